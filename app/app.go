@@ -1,8 +1,25 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"zen-test/app/auth"
+	"zen-test/app/consts"
 	"zen-test/app/database"
+	"zen-test/app/events"
+	"zen-test/app/helpers"
+	"zen-test/app/jobs"
+	"zen-test/app/middleware"
+	"zen-test/app/notifications"
+	"zen-test/app/oidc"
+	"zen-test/app/payments"
+	"zen-test/app/realtime"
 	"zen-test/app/web/controllers"
+	"zen-test/app/web/models"
 	"zen-test/app/web/repositories"
 	"zen-test/app/web/router"
 	"zen-test/app/web/services"
@@ -22,7 +39,9 @@ type AppConfig struct {
 	AppSchema      []string
 }
 
-func Init() (*mux.Router, AppConfig) {
+// Init wires the application. It returns the router, so more routes can be
+// mounted, and the authenticated handler to serve.
+func Init(ctx context.Context) (*mux.Router, http.Handler, AppConfig) {
 
 	appConfig := AppConfig{
 		AppName:        "Zenstore",
@@ -36,25 +55,240 @@ func Init() (*mux.Router, AppConfig) {
 	}
 
 	db := database.InitializeDB()
+	database.DBMigrate(db)
 	validate := validator.New()
 
 	userRepo := repositories.NewUserRepository()
 	productRepo := repositories.NewProductRepository()
 	orderRepo := repositories.NewOrderRepository()
 	imageRepo := repositories.NewImageRepository()
+	returnRepo := repositories.NewReturnRepository()
+	refundRepo := repositories.NewRefundRepository()
+	outboxRepo := repositories.NewOutboxRepository()
+	webhookRepo := repositories.NewWebhookRepository()
+	refreshTokenRepo := repositories.NewRefreshTokenRepository()
+	sessionRepo := repositories.NewSessionRepository()
+	signingKeyRepo := repositories.NewSigningKeyRepository()
+	passwordResetRepo := repositories.NewPasswordResetRepository()
+	emailVerificationRepo := repositories.NewEmailVerificationRepository()
+	loginAttemptRepo := repositories.NewLoginAttemptRepository()
+	twoFactorRepo := repositories.NewTwoFactorRepository()
+	loginChallengeRepo := repositories.NewLoginChallengeRepository()
+	externalIdentityRepo := repositories.NewExternalIdentityRepository()
+	apiKeyRepo := repositories.NewAPIKeyRepository()
+	addressRepo := repositories.NewAddressRepository()
+
+	paymentGateway := payments.NewManualGateway()
+	orderExpiryConfig := services.OrderExpiryConfig{
+		Window:   helpers.GetEnvDuration("ORDER_EXPIRY_WINDOW", 1*time.Hour),
+		Interval: helpers.GetEnvDuration("ORDER_EXPIRY_INTERVAL", 1*time.Hour),
+	}
+
+	jobQueue := jobs.NewQueue(db, jobs.Config{
+		Workers:      helpers.GetEnvInt("JOB_WORKERS", 4),
+		PollInterval: helpers.GetEnvDuration("JOB_POLL_INTERVAL", 2*time.Second),
+		MaxAttempts:  helpers.GetEnvInt("JOB_MAX_ATTEMPTS", 5),
+		BaseBackoff:  helpers.GetEnvDuration("JOB_BACKOFF_BASE", 10*time.Second),
+		MaxBackoff:   helpers.GetEnvDuration("JOB_BACKOFF_MAX", 1*time.Hour),
+		LockTimeout:  helpers.GetEnvDuration("JOB_LOCK_TIMEOUT", 15*time.Minute),
+		LeaseTTL:     helpers.GetEnvDuration("JOB_LEASE_TTL", 30*time.Second),
+	})
+
+	renderer, err := notifications.NewRenderer()
+	helpers.PanicIfError(err)
+	notifier, err := notifications.NewNotifier(notifications.Config{
+		Driver: helpers.GetEnv("MAIL_DRIVER", "file"),
+		From:   helpers.GetEnv("MAIL_FROM", "Zenstore <no-reply@zenstore.local>"),
+		Dir:    helpers.GetEnv("MAIL_DIR", "tmp/mail"),
+		SMTP: notifications.SMTPConfig{
+			Host:     helpers.GetEnv("SMTP_HOST", "localhost"),
+			Port:     helpers.GetEnv("SMTP_PORT", "587"),
+			Username: helpers.GetEnv("SMTP_USERNAME", ""),
+			Password: helpers.GetEnv("SMTP_PASSWORD", ""),
+		},
+	})
+	helpers.PanicIfError(err)
+
+	authConfig := services.AuthConfig{
+		RefreshTokenTTL: helpers.GetEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		Throttle: services.LoginThrottleConfig{
+			Window:         helpers.GetEnvDuration("LOGIN_THROTTLE_WINDOW", 15*time.Minute),
+			DelayAfter:     helpers.GetEnvInt("LOGIN_DELAY_AFTER", 3),
+			BaseDelay:      helpers.GetEnvDuration("LOGIN_DELAY_BASE", time.Second),
+			MaxDelay:       helpers.GetEnvDuration("LOGIN_DELAY_MAX", 30*time.Second),
+			LockoutAfter:   helpers.GetEnvInt("LOGIN_LOCKOUT_AFTER", 10),
+			IPLockoutAfter: helpers.GetEnvInt("LOGIN_IP_LOCKOUT_AFTER", 50),
+		},
+	}
+
+	secret := helpers.GetEnv("SECRET", "")
+	if secret == "" {
+		panic("SECRET must be set to seal the token signing keys and TOTP secrets")
+	}
+	keyConfig := services.KeyConfig{
+		Algorithm:        helpers.GetEnv("AUTH_KEY_ALGORITHM", auth.AlgorithmRS256),
+		RotationInterval: helpers.GetEnvDuration("AUTH_KEY_ROTATION", 30*24*time.Hour),
+		PublishLead:      helpers.GetEnvDuration("AUTH_KEY_PUBLISH_LEAD", 15*time.Minute),
+		Overlap:          helpers.GetEnvDuration("AUTH_KEY_OVERLAP", 2*time.Hour),
+		CheckInterval:    helpers.GetEnvDuration("AUTH_KEY_ROTATION_CHECK", 1*time.Hour),
+		RefreshInterval:  helpers.GetEnvDuration("AUTH_KEY_REFRESH_INTERVAL", 1*time.Minute),
+		Secret:           secret,
+	}
+	keySet := auth.NewKeySet()
+	keyService := services.NewKeyService(signingKeyRepo, keySet, keyConfig, db)
+	// Make sure a key exists before the first token is signed.
+	err = keyService.Rotate(ctx)
+	helpers.PanicIfError(err)
+	go keyService.Start(ctx)
+	tokens := auth.NewJWT(keySet, auth.TokenConfig{
+		Issuer:   helpers.GetEnv("JWT_ISSUER", "zenstore"),
+		Audience: helpers.GetEnv("JWT_AUDIENCE", "zenstore-api"),
+		TTL:      helpers.GetEnvDuration("ACCESS_TOKEN_TTL", 1*time.Hour),
+		Leeway:   helpers.GetEnvDuration("JWT_LEEWAY", 30*time.Second),
+	})
 
-	userService := services.NewUserService(userRepo, db, validate)
-	productservice := services.NewProductService(productRepo, imageRepo, db, validate)
-	orderService := services.NewOrderService(orderRepo, productRepo, userRepo, db, validate)
+	sessionService := services.NewSessionService(sessionRepo, refreshTokenRepo, userRepo, helpers.GetEnvDuration("SESSION_CACHE_TTL", 30*time.Second), db)
+	emailVerificationService := services.NewEmailVerificationService(userRepo, emailVerificationRepo, jobQueue, services.EmailVerificationConfig{
+		TokenTTL:       helpers.GetEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		ResendInterval: helpers.GetEnvDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", 1*time.Minute),
+		MaxPerDay:      helpers.GetEnvInt("EMAIL_VERIFICATION_MAX_PER_DAY", 5),
+		URL:            helpers.GetEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+	}, db, validate)
+	twoFactorService := services.NewTwoFactorService(userRepo, twoFactorRepo, loginChallengeRepo, services.TwoFactorConfig{
+		Issuer:        helpers.GetEnv("TWO_FACTOR_ISSUER", "Zenstore"),
+		Secret:        secret,
+		ChallengeTTL:  helpers.GetEnvDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute),
+		MaxAttempts:   helpers.GetEnvInt("TWO_FACTOR_MAX_ATTEMPTS", 5),
+		RecoveryCodes: helpers.GetEnvInt("TWO_FACTOR_RECOVERY_CODES", 10),
+	}, db, validate)
+	userService := services.NewUserService(userRepo, outboxRepo, refreshTokenRepo, sessionRepo, loginAttemptRepo, sessionService, emailVerificationService, twoFactorService, tokens, authConfig, db, validate)
+	passwordResetService := services.NewPasswordResetService(userRepo, passwordResetRepo, refreshTokenRepo, sessionRepo, sessionService, jobQueue, services.PasswordResetConfig{
+		TokenTTL: helpers.GetEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+		Throttle: helpers.GetEnvDuration("PASSWORD_RESET_THROTTLE", 1*time.Minute),
+		URL:      helpers.GetEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+	}, db, validate)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, services.APIKeyConfig{
+		TouchInterval: helpers.GetEnvDuration("API_KEY_TOUCH_INTERVAL", 1*time.Minute),
+	}, db, validate)
+	oidcService := services.NewOIDCService(oidcProviders(), userRepo, externalIdentityRepo, outboxRepo, userService, emailVerificationService, services.OIDCConfig{
+		StateTTL: helpers.GetEnvDuration("OIDC_STATE_TTL", 10*time.Minute),
+	}, db)
+	addressService := services.NewAddressService(addressRepo, db, validate)
+	accountService := services.NewAccountService(userRepo, addressRepo, orderRepo, outboxRepo, webhookRepo, sessionService, db, validate)
+	productservice := services.NewProductService(productRepo, imageRepo, outboxRepo, db, validate)
+	orderService := services.NewOrderService(orderRepo, productRepo, userRepo, addressRepo, refundRepo, outboxRepo, paymentGateway, orderExpiryConfig, db, validate)
+	returnService := services.NewReturnService(returnRepo, orderRepo, productRepo, userRepo, refundRepo, outboxRepo, paymentGateway, db, validate)
+	webhookService := services.NewWebhookService(webhookRepo, userRepo, jobQueue, services.WebhookConfig{
+		Timeout:             helpers.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		AllowPrivateTargets: helpers.GetEnvBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+	}, db, validate)
+	notificationService := services.NewNotificationService(userRepo, jobQueue, renderer, notifier, db)
+	orderEventService := services.NewOrderEventService(orderRepo, userRepo, outboxRepo, db)
+	dashboardService := services.NewDashboardService()
+	userAdminService := services.NewUserAdminService(userRepo, orderRepo, sessionService, db, validate)
+
+	realtimeHub := realtime.NewHub(db, outboxRepo, realtime.HubConfig{
+		PollInterval: helpers.GetEnvDuration("REALTIME_POLL_INTERVAL", 1*time.Second),
+		BatchSize:    helpers.GetEnvInt("REALTIME_BATCH_SIZE", 500),
+		BufferSize:   helpers.GetEnvInt("REALTIME_BUFFER_SIZE", 64),
+		Overlap:      helpers.GetEnvDuration("REALTIME_OVERLAP", 5*time.Second),
+	})
+	go realtimeHub.Start(ctx)
 
 	userController := controllers.NewUserController(userService)
+	sessionController := controllers.NewSessionController(sessionService)
+	passwordResetController := controllers.NewPasswordResetController(passwordResetService)
+	emailVerificationController := controllers.NewEmailVerificationController(emailVerificationService)
+	twoFactorController := controllers.NewTwoFactorController(twoFactorService)
+	oidcController := controllers.NewOIDCController(oidcService)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	addressController := controllers.NewAddressController(addressService)
+	accountController := controllers.NewAccountController(accountService)
 	productController := controllers.NewProductController(productservice)
 	orderController := controllers.NewOrderController(orderService)
+	orderEventController := controllers.NewOrderEventController(orderEventService, realtimeHub, helpers.GetEnvDuration("SSE_HEARTBEAT", 15*time.Second))
+	returnController := controllers.NewReturnController(returnService)
+	webhookController := controllers.NewWebhookController(webhookService)
+	keyController := controllers.NewKeyController(keyService)
+	userAdminController := controllers.NewUserAdminController(userAdminService)
+	dashboardController := controllers.NewDashboardController(dashboardService, realtimeHub, realtime.DashboardConfig{
+		LowStockThreshold: uint32(helpers.GetEnvInt("DASHBOARD_LOW_STOCK_THRESHOLD", 5)),
+		PingInterval:      helpers.GetEnvDuration("DASHBOARD_PING_INTERVAL", 30*time.Second),
+		WriteTimeout:      helpers.GetEnvDuration("DASHBOARD_WRITE_TIMEOUT", 10*time.Second),
+	})
 
-	go orderService.AutoCancelUnpaidOrders()
+	registerJobs(jobQueue, orderService, webhookService, notificationService, keyService, orderExpiryConfig, keyConfig)
+	jobQueue.Start(ctx)
 
-	router := router.InitializeRouter(userController, productController, orderController)
+	eventBus := events.NewBus()
+	eventBus.AddSink(events.NewLogSink())
+	if sinkURL := helpers.GetEnv("EVENT_SINK_URL", ""); sinkURL != "" {
+		eventBus.AddSink(events.NewHTTPSink(sinkURL))
+	}
+	eventBus.Subscribe(events.AllEvents, "webhooks", webhookService.Dispatch)
+	eventBus.Subscribe(events.AllEvents, "notifications", notificationService.Notify)
+	eventRelay := events.NewRelay(db, eventBus, outboxRepo, jobQueue.Locker(), events.RelayConfig{
+		Interval:    helpers.GetEnvDuration("EVENT_RELAY_INTERVAL", 1*time.Second),
+		BatchSize:   helpers.GetEnvInt("EVENT_RELAY_BATCH_SIZE", 100),
+		MaxAttempts: helpers.GetEnvInt("EVENT_RELAY_MAX_ATTEMPTS", 10),
+		BaseBackoff: helpers.GetEnvDuration("EVENT_RELAY_BACKOFF_BASE", 5*time.Second),
+		MaxBackoff:  helpers.GetEnvDuration("EVENT_RELAY_BACKOFF_MAX", 30*time.Minute),
+	})
+	go eventRelay.Start(ctx)
 
-	database.DBMigrate(db)
-	return router, appConfig
+	router := router.InitializeRouter(userController, sessionController, passwordResetController, emailVerificationController, twoFactorController, oidcController, apiKeyController, addressController, accountController, productController, orderController, orderEventController, returnController, webhookController, dashboardController, userAdminController, keyController, userService)
+
+	trustedProxies, err := helpers.ParseTrustedProxies(helpers.GetEnvList("TRUSTED_PROXIES", nil))
+	helpers.PanicIfError(err)
+	router.Use(middleware.ResolveClientIP(trustedProxies))
+	router.Use(middleware.RequireVerifiedEmail(helpers.GetEnvList("VERIFIED_EMAIL_REQUIRED", []string{"POST /orders", "POST /returns"}), emailVerificationService))
+
+	return router, middleware.AuthMiddleware(tokens, sessionService, apiKeyService, userService)(router), appConfig
+}
+
+func registerJobs(jobQueue *jobs.Queue, orderService services.OrderService, webhookService services.WebhookService, notificationService services.NotificationService, keyService services.KeyService, orderExpiryConfig services.OrderExpiryConfig, keyConfig services.KeyConfig) {
+	jobs.Handle(jobQueue, consts.JobTypeOrderExpiry, func(ctx context.Context, _ struct{}) error {
+		run := orderService.CancelUnpaidOrders(ctx)
+		if run.Failed > 0 {
+			return fmt.Errorf("%d unpaid orders could not be cancelled", run.Failed)
+		}
+		return nil
+	})
+
+	jobs.Handle(jobQueue, consts.JobTypeWebhookDelivery, func(ctx context.Context, job models.WebhookDeliveryJob) error {
+		return webhookService.Deliver(ctx, job.DeliveryID)
+	})
+
+	jobs.Handle(jobQueue, consts.JobTypeEmail, notificationService.SendEmail)
+	jobs.Handle(jobQueue, consts.JobTypeLinkEmail, notificationService.SendLinkEmail)
+
+	jobs.Handle(jobQueue, consts.JobTypeKeyRotation, func(ctx context.Context, _ struct{}) error {
+		return keyService.Rotate(ctx)
+	})
+
+	err := jobQueue.Schedule("order-expiry", "@every "+orderExpiryConfig.Interval.String(), consts.JobTypeOrderExpiry, nil)
+	helpers.PanicIfError(err)
+
+	err = jobQueue.Schedule("signing-key-rotation", "@every "+keyConfig.CheckInterval.String(), consts.JobTypeKeyRotation, nil)
+	helpers.PanicIfError(err)
+}
+
+// oidcProviders reads the identity providers named in OIDC_PROVIDERS, each
+// configured by OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL
+// and optionally _SCOPES.
+func oidcProviders() map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider)
+	httpClient := &http.Client{Timeout: helpers.GetEnvDuration("OIDC_TIMEOUT", 10*time.Second)}
+
+	for _, name := range helpers.GetEnvList("OIDC_PROVIDERS", nil) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers[name] = oidc.NewProvider(oidc.Config{
+			Issuer:       helpers.GetEnv(prefix+"ISSUER", ""),
+			ClientID:     helpers.GetEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: helpers.GetEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  helpers.GetEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:       helpers.GetEnvList(prefix+"SCOPES", nil),
+		}, httpClient)
+	}
+	return providers
 }
@@ -1,54 +1,108 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var secretKey = os.Getenv("SECRET")
+// TokenIssuer mints access tokens for a user's login session.
+type TokenIssuer interface {
+	Issue(userId string, sessionId string) (string, error)
+}
 
-func CreateToken(id string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"id":  id,
-			"exp": time.Now().Add(time.Hour * 1).Unix(),
-		})
-	tokenString, err := token.SignedString([]byte(secretKey))
-	if err != nil {
-		return "", err
+// TokenVerifier checks an access token and returns its claims.
+type TokenVerifier interface {
+	Verify(tokenString string) (Claims, error)
+}
+
+// Claims are the registered claims every access token carries, plus sid which
+// names the login session so the token stops working when that session is
+// revoked.
+type Claims struct {
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// Leeway is the clock skew tolerated on exp, nbf and iat.
+	Leeway time.Duration
+}
+
+// JWT issues and verifies access tokens signed with the keys of a KeySet.
+type JWT struct {
+	Keys   *KeySet
+	Config TokenConfig
+}
+
+func NewJWT(keySet *KeySet, config TokenConfig) *JWT {
+	return &JWT{
+		Keys:   keySet,
+		Config: config,
 	}
-	return tokenString, nil
 }
 
-func CreateRefreshToken(id string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"id":  id,
-			"exp": time.Now().Add(time.Hour * 24 * 7).Unix(),
-		})
-	tokenString, err := token.SignedString([]byte(secretKey))
-	if err != nil {
-		return "", err
+func (j *JWT) Issue(userId string, sessionId string) (string, error) {
+	now := time.Now()
+	key, ok := j.Keys.Signing(now)
+	if !ok {
+		return "", errors.New("no active signing key")
 	}
-	return tokenString, nil
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), Claims{
+		SessionID: sessionId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.Config.Issuer,
+			Subject:   userId,
+			Audience:  jwt.ClaimStrings{j.Config.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.Config.TTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+	})
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.PrivateKey)
 }
 
-func VerifyToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
+// Verify only accepts tokens signed by a known key with that key's own
+// algorithm, so a token cannot pick a weaker algorithm or a public key as an
+// HMAC secret, and requires every registered claim Issue sets.
+func (j *JWT) Verify(tokenString string) (Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}),
+		jwt.WithIssuer(j.Config.Issuer),
+		jwt.WithAudience(j.Config.Audience),
+		jwt.WithLeeway(j.Config.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+
+	var claims Claims
+	_, err := parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.Keys.Key(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.PublicKey(), nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Invalid token: %v", err)
-	}
-	if !token.Valid {
-		return nil, fmt.Errorf("Invalid token")
+		return Claims{}, err
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, fmt.Errorf("Invalid token claims")
+
+	if claims.Subject == "" || claims.ID == "" || claims.IssuedAt == nil || claims.NotBefore == nil {
+		return Claims{}, errors.New("token is missing required claims")
 	}
 	return claims, nil
 }
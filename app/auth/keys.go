@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// SigningKey is one key of the set, identified in token headers by its kid.
+// A key signs from ActivatesAt until a newer key activates, and verifies until
+// RetiresAt so tokens it signed outlive the rotation.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	ActivatesAt time.Time
+	RetiresAt   *time.Time
+}
+
+func (k SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+func (k SigningKey) retired(now time.Time) bool {
+	return k.RetiresAt != nil && !k.RetiresAt.After(now)
+}
+
+func GenerateSigningKey(algorithm string, activatesAt time.Time) (SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return SigningKey{
+		ID:          uuid.New().String(),
+		Algorithm:   algorithm,
+		PrivateKey:  privateKey,
+		ActivatesAt: activatesAt,
+	}, nil
+}
+
+// SealPrivateKey encrypts the key with Seal, so it can be shared between
+// replicas through the database.
+func SealPrivateKey(privateKey crypto.Signer, secret string) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return Seal(der, secret)
+}
+
+func OpenPrivateKey(sealed string, secret string) (crypto.Signer, error) {
+	der, err := Open(sealed, secret)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt signing key: %v", err)
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+	return signer, nil
+}
+
+// KeySet holds the keys tokens are signed and verified with. It is safe for
+// concurrent use and can be swapped wholesale when keys rotate.
+type KeySet struct {
+	mutex sync.RWMutex
+	keys  []SigningKey
+}
+
+func NewKeySet(keys ...SigningKey) *KeySet {
+	keySet := &KeySet{}
+	keySet.Replace(keys)
+	return keySet
+}
+
+func (ks *KeySet) Replace(keys []SigningKey) {
+	sorted := append([]SigningKey(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActivatesAt.Before(sorted[j].ActivatesAt)
+	})
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	ks.keys = sorted
+}
+
+// Signing returns the most recently activated key that has not retired.
+func (ks *KeySet) Signing(now time.Time) (SigningKey, bool) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		key := ks.keys[i]
+		if !key.ActivatesAt.After(now) && !key.retired(now) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// Key returns the key a token names in its kid header. Keys that are
+// published but not yet active verify too, so the set can be loaded by
+// every replica before any of them signs with it.
+func (ks *KeySet) Key(kid string, now time.Time) (SigningKey, bool) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	for _, key := range ks.keys {
+		if key.ID == kid && !key.retired(now) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// Published returns every key that verifies tokens at now.
+func (ks *KeySet) Published(now time.Time) []SigningKey {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	var keys []SigningKey
+	for _, key := range ks.keys {
+		if !key.retired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"zen-test/app/consts"
+)
+
+type scopesContextKey struct{}
+
+var customerScopes = []string{
+	consts.ScopeProductsRead,
+	consts.ScopeOrdersReadOwn,
+	consts.ScopeOrdersWriteOwn,
+	consts.ScopeReturnsReadOwn,
+	consts.ScopeReturnsWriteOwn,
+}
+
+var staffScopes = append([]string{
+	consts.ScopeProductsWrite,
+	consts.ScopeOrdersReadAll,
+	consts.ScopeOrdersWriteAll,
+	consts.ScopeReturnsReadAll,
+	consts.ScopeReturnsWriteAll,
+	consts.ScopeWebhooksRead,
+	consts.ScopeWebhooksWrite,
+	consts.ScopeDashboardRead,
+	consts.ScopeUsersRead,
+	consts.ScopeUsersWrite,
+}, customerScopes...)
+
+var adminScopes = append([]string{
+	consts.ScopeUsersRoles,
+}, staffScopes...)
+
+// RoleScopes are the scopes each role is granted.
+var RoleScopes = map[string][]string{
+	consts.UserRoleCustomer: customerScopes,
+	consts.UserRoleStaff:    staffScopes,
+	consts.UserRoleAdmin:    adminScopes,
+}
+
+// Satisfies tells whether the granted scopes include required, counting a
+// scope ending in ":all" as covering the one ending in ":own".
+func Satisfies(granted []string, required string) bool {
+	all := strings.TrimSuffix(required, ":own") + ":all"
+	for _, scope := range granted {
+		if scope == required || (strings.HasSuffix(required, ":own") && scope == all) {
+			return true
+		}
+	}
+	return false
+}
+
+// Intersect returns the scopes satisfied by both a and b, such as what a
+// user's API key may do given what the user themselves may do.
+func Intersect(a []string, b []string) []string {
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, scope := range append(append([]string{}, a...), b...) {
+		candidates := []string{scope}
+		if strings.HasSuffix(scope, ":all") {
+			candidates = append(candidates, strings.TrimSuffix(scope, ":all")+":own")
+		}
+
+		for _, candidate := range candidates {
+			if seen[candidate] || !Satisfies(a, candidate) || !Satisfies(b, candidate) {
+				continue
+			}
+			seen[candidate] = true
+			scopes = append(scopes, candidate)
+		}
+	}
+	return scopes
+}
+
+// WithScopes returns a copy of ctx carrying the scopes granted to the request.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesContextKey{}, scopes)
+}
+
+// HasScope tells whether the request of ctx was granted scope.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, _ := ctx.Value(scopesContextKey{}).([]string)
+	return Satisfies(scopes, scope)
+}
@@ -1,8 +1,75 @@
 package consts
 
 const (
-	OrderPaymentStatusUnpaid         = "UNPAID"
-	OrderPaymentStatusPaid           = "PAID"
-	OrderPaymentStatusCancel         = "CANCEL"
-	TaxRate                  float64 = 0.1
+	OrderPaymentStatusUnpaid                    = "UNPAID"
+	OrderPaymentStatusPaid                      = "PAID"
+	OrderPaymentStatusCancel                    = "CANCEL"
+	OrderPaymentStatusPartiallyRefunded         = "PARTIALLY_REFUNDED"
+	OrderPaymentStatusRefunded                  = "REFUNDED"
+	OrderPaymentStatusShipped                   = "SHIPPED"
+	TaxRate                             float64 = 0.1
+)
+
+const (
+	UserRoleCustomer = "CUSTOMER"
+	UserRoleStaff    = "STAFF"
+	UserRoleAdmin    = "ADMIN"
+)
+
+// Scopes name what a request may do. Roles grant a set of them, and an API
+// key is limited to the ones it was created with. A scope ending in ":all"
+// covers the same scope ending in ":own".
+const (
+	ScopeProductsRead    = "products:read"
+	ScopeProductsWrite   = "products:write"
+	ScopeOrdersReadOwn   = "orders:read:own"
+	ScopeOrdersReadAll   = "orders:read:all"
+	ScopeOrdersWriteOwn  = "orders:write:own"
+	ScopeOrdersWriteAll  = "orders:write:all"
+	ScopeReturnsReadOwn  = "returns:read:own"
+	ScopeReturnsReadAll  = "returns:read:all"
+	ScopeReturnsWriteOwn = "returns:write:own"
+	ScopeReturnsWriteAll = "returns:write:all"
+	ScopeWebhooksRead    = "webhooks:read"
+	ScopeWebhooksWrite   = "webhooks:write"
+	ScopeDashboardRead   = "dashboard:read"
+	ScopeUsersRead       = "users:read"
+	ScopeUsersWrite      = "users:write"
+	ScopeUsersRoles      = "users:roles"
+)
+
+const (
+	ReturnStatusRequested = "REQUESTED"
+	ReturnStatusApproved  = "APPROVED"
+	ReturnStatusRejected  = "REJECTED"
+	ReturnStatusReceived  = "RECEIVED"
+)
+
+const (
+	RefundStatusSucceeded = "SUCCEEDED"
+	RefundStatusFailed    = "FAILED"
+)
+
+const (
+	JobStatusPending = "PENDING"
+	JobStatusRunning = "RUNNING"
+	JobStatusDone    = "DONE"
+)
+
+const (
+	JobTypeOrderExpiry     = "orders.expire_unpaid"
+	JobTypeWebhookDelivery = "webhooks.deliver"
+	JobTypeEmail           = "notifications.email"
+	JobTypeKeyRotation     = "auth.rotate_signing_keys"
+	JobTypeLinkEmail       = "notifications.link_email"
+)
+
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderPaid      = "order.paid"
+	EventOrderCancelled = "order.cancelled"
+	EventOrderShipped   = "order.shipped"
+	EventProductUpdated = "product.updated"
+	EventStockChanged   = "stock.changed"
+	EventUserRegistered = "user.registered"
 )
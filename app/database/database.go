@@ -61,6 +61,29 @@ func DBMigrate(db *gorm.DB) {
 		&models.Image{},
 		&models.Product{},
 		&models.OrderItem{},
+		&models.ReturnRequest{},
+		&models.ReturnItem{},
+		&models.Refund{},
+		&models.OrderExpiryTotals{},
+		&models.Job{},
+		&models.DeadJob{},
+		&models.JobLease{},
+		&models.OutboxEvent{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.SigningKey{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.LoginAttempt{},
+		&models.TwoFactor{},
+		&models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.ExternalIdentity{},
+		&models.OIDCLoginState{},
+		&models.APIKey{},
+		&models.Address{},
 	)
 	helpers.PanicIfError(err)
 
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"zen-test/app/web/models"
+)
+
+// AllEvents subscribes a handler to every event type.
+const AllEvents = "*"
+
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	UserID        string          `json:"user_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// Subscriber handles an event in process. Delivery is at least once: an
+// event is not handed to a subscriber again once it succeeded, but may be
+// after the subscriber failed part way through.
+type Subscriber func(ctx context.Context, event Event) error
+
+type namedSubscriber struct {
+	name    string
+	handler Subscriber
+}
+
+// Sink forwards events to a system outside this process.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers map[string][]namedSubscriber
+	sinks       []Sink
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]namedSubscriber)}
+}
+
+// Subscribe hands events of eventType to subscriber. The name tells which
+// subscribers already have an event when it is published again, so it has
+// to be unique and stay the same across restarts.
+func (b *Bus) Subscribe(eventType string, name string, subscriber Subscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subscribers[eventType] = append(b.subscribers[eventType], namedSubscriber{name: name, handler: subscriber})
+}
+
+func (b *Bus) AddSink(sink Sink) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sinks = append(b.sinks, sink)
+}
+
+// Publish hands the event to every matching subscriber and sink except the
+// ones named in delivered, which have it from an earlier attempt. It returns
+// delivered along with the ones that took it now, and all failures together.
+func (b *Bus) Publish(ctx context.Context, event Event, delivered []string) ([]string, error) {
+	b.mutex.RLock()
+	subscribers := append(append([]namedSubscriber(nil), b.subscribers[event.Type]...), b.subscribers[AllEvents]...)
+	sinks := append([]Sink(nil), b.sinks...)
+	b.mutex.RUnlock()
+
+	done := make(map[string]bool)
+	for _, target := range delivered {
+		done[target] = true
+	}
+
+	var errs []error
+	for _, subscriber := range subscribers {
+		target := "subscriber:" + subscriber.name
+		if done[target] {
+			continue
+		}
+		if err := deliver(ctx, subscriber.handler, event); err != nil {
+			errs = append(errs, fmt.Errorf("subscriber %s: %v", subscriber.name, err))
+			continue
+		}
+		done[target] = true
+		delivered = append(delivered, target)
+	}
+	for _, sink := range sinks {
+		target := "sink:" + sink.Name()
+		if done[target] {
+			continue
+		}
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %v", sink.Name(), err))
+			continue
+		}
+		done[target] = true
+		delivered = append(delivered, target)
+	}
+
+	return delivered, errors.Join(errs...)
+}
+
+func deliver(ctx context.Context, subscriber Subscriber, event Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("subscriber panic: %v", recovered)
+		}
+	}()
+
+	return subscriber(ctx, event)
+}
+
+func FromOutbox(outboxEvent models.OutboxEvent) Event {
+	return Event{
+		ID:            outboxEvent.ID,
+		Type:          outboxEvent.Type,
+		AggregateType: outboxEvent.AggregateType,
+		AggregateID:   outboxEvent.AggregateID,
+		UserID:        outboxEvent.UserID,
+		Payload:       json.RawMessage(outboxEvent.Payload),
+		OccurredAt:    outboxEvent.CreatedAt,
+	}
+}
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"zen-test/app/jobs"
+	"zen-test/app/web/repositories"
+
+	"gorm.io/gorm"
+)
+
+const relayLockName = "zenstore:events:relay"
+
+type RelayConfig struct {
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Relay moves committed outbox events to the bus. Only the replica holding
+// the relay lock publishes, which keeps events in order across the cluster.
+type Relay struct {
+	DB               *gorm.DB
+	Bus              *Bus
+	OutboxRepository repositories.OutboxRepository
+	Locker           jobs.Locker
+	Config           RelayConfig
+}
+
+func NewRelay(db *gorm.DB, bus *Bus, outboxRepo repositories.OutboxRepository, locker jobs.Locker, config RelayConfig) *Relay {
+	return &Relay{
+		DB:               db,
+		Bus:              bus,
+		OutboxRepository: outboxRepo,
+		Locker:           locker,
+		Config:           config,
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.Config.Interval)
+	defer ticker.Stop()
+
+	var leadership jobs.Lock
+	defer func() {
+		if leadership != nil {
+			leadership.Unlock()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if leadership != nil {
+			select {
+			case <-leadership.Lost():
+				leadership.Unlock()
+				leadership = nil
+			default:
+			}
+		}
+
+		if leadership == nil {
+			lock, acquired, err := r.Locker.TryLock(ctx, relayLockName)
+			if err != nil {
+				log.Printf("Event relay failed to take leadership: %v", err)
+				continue
+			}
+			if !acquired {
+				continue
+			}
+			leadership = lock
+		}
+
+		if _, err := r.RelayOnce(ctx); err != nil {
+			log.Printf("Event relay error: %v", err)
+		}
+	}
+}
+
+// RelayOnce publishes one batch of due events in the order they were written.
+// A failed event is retried with backoff, and only to the subscribers and
+// sinks that did not take it yet; later events of the same aggregate wait
+// behind it, others go on. After MaxAttempts the event is parked.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	outboxEvents, err := r.OutboxRepository.FindUnpublished(ctx, r.DB, time.Now(), r.Config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := make(map[string]bool)
+	var errs []error
+	for _, outboxEvent := range outboxEvents {
+		aggregate := outboxEvent.AggregateType + ":" + outboxEvent.AggregateID
+		if blocked[aggregate] {
+			continue
+		}
+
+		delivered, err := r.Bus.Publish(ctx, FromOutbox(outboxEvent), splitDelivered(outboxEvent.Delivered))
+		if err != nil {
+			blocked[aggregate] = true
+			errs = append(errs, err)
+
+			if markErr := r.markFailed(ctx, outboxEvent.ID, outboxEvent.Attempts+1, err, delivered); markErr != nil {
+				return published, markErr
+			}
+			continue
+		}
+
+		if err := r.OutboxRepository.MarkPublished(ctx, r.DB, outboxEvent.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, errors.Join(errs...)
+}
+
+// markFailed schedules the event for another attempt, or parks it once it
+// has failed MaxAttempts times.
+func (r *Relay) markFailed(ctx context.Context, eventId string, attempts int, cause error, delivered []string) error {
+	if attempts >= r.Config.MaxAttempts {
+		log.Printf("Event relay parked event %s after %d attempts: %v", eventId, attempts, cause)
+		return r.OutboxRepository.MarkDead(ctx, r.DB, eventId, cause.Error(), strings.Join(delivered, ","))
+	}
+
+	return r.OutboxRepository.MarkFailed(ctx, r.DB, eventId, cause.Error(), strings.Join(delivered, ","), time.Now().Add(r.backoff(attempts)))
+}
+
+// backoff doubles with each attempt, starting at BaseBackoff and capped at
+// MaxBackoff, the same way the job queue retries.
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.Config.BaseBackoff
+	for i := 1; i < attempts && backoff < r.Config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.Config.MaxBackoff {
+		backoff = r.Config.MaxBackoff
+	}
+	return backoff
+}
+
+func splitDelivered(delivered string) []string {
+	if delivered == "" {
+		return nil
+	}
+	return strings.Split(delivered, ",")
+}
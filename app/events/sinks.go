@@ -0,0 +1,65 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+type LogSink struct {
+}
+
+func NewLogSink() Sink {
+	return &LogSink{}
+}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Publish(ctx context.Context, event Event) error {
+	log.Printf("Event %s %s %s/%s", event.ID, event.Type, event.AggregateType, event.AggregateID)
+	return nil
+}
+
+// HTTPSink posts every event as JSON to a single collector endpoint.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPSink(url string) Sink {
+	return &HTTPSink{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *HTTPSink) Name() string {
+	return "http"
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return nil
+}
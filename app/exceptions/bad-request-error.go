@@ -0,0 +1,9 @@
+package exceptions
+
+type BadRequestError struct {
+	Error string
+}
+
+func NewBadRequestError(err string) BadRequestError {
+	return BadRequestError{Error: err}
+}
@@ -1,7 +1,9 @@
 package exceptions
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 
 	"zen-test/app/helpers"
 	"zen-test/app/web"
@@ -14,6 +16,22 @@ func ErrorHandler(writer http.ResponseWriter, request *http.Request, err interfa
 		return
 	}
 
+	if badRequestError(writer, request, err) {
+		return
+	}
+
+	if forbiddenError(writer, request, err) {
+		return
+	}
+
+	if unauthorizedError(writer, request, err) {
+		return
+	}
+
+	if tooManyRequestsError(writer, request, err) {
+		return
+	}
+
 	if validationError(writer, request, err) {
 		return
 	}
@@ -66,6 +84,81 @@ func notFoundError(writer http.ResponseWriter, request *http.Request, err interf
 	return false
 }
 
+func badRequestError(writer http.ResponseWriter, request *http.Request, err interface{}) bool {
+	exception, ok := err.(BadRequestError)
+	if ok {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusBadRequest)
+
+		webResponse := web.WebResponse{
+			Code:   http.StatusBadRequest,
+			Status: "Bad Request",
+			Data:   exception.Error,
+		}
+
+		helpers.WriteResponseBody(writer, webResponse)
+		return true
+	}
+	return false
+}
+
+func forbiddenError(writer http.ResponseWriter, request *http.Request, err interface{}) bool {
+	exception, ok := err.(ForbiddenError)
+	if ok {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusForbidden)
+
+		webResponse := web.WebResponse{
+			Code:   http.StatusForbidden,
+			Status: "Forbidden",
+			Data:   exception.Error,
+		}
+
+		helpers.WriteResponseBody(writer, webResponse)
+		return true
+	}
+	return false
+}
+
+func unauthorizedError(writer http.ResponseWriter, request *http.Request, err interface{}) bool {
+	exception, ok := err.(UnauthorizedError)
+	if ok {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusUnauthorized)
+
+		webResponse := web.WebResponse{
+			Code:   http.StatusUnauthorized,
+			Status: "Unauthorized",
+			Data:   exception.Error,
+		}
+
+		helpers.WriteResponseBody(writer, webResponse)
+		return true
+	}
+	return false
+}
+
+func tooManyRequestsError(writer http.ResponseWriter, request *http.Request, err interface{}) bool {
+	exception, ok := err.(TooManyRequestsError)
+	if ok {
+		writer.Header().Set("Content-Type", "application/json")
+		if exception.RetryAfter > 0 {
+			writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(exception.RetryAfter.Seconds()))))
+		}
+		writer.WriteHeader(http.StatusTooManyRequests)
+
+		webResponse := web.WebResponse{
+			Code:   http.StatusTooManyRequests,
+			Status: "Too Many Requests",
+			Data:   exception.Error,
+		}
+
+		helpers.WriteResponseBody(writer, webResponse)
+		return true
+	}
+	return false
+}
+
 func internalServerError(writer http.ResponseWriter, request *http.Request, err interface{}) {
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,9 @@
+package exceptions
+
+type ForbiddenError struct {
+	Error string
+}
+
+func NewForbiddenError(err string) ForbiddenError {
+	return ForbiddenError{Error: err}
+}
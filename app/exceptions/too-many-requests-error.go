@@ -0,0 +1,14 @@
+package exceptions
+
+import (
+	"time"
+)
+
+type TooManyRequestsError struct {
+	Error      string
+	RetryAfter time.Duration
+}
+
+func NewTooManyRequestsError(err string, retryAfter time.Duration) TooManyRequestsError {
+	return TooManyRequestsError{Error: err, RetryAfter: retryAfter}
+}
@@ -0,0 +1,9 @@
+package exceptions
+
+type UnauthorizedError struct {
+	Error string
+}
+
+func NewUnauthorizedError(err string) UnauthorizedError {
+	return UnauthorizedError{Error: err}
+}
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// maxIPLength is the size of the IP columns, enough for any IPv6 address.
+const maxIPLength = 45
+
+// ParseTrustedProxies reads the proxies whose X-Forwarded-For is believed,
+// each an IP address or a CIDR range.
+func ParseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR range", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, proxy, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR range", value)
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For is only
+// believed when the request comes from a trusted proxy, and then read from the
+// right, past the trusted proxies, to the first hop that is not one: anything
+// left of it was sent by the client and may be made up. The result always
+// fits the IP columns.
+func ClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		if len(host) > maxIPLength {
+			host = host[:maxIPLength]
+		}
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 && isTrustedProxy(ip, trustedProxies) {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			ip = hop
+			if !isTrustedProxy(ip, trustedProxies) {
+				break
+			}
+		}
+	}
+
+	return ip.String()
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,12 @@
 package helpers
 
-import "os"
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 func GetEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -9,3 +15,61 @@ func GetEnv(key, fallback string) string {
 
 	return fallback
 }
+
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, fallback)
+		return fallback
+	}
+	return duration
+}
+
+func GetEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid number %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return number
+}
+
+func GetEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid flag %q for %s, using %t", value, key, fallback)
+		return fallback
+	}
+	return flag
+}
+
+// GetEnvList reads a comma separated list; an empty value is an empty list.
+func GetEnvList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -8,6 +8,7 @@ import (
 const (
 	UserSession  = "user_session"
 	RefreshToken = "refresh_token"
+	OIDCState    = "oidc_state"
 )
 
 func SetCookie(w http.ResponseWriter, r *http.Request, sessionType string, value string) {
@@ -23,6 +24,25 @@ func SetCookie(w http.ResponseWriter, r *http.Request, sessionType string, value
 	http.SetCookie(w, &cookie)
 }
 
+// SetScopedCookie sets a cookie that is only sent to paths under path and
+// lapses at expires. An expires in the past removes it.
+func SetScopedCookie(w http.ResponseWriter, name string, value string, path string, expires time.Time) {
+	cookie := http.Cookie{
+		Name:     name,
+		Value:    value,
+		Expires:  expires,
+		Path:     path,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if !expires.After(time.Now()) {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(w, &cookie)
+}
+
 func GetCookie(w http.ResponseWriter, r *http.Request, sessionType string) (*http.Cookie, error) {
 	cookie, err := r.Cookie(sessionType)
 	if err != nil {
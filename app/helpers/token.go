@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// RandomToken returns an unguessable URL-safe token made of size random bytes.
+func RandomToken(size int) string {
+	token := make([]byte, size)
+	_, err := rand.Read(token)
+	PanicIfError(err)
+
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// HashToken is how opaque tokens are stored, so a leaked table cannot be
+// replayed.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
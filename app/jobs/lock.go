@@ -0,0 +1,210 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Locker hands out named locks shared by every replica through the database,
+// so work that must happen once per cluster runs on a single instance.
+type Locker interface {
+	// TryLock returns immediately; ok is false when another holder has the lock.
+	TryLock(ctx context.Context, name string) (lock Lock, ok bool, err error)
+}
+
+type Lock interface {
+	Unlock() error
+	// Lost is closed once the lock can no longer be guaranteed, for example
+	// because the database connection holding it went away.
+	Lost() <-chan struct{}
+}
+
+// NewLocker picks the native locking primitive of the database: advisory
+// locks on Postgres, GET_LOCK on MySQL and a lease table everywhere else.
+// Native locks belong to a connection, so a replica that dies releases them
+// and another one takes over.
+func NewLocker(db *gorm.DB, holder string, leaseTTL time.Duration) Locker {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return &sessionLocker{db: db, lockQuery: "SELECT pg_try_advisory_lock(hashtext($1))", unlockQuery: "SELECT pg_advisory_unlock(hashtext($1))", checkInterval: leaseTTL / 3}
+	case "mysql":
+		return &sessionLocker{db: db, lockQuery: "SELECT GET_LOCK(?, 0) = 1", unlockQuery: "SELECT RELEASE_LOCK(?)", checkInterval: leaseTTL / 3}
+	default:
+		return NewLeaseLocker(db, holder, leaseTTL)
+	}
+}
+
+type sessionLocker struct {
+	db            *gorm.DB
+	lockQuery     string
+	unlockQuery   string
+	checkInterval time.Duration
+}
+
+type sessionLock struct {
+	conn        *sql.Conn
+	name        string
+	unlockQuery string
+	lost        chan struct{}
+	done        chan struct{}
+	once        sync.Once
+}
+
+func (l *sessionLocker) TryLock(ctx context.Context, name string) (Lock, bool, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Session level locks live on one connection, which is kept out of the
+	// pool for as long as the lock is held.
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, l.lockQuery, name).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	lock := &sessionLock{conn: conn, name: name, unlockQuery: l.unlockQuery, lost: make(chan struct{}), done: make(chan struct{})}
+	go lock.watch(l.checkInterval)
+	return lock, true, nil
+}
+
+func (l *sessionLock) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := l.conn.PingContext(ctx)
+			cancel()
+			if err != nil {
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
+
+func (l *sessionLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *sessionLock) Unlock() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		_, err = l.conn.ExecContext(context.Background(), l.unlockQuery, l.name)
+		if closeErr := l.conn.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+type leaseLocker struct {
+	db     *gorm.DB
+	holder string
+	ttl    time.Duration
+}
+
+type leaseLock struct {
+	locker *leaseLocker
+	name   string
+	lost   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewLeaseLocker implements Locker with rows in the job_leases table that
+// expire after ttl unless the holder keeps renewing them, so a crashed
+// holder is taken over once its lease runs out.
+func NewLeaseLocker(db *gorm.DB, holder string, ttl time.Duration) Locker {
+	return &leaseLocker{db: db, holder: holder, ttl: ttl}
+}
+
+func (l *leaseLocker) TryLock(ctx context.Context, name string) (Lock, bool, error) {
+	acquired, err := l.acquire(ctx, name)
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+
+	lock := &leaseLock{locker: l, name: name, lost: make(chan struct{}), done: make(chan struct{})}
+	go lock.renew(ctx)
+	return lock, true, nil
+}
+
+func (l *leaseLocker) acquire(ctx context.Context, name string) (bool, error) {
+	now := time.Now()
+	lease := models.JobLease{Name: name, Holder: l.holder, ExpiresAt: now.Add(l.ttl)}
+
+	result := l.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&lease)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	result = l.db.WithContext(ctx).Model(&models.JobLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, l.holder, now).
+		Updates(map[string]interface{}{"holder": l.holder, "expires_at": now.Add(l.ttl)})
+	return result.RowsAffected == 1, result.Error
+}
+
+// renew extends the lease until it is unlocked or ctx ends. Once ctx ends
+// the lease is simply left to expire, which is also what happens when the
+// process dies.
+func (l *leaseLock) renew(ctx context.Context) {
+	ticker := time.NewTicker(l.locker.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result := l.locker.db.Model(&models.JobLease{}).
+				Where("name = ? AND holder = ?", l.name, l.locker.holder).
+				Update("expires_at", time.Now().Add(l.locker.ttl))
+			if result.Error != nil || result.RowsAffected != 1 {
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
+
+func (l *leaseLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *leaseLock) Unlock() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		err = l.locker.db.Where("name = ? AND holder = ?", l.name, l.locker.holder).Delete(&models.JobLease{}).Error
+	})
+	return err
+}
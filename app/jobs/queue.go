@@ -0,0 +1,470 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"zen-test/app/consts"
+	"zen-test/app/web/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Handler processes the raw JSON payload of a job. Returning an error makes
+// the queue retry the job with backoff until it runs out of attempts.
+type Handler func(ctx context.Context, payload []byte) error
+
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// LockTimeout is how long a job may stay running before it is assumed
+	// that its worker died and the job is handed out again.
+	LockTimeout time.Duration
+	// LeaseTTL bounds how long a dead replica can keep scheduler leadership
+	// or a scheduled job lock before another replica takes over.
+	LeaseTTL time.Duration
+}
+
+const schedulerLockName = "zenstore:jobs:scheduler"
+
+type Queue struct {
+	DB     *gorm.DB
+	Config Config
+
+	workerId   string
+	locker     Locker
+	mutex      sync.RWMutex
+	handlers   map[string]Handler
+	schedules  []scheduledJob
+	singletons map[string]bool
+	wake       chan struct{}
+	waitGroup  sync.WaitGroup
+}
+
+type scheduledJob struct {
+	name     string
+	schedule Schedule
+	jobType  string
+	payload  interface{}
+}
+
+type EnqueueOption func(job *models.Job)
+
+func NewQueue(db *gorm.DB, config Config) *Queue {
+	hostname, _ := os.Hostname()
+	workerId := fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8])
+
+	return &Queue{
+		DB:         db,
+		Config:     config,
+		workerId:   workerId,
+		locker:     NewLocker(db, workerId, config.LeaseTTL),
+		handlers:   make(map[string]Handler),
+		singletons: make(map[string]bool),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// RunAt delays the job until the given time.
+func RunAt(runAt time.Time) EnqueueOption {
+	return func(job *models.Job) {
+		job.RunAt = runAt
+	}
+}
+
+// MaxAttempts overrides the queue wide attempt limit for one job.
+func MaxAttempts(attempts int) EnqueueOption {
+	return func(job *models.Job) {
+		job.MaxAttempts = attempts
+	}
+}
+
+// UniqueKey makes Enqueue store the job only once for the key, so work that
+// is triggered again, like an event delivered twice, is not done twice.
+func UniqueKey(key string) EnqueueOption {
+	return func(job *models.Job) {
+		job.UniqueKey = &key
+	}
+}
+
+// ForUser records the user whose personal data the payload holds, so the job
+// is removed with their account.
+func ForUser(userId string) EnqueueOption {
+	return func(job *models.Job) {
+		job.UserID = userId
+	}
+}
+
+func (q *Queue) Register(jobType string, handler Handler) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.handlers[jobType] = handler
+}
+
+// Handle registers a handler that receives the payload already decoded into T.
+func Handle[T any](q *Queue, jobType string, handler func(ctx context.Context, payload T) error) {
+	q.Register(jobType, func(ctx context.Context, raw []byte) error {
+		var payload T
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				return fmt.Errorf("decode %s payload: %v", jobType, err)
+			}
+		}
+		return handler(ctx, payload)
+	})
+}
+
+// Schedule enqueues a job of jobType every time spec fires. See ParseSchedule
+// for the supported formats. Only the replica holding scheduler leadership
+// enqueues, and a scheduled job type never runs on two workers at once.
+func (q *Queue) Schedule(name string, spec string, jobType string, payload interface{}) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.schedules = append(q.schedules, scheduledJob{name: name, schedule: schedule, jobType: jobType, payload: payload})
+	q.singletons[jobType] = true
+	return nil
+}
+
+// Enqueue stores a job using db, which may be a transaction so the job only
+// becomes visible when the surrounding business change commits. When a job
+// with the same unique key exists, that job is returned and nothing is stored.
+func (q *Queue) Enqueue(ctx context.Context, db *gorm.DB, jobType string, payload interface{}, options ...EnqueueOption) (models.Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job := models.Job{
+		ID:          uuid.New().String(),
+		Type:        jobType,
+		Payload:     string(encoded),
+		Status:      consts.JobStatusPending,
+		MaxAttempts: q.Config.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, option := range options {
+		option(&job)
+	}
+
+	create := db.WithContext(ctx)
+	if job.UniqueKey != nil {
+		create = create.Clauses(clause.OnConflict{DoNothing: true})
+	}
+	result := create.Create(&job)
+	if result.Error != nil {
+		return models.Job{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		var existing models.Job
+		err = db.WithContext(ctx).Where("unique_key = ?", *job.UniqueKey).Take(&existing).Error
+		return existing, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Start launches the worker pool and the scheduler. They stop once ctx is
+// cancelled; Wait blocks until they have.
+func (q *Queue) Start(ctx context.Context) {
+	log.Printf("Job queue started: workers=%d", q.Config.Workers)
+
+	for i := 0; i < q.Config.Workers; i++ {
+		q.waitGroup.Add(1)
+		go q.work(ctx)
+	}
+
+	q.waitGroup.Add(1)
+	go q.schedule(ctx)
+}
+
+// Locker exposes the queue's locker for other cluster wide singletons.
+func (q *Queue) Locker() Locker {
+	return q.locker
+}
+
+func (q *Queue) Wait() {
+	q.waitGroup.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.waitGroup.Done()
+
+	for {
+		processed, err := q.RunNext(ctx)
+		if err != nil {
+			log.Printf("Job queue error: %v", err)
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.Config.PollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs a single due job. It reports whether a job was run.
+func (q *Queue) RunNext(ctx context.Context) (bool, error) {
+	job, claimed, err := q.claim(ctx)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	q.mutex.RLock()
+	handler, ok := q.handlers[job.Type]
+	singleton := q.singletons[job.Type]
+	q.mutex.RUnlock()
+
+	if singleton {
+		lock, acquired, err := q.locker.TryLock(ctx, "zenstore:jobs:"+job.Type)
+		if err != nil {
+			return true, q.release(ctx, job, err)
+		}
+		if !acquired {
+			log.Printf("Job %s (%s) skipped, already running on another worker", job.ID, job.Type)
+			return true, q.finish(ctx, job, "skipped: already running on another worker")
+		}
+		defer lock.Unlock()
+	}
+
+	if !ok {
+		err = fmt.Errorf("no handler registered for job type %s", job.Type)
+	} else {
+		err = q.execute(ctx, handler, job)
+	}
+
+	if err == nil {
+		return true, q.finish(ctx, job, "")
+	}
+
+	log.Printf("Job %s (%s) attempt %d/%d failed: %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, err)
+	return true, q.release(ctx, job, err)
+}
+
+func (q *Queue) finish(ctx context.Context, job models.Job, note string) error {
+	return q.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("id = ?", job.ID).
+		Updates(map[string]interface{}{"status": consts.JobStatusDone, "locked_at": nil, "locked_by": "", "last_error": note}).
+		Error
+}
+
+// release hands a failed job back for a later retry, or buries it once it
+// has used up its attempts.
+func (q *Queue) release(ctx context.Context, job models.Job, cause error) error {
+	if job.Attempts >= job.MaxAttempts {
+		return q.bury(ctx, job, cause)
+	}
+
+	return q.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("id = ?", job.ID).
+		Updates(map[string]interface{}{
+			"status":     consts.JobStatusPending,
+			"run_at":     time.Now().Add(q.backoff(job.Attempts)),
+			"locked_at":  nil,
+			"locked_by":  "",
+			"last_error": cause.Error(),
+		}).Error
+}
+
+func (q *Queue) execute(ctx context.Context, handler Handler, job models.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+
+	return handler(ctx, []byte(job.Payload))
+}
+
+// claim picks the oldest due job and marks it running. The conditional update
+// makes sure only one worker, on any replica, wins a given job.
+func (q *Queue) claim(ctx context.Context) (models.Job, bool, error) {
+	now := time.Now()
+
+	err := q.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", consts.JobStatusRunning, now.Add(-q.Config.LockTimeout)).
+		Updates(map[string]interface{}{"status": consts.JobStatusPending, "locked_at": nil, "locked_by": ""}).
+		Error
+	if err != nil {
+		return models.Job{}, false, err
+	}
+
+	var candidates []models.Job
+	err = q.DB.WithContext(ctx).
+		Where("status = ? AND run_at <= ?", consts.JobStatusPending, now).
+		Order("run_at").
+		Limit(q.Config.Workers).
+		Find(&candidates).Error
+	if err != nil {
+		return models.Job{}, false, err
+	}
+
+	for _, job := range candidates {
+		result := q.DB.WithContext(ctx).Model(&models.Job{}).
+			Where("id = ? AND status = ?", job.ID, consts.JobStatusPending).
+			Updates(map[string]interface{}{
+				"status":    consts.JobStatusRunning,
+				"attempts":  gorm.Expr("attempts + 1"),
+				"locked_at": now,
+				"locked_by": q.workerId,
+			})
+		if result.Error != nil {
+			return models.Job{}, false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Attempts++
+			job.Status = consts.JobStatusRunning
+			return job, true, nil
+		}
+	}
+
+	return models.Job{}, false, nil
+}
+
+// bury moves a job that exhausted its attempts to the dead letter table. The
+// stored row is what gets buried, since it may have been scrubbed or removed
+// while the job ran.
+func (q *Queue) bury(ctx context.Context, job models.Job, cause error) error {
+	return q.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored models.Job
+		err := tx.Where("id = ?", job.ID).Take(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		deadJob := models.DeadJob{
+			ID:        stored.ID,
+			Type:      stored.Type,
+			Payload:   stored.Payload,
+			UserID:    stored.UserID,
+			Attempts:  job.Attempts,
+			LastError: cause.Error(),
+			FailedAt:  time.Now(),
+			CreatedAt: stored.CreatedAt,
+		}
+		if err := tx.Create(&deadJob).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", job.ID).Delete(&models.Job{}).Error
+	})
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.Config.BaseBackoff
+	for i := 1; i < attempts && backoff < q.Config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > q.Config.MaxBackoff {
+		backoff = q.Config.MaxBackoff
+	}
+	return backoff
+}
+
+// schedule enqueues due scheduled jobs while this replica is the scheduler
+// leader. Followers keep trying to take the lock, so leadership moves on its
+// own when the leader stops or dies.
+func (q *Queue) schedule(ctx context.Context) {
+	defer q.waitGroup.Done()
+
+	q.mutex.RLock()
+	schedules := append([]scheduledJob(nil), q.schedules...)
+	q.mutex.RUnlock()
+
+	if len(schedules) == 0 {
+		return
+	}
+
+	var leadership Lock
+	var lastAttempt time.Time
+	next := make([]time.Time, len(schedules))
+	defer func() {
+		if leadership != nil {
+			leadership.Unlock()
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		if leadership != nil {
+			select {
+			case <-leadership.Lost():
+				log.Printf("Job scheduler lost leadership: %s", q.workerId)
+				leadership.Unlock()
+				leadership = nil
+			default:
+			}
+		}
+
+		if leadership == nil {
+			if now.Sub(lastAttempt) < q.Config.PollInterval {
+				continue
+			}
+			lastAttempt = now
+
+			lock, acquired, err := q.locker.TryLock(ctx, schedulerLockName)
+			if err != nil {
+				log.Printf("Job scheduler failed to take leadership: %v", err)
+				continue
+			}
+			if !acquired {
+				continue
+			}
+
+			log.Printf("Job scheduler became leader: %s", q.workerId)
+			leadership = lock
+			for i, scheduled := range schedules {
+				next[i] = scheduled.schedule.Next(now)
+			}
+			continue
+		}
+
+		for i, scheduled := range schedules {
+			if next[i].IsZero() || now.Before(next[i]) {
+				continue
+			}
+			next[i] = scheduled.schedule.Next(now)
+
+			if _, err := q.Enqueue(ctx, q.DB, scheduled.jobType, scheduled.payload); err != nil {
+				log.Printf("Scheduled job %s failed to enqueue: %v", scheduled.name, err)
+			}
+		}
+	}
+}
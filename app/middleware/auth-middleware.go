@@ -8,14 +8,49 @@ import (
 
 	"zen-test/app/auth"
 	"zen-test/app/exceptions"
+	"zen-test/app/web/models"
+
+	"github.com/gorilla/websocket"
 )
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey    contextKey = "user"
+	sessionContextKey contextKey = "session"
+	apiKeyContextKey  contextKey = "apiKey"
+)
+
+// WebSocketBearerProtocol is the subprotocol a browser offers, followed by its
+// access token, to authenticate a WebSocket handshake, which cannot carry an
+// Authorization header: Sec-WebSocket-Protocol: bearer, <token>.
+const WebSocketBearerProtocol = "bearer"
+
+// SessionValidator tells whether the login session a token was issued for is
+// still active.
+type SessionValidator interface {
+	SessionActive(ctx context.Context, sessionId string, userId string) bool
+}
+
+// APIKeyAuthenticator finds the API key a request presented in X-API-Key.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, bool)
+}
+
+// AccountChecker tells whether a user may still use the API, which a disabled
+// account may not.
+type AccountChecker interface {
+	AccountActive(ctx context.Context, userId string) bool
+}
 
 func isPublicRoute(r *http.Request) bool {
-	return (r.URL.Path == "/users/login" || r.URL.Path == "/users/signup") && r.Method == "POST"
+	switch r.URL.Path {
+	case "/users/login", "/users/login/2fa", "/users/signup", "/users/refresh-token", "/users/password/forgot", "/users/password/reset", "/users/email/verify":
+		return r.Method == "POST"
+	case "/.well-known/jwks.json":
+		return r.Method == "GET"
+	}
+	return strings.HasPrefix(r.URL.Path, "/auth/oidc/") && r.Method == "GET"
 }
 
 func RedirectSwagger(next http.Handler) http.Handler {
@@ -28,34 +63,80 @@ func RedirectSwagger(next http.Handler) http.Handler {
 	})
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if isPublicRoute(r) || strings.HasPrefix(r.URL.Path, "/swagger/") {
-			next.ServeHTTP(w, r)
-			return
-		}
+func AuthMiddleware(tokens auth.TokenVerifier, sessions SessionValidator, apiKeys APIKeyAuthenticator, accounts AccountChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPublicRoute(r) || strings.HasPrefix(r.URL.Path, "/swagger/") {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
-			return
-		}
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				apiKey, ok := apiKeys.AuthenticateAPIKey(r.Context(), key)
+				if !ok {
+					http.Error(w, "Invalid API key", http.StatusUnauthorized)
+					return
+				}
+				if !accounts.AccountActive(r.Context(), apiKey.UserID) {
+					http.Error(w, "Account is disabled", http.StatusForbidden)
+					return
+				}
 
-		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-		claims, err := auth.VerifyToken(tokenString)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
-			return
-		}
+				ctx := context.WithValue(r.Context(), userContextKey, apiKey.UserID)
+				ctx = context.WithValue(ctx, apiKeyContextKey, apiKey)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 
-		// if _, err := r.Cookie(helpers.UserSession); err != nil {
-		// 	http.Error(w, "Invalid user cookie", http.StatusBadRequest)
-		// 	return
-		// }
+			tokenString := bearerToken(r)
+			if tokenString == "" {
+				http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+				return
+			}
 
-		ctx := context.WithValue(r.Context(), userContextKey, claims["id"])
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			claims, err := tokens.Verify(tokenString)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
+				return
+			}
+
+			userId := claims.Subject
+			sessionId := claims.SessionID
+			if sessionId != "" && !sessions.SessionActive(r.Context(), sessionId, userId) {
+				http.Error(w, "Invalid token: session has been revoked", http.StatusUnauthorized)
+				return
+			}
+			if !accounts.AccountActive(r.Context(), userId) {
+				http.Error(w, "Account is disabled", http.StatusForbidden)
+				return
+			}
+
+			// if _, err := r.Cookie(helpers.UserSession); err != nil {
+			// 	http.Error(w, "Invalid user cookie", http.StatusBadRequest)
+			// 	return
+			// }
+
+			ctx := context.WithValue(r.Context(), userContextKey, userId)
+			ctx = context.WithValue(ctx, sessionContextKey, sessionId)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// bearerToken reads the access token from the Authorization header or, on a
+// WebSocket handshake, from the subprotocols.
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		protocols := websocket.Subprotocols(r)
+		if len(protocols) == 2 && protocols[0] == WebSocketBearerProtocol {
+			return protocols[1]
+		}
+	}
+	return ""
 }
 
 func RecoverMiddleware(next http.Handler) http.Handler {
@@ -76,3 +157,16 @@ func GetUserID(r *http.Request) string {
 	}
 	return ""
 }
+
+// GetAPIKey returns the API key the request was made with, if it was.
+func GetAPIKey(r *http.Request) (models.APIKey, bool) {
+	apiKey, ok := r.Context().Value(apiKeyContextKey).(models.APIKey)
+	return apiKey, ok
+}
+
+func GetSessionID(r *http.Request) string {
+	if sessionID, ok := r.Context().Value(sessionContextKey).(string); ok {
+		return sessionID
+	}
+	return ""
+}
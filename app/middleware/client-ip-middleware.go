@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"zen-test/app/helpers"
+
+	"github.com/gorilla/mux"
+)
+
+const clientIPContextKey contextKey = "clientIP"
+
+// ResolveClientIP works out the client address of every request once, taking
+// X-Forwarded-For only from trustedProxies. See helpers.ClientIP.
+func ResolveClientIP(trustedProxies []*net.IPNet) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPContextKey, helpers.ClientIP(r, trustedProxies))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetClientIP returns the client address resolved by ResolveClientIP, or the
+// peer address when the middleware is not in use.
+func GetClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip
+	}
+	return helpers.ClientIP(r, nil)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"zen-test/app/auth"
+	"zen-test/app/exceptions"
+
+	"github.com/gorilla/mux"
+)
+
+// ScopeResolver finds the scopes the role of a user grants.
+type ScopeResolver interface {
+	UserScopes(ctx context.Context, userId string) []string
+}
+
+// RequireScopes refuses requests to a route of routeScopes unless the user,
+// and the API key when one was used, were granted the scope it names. Routes
+// are keyed like in RequireVerifiedEmail. API keys may only be used on routes
+// that name a scope. The scopes granted are put in the request context for
+// services to check with auth.HasScope.
+func RequireScopes(routeScopes map[string]string, resolver ScopeResolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId := GetUserID(r)
+			route := mux.CurrentRoute(r)
+			if userId == "" || route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			template, _ := route.GetPathTemplate()
+			required, scoped := routeScopes[r.Method+" "+template]
+			apiKey, withAPIKey := GetAPIKey(r)
+			if !scoped {
+				if withAPIKey {
+					panic(exceptions.NewForbiddenError("API keys cannot be used on this endpoint"))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			granted := resolver.UserScopes(r.Context(), userId)
+			if withAPIKey {
+				granted = auth.Intersect(granted, strings.Split(apiKey.Scopes, ","))
+			}
+
+			if !auth.Satisfies(granted, required) {
+				panic(exceptions.NewForbiddenError("missing scope " + required))
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithScopes(r.Context(), granted)))
+		})
+	}
+}
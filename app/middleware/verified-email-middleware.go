@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"zen-test/app/exceptions"
+
+	"github.com/gorilla/mux"
+)
+
+// EmailVerifier tells whether a user has verified their email address.
+type EmailVerifier interface {
+	EmailVerified(ctx context.Context, userId string) bool
+}
+
+// RequireVerifiedEmail rejects users whose email is not verified on the routes
+// of the policy. Each route is written as its method and the path template it
+// was registered with, such as "POST /orders" or "POST /orders/{orderId}/cancel".
+func RequireVerifiedEmail(policy []string, verifier EmailVerifier) mux.MiddlewareFunc {
+	routes := make(map[string]bool)
+	for _, route := range policy {
+		fields := strings.Fields(route)
+		if len(fields) == 2 {
+			routes[strings.ToUpper(fields[0])+" "+fields[1]] = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			template, err := route.GetPathTemplate()
+			if err != nil || !routes[r.Method+" "+template] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userId := GetUserID(r)
+			if userId == "" || !verifier.EmailVerified(r.Context(), userId) {
+				panic(exceptions.NewForbiddenError("verify your email address to use this endpoint"))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileNotifier writes every message as an .eml file into Dir, so mail can be
+// inspected locally without an SMTP server.
+type FileNotifier struct {
+	Dir  string
+	From string
+}
+
+func NewFileNotifier(dir string, from string) Notifier {
+	return &FileNotifier{Dir: dir, From: from}
+}
+
+func (n *FileNotifier) Send(ctx context.Context, message Message) error {
+	err := os.MkdirAll(n.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), message.To)
+	return os.WriteFile(filepath.Join(n.Dir, name), mimeMessage(n.From, message), 0o644)
+}
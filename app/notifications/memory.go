@@ -0,0 +1,31 @@
+package notifications
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryNotifier keeps sent messages in memory for tests.
+type MemoryNotifier struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (n *MemoryNotifier) Send(ctx context.Context, message Message) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func (n *MemoryNotifier) Messages() []Message {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return append([]Message(nil), n.messages...)
+}
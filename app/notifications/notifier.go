@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier hands a rendered message to a delivery channel.
+type Notifier interface {
+	Send(ctx context.Context, message Message) error
+}
+
+type Config struct {
+	// Driver is one of "smtp", "file" or "memory".
+	Driver string
+	From   string
+	Dir    string
+	SMTP   SMTPConfig
+}
+
+func NewNotifier(config Config) (Notifier, error) {
+	switch config.Driver {
+	case "smtp":
+		return NewSMTPNotifier(config.SMTP, config.From), nil
+	case "file":
+		return NewFileNotifier(config.Dir, config.From), nil
+	case "memory":
+		return NewMemoryNotifier(), nil
+	}
+	return nil, fmt.Errorf("unknown notifier driver %q", config.Driver)
+}
+
+// mimeMessage encodes message as a multipart/alternative email with a text
+// and an HTML part.
+func mimeMessage(from string, message Message) []byte {
+	boundary := fmt.Sprintf("zenstore-%d", time.Now().UnixNano())
+
+	var builder strings.Builder
+	builder.WriteString("From: " + from + "\r\n")
+	builder.WriteString("To: " + message.To + "\r\n")
+	builder.WriteString("Subject: " + encodeHeader(message.Subject) + "\r\n")
+	builder.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: multipart/alternative; boundary=" + boundary + "\r\n\r\n")
+
+	builder.WriteString("--" + boundary + "\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	builder.WriteString(message.Text + "\r\n")
+
+	builder.WriteString("--" + boundary + "\r\n")
+	builder.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	builder.WriteString(message.HTML + "\r\n")
+
+	builder.WriteString("--" + boundary + "--\r\n")
+	return []byte(builder.String())
+}
+
+// encodeHeader keeps text rendered from user data, like a name in the
+// subject, on one header line and encodes anything beyond ASCII.
+func encodeHeader(value string) string {
+	value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+	return mime.QEncoding.Encode("utf-8", value)
+}
@@ -0,0 +1,33 @@
+package notifications
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+type SMTPNotifier struct {
+	Config SMTPConfig
+	From   string
+}
+
+func NewSMTPNotifier(config SMTPConfig, from string) Notifier {
+	return &SMTPNotifier{Config: config, From: from}
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, message Message) error {
+	var auth smtp.Auth
+	if n.Config.Username != "" {
+		auth = smtp.PlainAuth("", n.Config.Username, n.Config.Password, n.Config.Host)
+	}
+
+	address := net.JoinHostPort(n.Config.Host, n.Config.Port)
+	return smtp.SendMail(address, auth, n.From, []string{message.To}, mimeMessage(n.From, message))
+}
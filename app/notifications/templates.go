@@ -0,0 +1,116 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+// DefaultLocale is used when a user has no locale or no template exists in
+// theirs.
+const DefaultLocale = "en"
+
+// Templates of emails that are not sent for a domain event.
+const (
+	TemplatePasswordReset     = "user.password_reset"
+	TemplateEmailVerification = "user.email_verification"
+	TemplateEmailChange       = "user.email_change"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Renderer turns a template name, such as an event type, into a message. Every
+// template has a text file defining "subject" and "text", and an HTML file
+// defining "html", per locale: templates/<locale>/<name>.txt and .html.
+type Renderer struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+var templateFuncs = map[string]interface{}{
+	"money": func(amount float64) string {
+		return fmt.Sprintf("%.2f", amount)
+	},
+}
+
+func NewRenderer() (*Renderer, error) {
+	renderer := &Renderer{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+
+	paths, err := fs.Glob(templateFiles, "templates/*/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		parsed, err := texttemplate.New("").Funcs(templateFuncs).ParseFS(templateFiles, path)
+		if err != nil {
+			return nil, err
+		}
+		renderer.text[templateKey(path)] = parsed
+	}
+
+	paths, err = fs.Glob(templateFiles, "templates/*/*.html")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		parsed, err := htmltemplate.New("").Funcs(templateFuncs).ParseFS(templateFiles, path)
+		if err != nil {
+			return nil, err
+		}
+		renderer.html[templateKey(path)] = parsed
+	}
+
+	return renderer, nil
+}
+
+func (r *Renderer) Has(name string) bool {
+	_, ok := r.text[DefaultLocale+"/"+name]
+	return ok
+}
+
+func (r *Renderer) Render(name string, locale string, data interface{}) (Message, error) {
+	key := locale + "/" + name
+	if _, ok := r.text[key]; !ok {
+		key = DefaultLocale + "/" + name
+	}
+
+	textTemplate, ok := r.text[key]
+	if !ok {
+		return Message{}, fmt.Errorf("no template for %q", name)
+	}
+	htmlTemplate, ok := r.html[key]
+	if !ok {
+		return Message{}, fmt.Errorf("no html template for %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplate.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplate.ExecuteTemplate(&html, "html", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		HTML:    strings.TrimSpace(html.String()),
+	}, nil
+}
+
+// templateKey maps templates/en/order.paid.txt to en/order.paid.
+func templateKey(path string) string {
+	key := strings.TrimPrefix(path, "templates/")
+	return key[:strings.LastIndex(key, ".")]
+}
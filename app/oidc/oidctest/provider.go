@@ -0,0 +1,223 @@
+// Package oidctest runs a tiny OpenID Connect provider for tests, so the
+// login flow can be exercised without a real identity provider.
+package oidctest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"zen-test/app/auth"
+	"zen-test/app/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type grant struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	identity      oidc.Identity
+}
+
+// Provider approves every authorization request as the identity given to
+// SignInAs, checking the client credentials, redirect URI and PKCE verifier
+// the way a real provider does.
+type Provider struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mutex    sync.Mutex
+	identity oidc.Identity
+	grants   map[string]grant
+	key      auth.SigningKey
+	keys     *auth.KeySet
+
+	unknownKey   bool
+	jwksRequests int
+}
+
+func NewProvider(clientID string, clientSecret string) *Provider {
+	key, err := auth.GenerateSigningKey(auth.AlgorithmEdDSA, time.Now().Add(-time.Minute))
+	if err != nil {
+		panic(err)
+	}
+
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		grants:       make(map[string]grant),
+		key:          key,
+		keys:         auth.NewKeySet(key),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Config returns the client configuration for the provider.
+func (p *Provider) Config(redirectURL string) oidc.Config {
+	return oidc.Config{
+		Issuer:       p.URL,
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+	}
+}
+
+// SignInAs sets who the next authorization requests log in as.
+func (p *Provider) SignInAs(identity oidc.Identity) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.identity = identity
+}
+
+// SignWithUnknownKey makes the next ID tokens name a key the provider does
+// not publish, as a forged token would.
+func (p *Provider) SignWithUnknownKey(unknown bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.unknownKey = unknown
+}
+
+// JWKSRequests is how many times the keys have been fetched.
+func (p *Provider) JWKSRequests() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.jwksRequests
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != p.ClientID ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	p.mutex.Lock()
+	p.grants[code] = grant{
+		redirectURI:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		identity:      p.identity,
+	}
+	p.mutex.Unlock()
+
+	callback := redirect.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirect.RawQuery = callback.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	// Client credentials in basic auth are form encoded (RFC 6749 2.3.1).
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	p.mutex.Lock()
+	grant, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mutex.Unlock()
+
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != grant.redirectURI ||
+		oidc.CodeChallenge(r.PostFormValue("code_verifier")) != grant.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"iss":            p.URL,
+		"sub":            grant.identity.Subject,
+		"aud":            p.ClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.identity.Email,
+		"email_verified": grant.identity.EmailVerified,
+		"name":           grant.identity.Name,
+	})
+	idToken.Header["kid"] = p.key.ID
+	p.mutex.Lock()
+	if p.unknownKey {
+		idToken.Header["kid"] = "unknown-" + randomString()
+	}
+	p.mutex.Unlock()
+
+	signed, err := idToken.SignedString(p.key.PrivateKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mutex.Lock()
+	p.jwksRequests++
+	p.mutex.Unlock()
+
+	writeJSON(w, http.StatusOK, p.keys.JWKS(time.Now()))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random)
+}
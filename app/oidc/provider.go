@@ -0,0 +1,279 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"zen-test/app/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config is what the application registered with an identity provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity is who the provider says logged in.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type idTokenClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// keyRefreshInterval is the least time between two fetches of the keys of a
+// provider, so tokens naming made up keys cannot have it fetched on every
+// request.
+const keyRefreshInterval = time.Minute
+
+// Provider logs users in with an OpenID Connect provider through the
+// authorization code flow with PKCE. The provider is discovered on first use
+// and its keys are fetched again when a token names a key not seen yet, at
+// most once every keyRefreshInterval.
+type Provider struct {
+	Config     Config
+	HTTPClient *http.Client
+
+	mutex         sync.Mutex
+	metadata      *metadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+
+	// refreshMutex lets one request at a time fetch the keys, without
+	// holding up the requests that find their key cached.
+	refreshMutex sync.Mutex
+}
+
+func NewProvider(config Config, httpClient *http.Client) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		Config:     config,
+		HTTPClient: httpClient,
+	}
+}
+
+// AuthCodeURL returns the page of the provider the user is sent to.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	link, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.Config.ClientID)
+	query.Set("redirect_uri", p.Config.RedirectURL)
+	query.Set("scope", strings.Join(p.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// Authenticate redeems the code the provider sent the user back with and
+// verifies the ID token it is answered with.
+func (p *Provider) Authenticate(ctx context.Context, code string, codeVerifier string, nonce string) (Identity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.do(request, &tokens); err != nil {
+		return Identity{}, fmt.Errorf("token request failed: %v", err)
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+
+	return p.verify(ctx, metadata, tokens.IDToken, nonce)
+}
+
+func (p *Provider) verify(ctx context.Context, metadata *metadata, idToken string, nonce string) (Identity, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	var claims idTokenClaims
+	_, err := parser.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, metadata, kid)
+	})
+	if err != nil {
+		return Identity{}, err
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New("id token has no subject")
+	}
+	if claims.Nonce != nonce {
+		return Identity{}, errors.New("id token nonce does not match")
+	}
+
+	return Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.Config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovered metadata
+	if err := p.do(request, &discovered); err != nil {
+		return nil, fmt.Errorf("discovery of %s failed: %v", p.Config.Issuer, err)
+	}
+	if discovered.Issuer != p.Config.Issuer {
+		return nil, fmt.Errorf("provider claims to be %s, not %s", discovered.Issuer, p.Config.Issuer)
+	}
+
+	p.metadata = &discovered
+	return p.metadata, nil
+}
+
+func (p *Provider) key(ctx context.Context, metadata *metadata, kid string) (crypto.PublicKey, error) {
+	if key, ok, _ := p.cachedKey(kid); ok {
+		return key, nil
+	}
+
+	p.refreshMutex.Lock()
+	defer p.refreshMutex.Unlock()
+
+	// The keys may have been fetched while this request waited its turn.
+	key, ok, fetchedAt := p.cachedKey(kid)
+	if ok {
+		return key, nil
+	}
+	if time.Since(fetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx, metadata)
+
+	// A failed fetch keeps the keys already known and waits out the
+	// interval like a successful one.
+	p.mutex.Lock()
+	p.keysFetchedAt = time.Now()
+	if err == nil {
+		p.keys = keys
+	}
+	p.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) cachedKey(kid string) (crypto.PublicKey, bool, time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key, ok := p.keys[kid]
+	return key, ok, p.keysFetchedAt
+}
+
+func (p *Provider) fetchKeys(ctx context.Context, metadata *metadata) (map[string]crypto.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks auth.JWKS
+	if err := p.do(request, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching keys failed: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	return keys, nil
+}
+
+func (p *Provider) do(request *http.Request, target interface{}) error {
+	response, err := p.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", request.URL.Host, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
@@ -0,0 +1,41 @@
+package payments
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+type RefundRequest struct {
+	OrderID string
+	Amount  float64
+	Reason  string
+}
+
+type RefundResult struct {
+	Reference string
+}
+
+// Gateway is the boundary between order handling and whichever payment
+// provider actually moves the money.
+type Gateway interface {
+	Refund(ctx context.Context, request RefundRequest) (RefundResult, error)
+}
+
+type ManualGateway struct {
+}
+
+// NewManualGateway returns a gateway for stores that settle refunds outside
+// the application; it only hands out a reference for bookkeeping.
+func NewManualGateway() Gateway {
+	return &ManualGateway{}
+}
+
+func (g *ManualGateway) Refund(ctx context.Context, request RefundRequest) (RefundResult, error) {
+	if request.Amount <= 0 {
+		return RefundResult{}, errors.New("refund amount must be greater than zero")
+	}
+
+	return RefundResult{Reference: "manual-" + uuid.New().String()}, nil
+}
@@ -0,0 +1,221 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/web/models"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	TopicOrders    = "orders"
+	TopicInventory = "inventory"
+
+	MessageEvent    = "event"
+	MessageLowStock = "inventory.low_stock"
+	MessageTopics   = "topics"
+	MessageError    = "error"
+)
+
+type DashboardConfig struct {
+	// LowStockThreshold raises a low stock alert when a stock change leaves a
+	// product with this many units or fewer.
+	LowStockThreshold uint32
+	PingInterval      time.Duration
+	WriteTimeout      time.Duration
+}
+
+// DashboardCommand is sent by a client to choose its topics, e.g.
+// {"action":"subscribe","topics":["orders"]}.
+type DashboardCommand struct {
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
+}
+
+type DashboardMessage struct {
+	Type   string        `json:"type"`
+	Topic  string        `json:"topic,omitempty"`
+	Topics []string      `json:"topics,omitempty"`
+	Event  *events.Event `json:"event,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+type dashboardClient struct {
+	conn    *websocket.Conn
+	config  DashboardConfig
+	topics  map[string]bool
+	replies chan dashboardReply
+}
+
+// dashboardReply carries the answer to a command and, when the command
+// changed them, the client's new topics.
+type dashboardReply struct {
+	message DashboardMessage
+	topics  map[string]bool
+}
+
+// ServeDashboard feeds order and inventory events to a staff connection until
+// either side closes it. A client that cannot keep up is disconnected by the
+// hub and should reconnect.
+func ServeDashboard(conn *websocket.Conn, hub *Hub, config DashboardConfig) {
+	defer conn.Close()
+
+	subscription := hub.Subscribe(func(event events.Event) bool {
+		return topicOf(event) != ""
+	})
+	defer subscription.Close()
+
+	client := &dashboardClient{
+		conn:    conn,
+		config:  config,
+		topics:  make(map[string]bool),
+		replies: make(chan dashboardReply, 8),
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go client.read(done, stopped)
+	client.write(subscription, done)
+	close(stopped)
+}
+
+// read handles commands from the client. Topic changes travel to the writer
+// with their reply, so events are filtered by the new topics from the moment
+// the client is told about them.
+func (c *dashboardClient) read(done chan struct{}, stopped chan struct{}) {
+	defer close(done)
+
+	topics := make(map[string]bool)
+	c.conn.SetReadLimit(4096)
+	c.conn.SetReadDeadline(time.Now().Add(2 * c.config.PingInterval))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(2 * c.config.PingInterval))
+	})
+
+	for {
+		var command DashboardCommand
+		err := c.conn.ReadJSON(&command)
+		if err != nil {
+			return
+		}
+
+		message, changed := applyCommand(topics, command)
+		reply := dashboardReply{message: message}
+		if changed {
+			reply.topics = make(map[string]bool, len(topics))
+			for topic := range topics {
+				reply.topics[topic] = true
+			}
+		}
+
+		select {
+		case c.replies <- reply:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+func (c *dashboardClient) write(subscription *Subscription, done chan struct{}) {
+	ping := time.NewTicker(c.config.PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case reply := <-c.replies:
+			if reply.topics != nil {
+				c.topics = reply.topics
+			}
+			if c.send(reply.message) != nil {
+				return
+			}
+		case event, open := <-subscription.Events():
+			if !open {
+				c.conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "event feed closed, reconnect"),
+					time.Now().Add(c.config.WriteTimeout))
+				return
+			}
+			for _, message := range c.messagesFor(event) {
+				if c.send(message) != nil {
+					return
+				}
+			}
+		case <-ping.C:
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.WriteTimeout))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *dashboardClient) send(message DashboardMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	return c.conn.WriteJSON(message)
+}
+
+func (c *dashboardClient) messagesFor(event events.Event) []DashboardMessage {
+	topic := topicOf(event)
+	if !c.topics[topic] {
+		return nil
+	}
+
+	messages := []DashboardMessage{{Type: MessageEvent, Topic: topic, Event: &event}}
+
+	if event.Type == consts.EventStockChanged {
+		var payload models.StockChangedPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			log.Printf("Dashboard could not read stock event %s: %v", event.ID, err)
+		} else if payload.Delta < 0 && payload.Stock <= c.config.LowStockThreshold {
+			messages = append(messages, DashboardMessage{Type: MessageLowStock, Topic: topic, Event: &event})
+		}
+	}
+	return messages
+}
+
+func applyCommand(topics map[string]bool, command DashboardCommand) (DashboardMessage, bool) {
+	for _, topic := range command.Topics {
+		if topic != TopicOrders && topic != TopicInventory {
+			return DashboardMessage{Type: MessageError, Error: "unknown topic " + topic}, false
+		}
+	}
+
+	switch command.Action {
+	case "subscribe":
+		for _, topic := range command.Topics {
+			topics[topic] = true
+		}
+	case "unsubscribe":
+		for _, topic := range command.Topics {
+			delete(topics, topic)
+		}
+	default:
+		return DashboardMessage{Type: MessageError, Error: "unknown action " + command.Action}, false
+	}
+
+	subscribed := []string{}
+	for _, topic := range []string{TopicOrders, TopicInventory} {
+		if topics[topic] {
+			subscribed = append(subscribed, topic)
+		}
+	}
+	return DashboardMessage{Type: MessageTopics, Topics: subscribed}, true
+}
+
+func topicOf(event events.Event) string {
+	switch event.Type {
+	case consts.EventOrderCreated, consts.EventOrderPaid, consts.EventOrderCancelled, consts.EventOrderShipped:
+		return TopicOrders
+	case consts.EventStockChanged, consts.EventProductUpdated:
+		return TopicInventory
+	}
+	return ""
+}
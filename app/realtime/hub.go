@@ -0,0 +1,196 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"zen-test/app/events"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"gorm.io/gorm"
+)
+
+type HubConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// BufferSize is how many events a subscriber may fall behind before it
+	// is dropped.
+	BufferSize int
+	// Overlap is how far back from the newest event seen each poll looks
+	// again, for events stamped by a replica whose clock runs behind or that
+	// committed late. It has to outlast the clock skew between replicas.
+	Overlap time.Duration
+}
+
+// Hub fans published domain events out to connected clients. Only the relay
+// leader publishes to the in-process bus, so every replica tails the outbox
+// itself instead and clients see the same events whichever replica they hit.
+type Hub struct {
+	DB               *gorm.DB
+	OutboxRepository repositories.OutboxRepository
+	Config           HubConfig
+
+	mutex       sync.Mutex
+	subscribers map[*Subscription]struct{}
+	cursor      time.Time
+	seen        map[string]time.Time
+}
+
+// Subscription receives the events matching its filter until it is closed,
+// either by its owner or by the hub when the subscriber falls behind.
+type Subscription struct {
+	hub    *Hub
+	filter func(events.Event) bool
+	events chan events.Event
+	closed bool
+}
+
+func NewHub(db *gorm.DB, outboxRepo repositories.OutboxRepository, config HubConfig) *Hub {
+	return &Hub{
+		DB:               db,
+		OutboxRepository: outboxRepo,
+		Config:           config,
+		subscribers:      make(map[*Subscription]struct{}),
+		cursor:           time.Now(),
+		seen:             make(map[string]time.Time),
+	}
+}
+
+func (h *Hub) Subscribe(filter func(events.Event) bool) *Subscription {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	subscription := &Subscription{
+		hub:    h,
+		filter: filter,
+		events: make(chan events.Event, h.Config.BufferSize),
+	}
+	h.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// Events is closed when the subscription ends.
+func (s *Subscription) Events() <-chan events.Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+
+	s.hub.remove(s)
+}
+
+// Start tails the outbox until ctx is done, then closes every subscription.
+func (h *Hub) Start(ctx context.Context) {
+	ticker := time.NewTicker(h.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return
+		case <-ticker.C:
+			if _, err := h.PollOnce(ctx); err != nil {
+				log.Printf("Realtime hub poll failed: %v", err)
+			}
+		}
+	}
+}
+
+// PollOnce broadcasts the events published since the previous poll and
+// reports how many there were. Each poll reads the overlap window again and
+// skips the events it already sent, so an event published with an earlier
+// timestamp than one already seen is still sent once.
+func (h *Hub) PollOnce(ctx context.Context) (int, error) {
+	since := h.cursor.Add(-h.Config.Overlap)
+
+	broadcast := 0
+	for {
+		outboxEvents, err := h.OutboxRepository.FindPublishedSince(ctx, h.DB, since, models.OutboxFilter{}, h.Config.BatchSize)
+		if err != nil {
+			return broadcast, err
+		}
+
+		for _, outboxEvent := range outboxEvents {
+			if _, ok := h.seen[outboxEvent.ID]; ok {
+				continue
+			}
+
+			h.seen[outboxEvent.ID] = *outboxEvent.PublishedAt
+			if outboxEvent.PublishedAt.After(h.cursor) {
+				h.cursor = *outboxEvent.PublishedAt
+			}
+
+			h.Broadcast(events.FromOutbox(outboxEvent))
+			broadcast++
+		}
+
+		// A full batch may have left events behind it; read on from the last
+		// one, unless the whole batch shares its timestamp.
+		if len(outboxEvents) < h.Config.BatchSize || !outboxEvents[len(outboxEvents)-1].PublishedAt.After(since) {
+			break
+		}
+		since = *outboxEvents[len(outboxEvents)-1].PublishedAt
+	}
+
+	// What fell out of the window is not read again.
+	for id, publishedAt := range h.seen {
+		if publishedAt.Before(h.cursor.Add(-h.Config.Overlap)) {
+			delete(h.seen, id)
+		}
+	}
+	return broadcast, nil
+}
+
+// Broadcast hands event to every matching subscriber without blocking. A
+// subscriber whose buffer is full is dropped rather than slowing down the
+// others.
+func (h *Hub) Broadcast(event events.Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for subscription := range h.subscribers {
+		if !subscription.filter(event) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			h.remove(subscription)
+		}
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for subscription := range h.subscribers {
+		h.remove(subscription)
+	}
+}
+
+func (h *Hub) remove(subscription *Subscription) {
+	if subscription.closed {
+		return
+	}
+
+	subscription.closed = true
+	delete(h.subscribers, subscription)
+	close(subscription.events)
+}
+
+// Matching returns a subscription filter equivalent to an outbox filter.
+func Matching(filter models.OutboxFilter) func(events.Event) bool {
+	return func(event events.Event) bool {
+		return (filter.AggregateType == "" || event.AggregateType == filter.AggregateType) &&
+			(filter.AggregateID == "" || event.AggregateID == filter.AggregateID) &&
+			(filter.UserID == "" || event.UserID == filter.UserID)
+	}
+}
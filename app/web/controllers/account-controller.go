@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+)
+
+type AccountController interface {
+	Export(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+}
+
+type AccountControllerImpl struct {
+	AccountService services.AccountService
+}
+
+func NewAccountController(accountService services.AccountService) AccountController {
+	return &AccountControllerImpl{
+		AccountService: accountService,
+	}
+}
+
+// Export Account godoc
+// @Summary Export my data
+// @Description Get a copy of the profile, addresses and orders of the authenticated user, as JSON or as a ZIP archive of JSON files
+// @Tags User
+// @Produce json
+// @Produce application/zip
+// @Param format query string false "Format" Enums(json, zip)
+// @Success 200 {object} web.WebResponse{data=models.UserExport}
+// @Failure 400 {object} web.WebResponse
+// @Router /users/me/export [get]
+// @Security BearerAuth
+func (c *AccountControllerImpl) Export(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		export := c.AccountService.Export(r.Context(), userId)
+		webResponse := web.WebResponse{
+			Code:   http.StatusOK,
+			Status: "Ok",
+			Data:   export,
+		}
+		helpers.WriteResponseBody(w, webResponse)
+	case "zip":
+		archive := c.AccountService.ExportArchive(r.Context(), userId)
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="export.zip"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+		w.Write(archive)
+	default:
+		panic(exceptions.NewBadRequestError("format must be json or zip"))
+	}
+}
+
+// Delete Account godoc
+// @Summary Delete my account
+// @Description Delete the account of the authenticated user once the password is confirmed. Personal data is removed and orders are anonymized; their amounts are kept. Users who only log in with an identity provider set a password through the password reset first.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.AccountDelete true "Password"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/me [delete]
+// @Security BearerAuth
+func (c *AccountControllerImpl) Delete(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	request := models.AccountDelete{}
+	helpers.ToRequestBody(r, &request)
+
+	c.AccountService.Delete(r.Context(), request, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AddressController interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	FindAll(w http.ResponseWriter, r *http.Request)
+	FindById(w http.ResponseWriter, r *http.Request)
+	Update(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	SetDefault(w http.ResponseWriter, r *http.Request)
+}
+
+type AddressControllerImpl struct {
+	AddressService services.AddressService
+}
+
+func NewAddressController(addressService services.AddressService) AddressController {
+	return &AddressControllerImpl{
+		AddressService: addressService,
+	}
+}
+
+// Create Address godoc
+// @Summary Add an address
+// @Description Add a shipping address to the address book of the authenticated user. The first address becomes the default.
+// @Tags Address
+// @Accept json
+// @Produce json
+// @Param request body models.AddressCreateUpdate true "Address"
+// @Success 200 {object} web.WebResponse{data=models.AddressResponse}
+// @Failure 400 {object} web.WebResponse
+// @Router /users/me/addresses [post]
+// @Security BearerAuth
+func (c *AddressControllerImpl) Create(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	request := models.AddressCreateUpdate{}
+	helpers.ToRequestBody(r, &request)
+
+	addressResponse := c.AddressService.Create(r.Context(), request, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   addressResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindAll Address godoc
+// @Summary List addresses
+// @Description List the address book of the authenticated user, the default address first
+// @Tags Address
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=[]models.AddressResponse}
+// @Router /users/me/addresses [get]
+// @Security BearerAuth
+func (c *AddressControllerImpl) FindAll(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	addressResponses := c.AddressService.FindAll(r.Context(), userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   addressResponses,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindById Address godoc
+// @Summary Get an address
+// @Description Get an address from the address book of the authenticated user
+// @Tags Address
+// @Produce json
+// @Param addressId path string true "Address ID"
+// @Success 200 {object} web.WebResponse{data=models.AddressResponse}
+// @Failure 404 {object} web.WebResponse
+// @Router /users/me/addresses/{addressId} [get]
+// @Security BearerAuth
+func (c *AddressControllerImpl) FindById(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+	addressId := mux.Vars(r)["addressId"]
+
+	addressResponse := c.AddressService.FindById(r.Context(), addressId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   addressResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Update Address godoc
+// @Summary Update an address
+// @Description Replace an address of the authenticated user. Orders already placed keep the address they were placed with.
+// @Tags Address
+// @Accept json
+// @Produce json
+// @Param addressId path string true "Address ID"
+// @Param request body models.AddressCreateUpdate true "Address"
+// @Success 200 {object} web.WebResponse{data=models.AddressResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /users/me/addresses/{addressId} [put]
+// @Security BearerAuth
+func (c *AddressControllerImpl) Update(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+	addressId := mux.Vars(r)["addressId"]
+
+	request := models.AddressCreateUpdate{}
+	helpers.ToRequestBody(r, &request)
+
+	addressResponse := c.AddressService.Update(r.Context(), addressId, request, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   addressResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Delete Address godoc
+// @Summary Delete an address
+// @Description Delete an address of the authenticated user. When it was the default, the oldest remaining address becomes the default.
+// @Tags Address
+// @Produce json
+// @Param addressId path string true "Address ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /users/me/addresses/{addressId} [delete]
+// @Security BearerAuth
+func (c *AddressControllerImpl) Delete(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+	addressId := mux.Vars(r)["addressId"]
+
+	c.AddressService.Delete(r.Context(), addressId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// SetDefault Address godoc
+// @Summary Make an address the default
+// @Description Make an address the default of the authenticated user, which orders ship to unless another is chosen
+// @Tags Address
+// @Produce json
+// @Param addressId path string true "Address ID"
+// @Success 200 {object} web.WebResponse{data=models.AddressResponse}
+// @Failure 404 {object} web.WebResponse
+// @Router /users/me/addresses/{addressId}/default [post]
+// @Security BearerAuth
+func (c *AddressControllerImpl) SetDefault(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+	addressId := mux.Vars(r)["addressId"]
+
+	addressResponse := c.AddressService.SetDefault(r.Context(), addressId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   addressResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
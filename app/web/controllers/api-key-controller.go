@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyController interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	FindAll(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+}
+
+type APIKeyControllerImpl struct {
+	APIKeyService services.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService services.APIKeyService) APIKeyController {
+	return &APIKeyControllerImpl{
+		APIKeyService: apiKeyService,
+	}
+}
+
+// Create API Key godoc
+// @Summary Create an API key
+// @Description Create an API key that acts as the authenticated user within its scopes, sent in the X-API-Key header. The key is only returned here.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.APIKeyCreate true "API key"
+// @Success 200 {object} web.WebResponse{data=models.APIKeyResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /users/me/api-keys [post]
+// @Security BearerAuth
+func (c *APIKeyControllerImpl) Create(w http.ResponseWriter, r *http.Request) {
+	userId := requireUserSession(r)
+
+	request := models.APIKeyCreate{}
+	helpers.ToRequestBody(r, &request)
+
+	apiKeyResponse := c.APIKeyService.Create(r.Context(), request, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   apiKeyResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindAll API Key godoc
+// @Summary List API keys
+// @Description List the API keys of the authenticated user that are not revoked
+// @Tags User
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=[]models.APIKeyResponse}
+// @Failure 403 {object} web.WebResponse
+// @Router /users/me/api-keys [get]
+// @Security BearerAuth
+func (c *APIKeyControllerImpl) FindAll(w http.ResponseWriter, r *http.Request) {
+	userId := requireUserSession(r)
+
+	apiKeyResponses := c.APIKeyService.FindAll(r.Context(), userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   apiKeyResponses,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Revoke API Key godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key of the authenticated user; it stops working at once
+// @Tags User
+// @Produce json
+// @Param keyId path string true "API key ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /users/me/api-keys/{keyId} [delete]
+// @Security BearerAuth
+func (c *APIKeyControllerImpl) Revoke(w http.ResponseWriter, r *http.Request) {
+	userId := requireUserSession(r)
+	keyId := mux.Vars(r)["keyId"]
+
+	c.APIKeyService.Revoke(r.Context(), keyId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// requireUserSession returns the user of the request, refusing requests made
+// with an API key so a leaked key cannot be used to mint more.
+func requireUserSession(r *http.Request) string {
+	if _, ok := middleware.GetAPIKey(r); ok {
+		panic(exceptions.NewForbiddenError("API keys cannot manage API keys"))
+	}
+	return middleware.GetUserID(r)
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"zen-test/app/middleware"
+	"zen-test/app/realtime"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/websocket"
+)
+
+type DashboardController interface {
+	Feed(w http.ResponseWriter, r *http.Request)
+}
+
+type DashboardControllerImpl struct {
+	DashboardService services.DashboardService
+	Hub              *realtime.Hub
+	Config           realtime.DashboardConfig
+	Upgrader         websocket.Upgrader
+}
+
+func NewDashboardController(dashboardService services.DashboardService, hub *realtime.Hub, config realtime.DashboardConfig) DashboardController {
+	return &DashboardControllerImpl{
+		DashboardService: dashboardService,
+		Hub:              hub,
+		Config:           config,
+		Upgrader:         websocket.Upgrader{Subprotocols: []string{middleware.WebSocketBearerProtocol}},
+	}
+}
+
+// Feed Dashboard godoc
+// @Summary Live staff dashboard feed
+// @Description WebSocket feed of order and inventory events, including low stock alerts. Send {"action":"subscribe","topics":["orders","inventory"]} to choose topics; clients that fall behind are disconnected. Browsers, which cannot set the Authorization header on the handshake, pass the access token as a subprotocol instead: new WebSocket(url, ["bearer", accessToken]). The server answers with the bearer subprotocol.
+// @Param Sec-WebSocket-Protocol header string false "bearer, followed by the access token, when there is no Authorization header"
+// @Tags Admin
+// @Success 101 {string} string "switching protocols"
+// @Failure 403 {object} web.WebResponse
+// @Router /admin/dashboard/ws [get]
+// @Security BearerAuth
+func (c *DashboardControllerImpl) Feed(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	c.DashboardService.Authorize(r.Context(), userId)
+
+	conn, err := c.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered the request.
+		log.Printf("Dashboard upgrade failed: %v", err)
+		return
+	}
+
+	realtime.ServeDashboard(conn, c.Hub, c.Config)
+}
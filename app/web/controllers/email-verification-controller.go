@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+)
+
+type EmailVerificationController interface {
+	Verify(w http.ResponseWriter, r *http.Request)
+	Resend(w http.ResponseWriter, r *http.Request)
+}
+
+type EmailVerificationControllerImpl struct {
+	EmailVerificationService services.EmailVerificationService
+}
+
+func NewEmailVerificationController(emailVerificationService services.EmailVerificationService) EmailVerificationController {
+	return &EmailVerificationControllerImpl{
+		EmailVerificationService: emailVerificationService,
+	}
+}
+
+// Verify Email godoc
+// @Summary Verify an email address
+// @Description Confirm the email address of an account with the token of the link mailed on signup
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.EmailVerify true "Verification token"
+// @Success 200 {object} web.WebResponse{data=models.UserResponse}
+// @Failure 400 {object} web.WebResponse
+// @Router /users/email/verify [post]
+func (c *EmailVerificationControllerImpl) Verify(w http.ResponseWriter, r *http.Request) {
+	request := models.EmailVerify{}
+	helpers.ToRequestBody(r, &request)
+
+	userResponse := c.EmailVerificationService.Verify(r.Context(), request)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Email verified",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, response)
+}
+
+// Resend Verification godoc
+// @Summary Resend the verification email
+// @Description Mail a new verification link to the authenticated user. Rate limited; earlier links stop working.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /users/email/verify/resend [post]
+// @Security BearerAuth
+func (c *EmailVerificationControllerImpl) Resend(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	c.EmailVerificationService.Resend(r.Context(), userId)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Verification email sent",
+	}
+	helpers.WriteResponseBody(w, response)
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/web/services"
+)
+
+type KeyController interface {
+	JWKS(w http.ResponseWriter, r *http.Request)
+}
+
+type KeyControllerImpl struct {
+	KeyService services.KeyService
+}
+
+func NewKeyController(keyService services.KeyService) KeyController {
+	return &KeyControllerImpl{
+		KeyService: keyService,
+	}
+}
+
+// JWKS Key godoc
+// @Summary Token signing keys
+// @Description Public keys that verify our access tokens, as a JSON Web Key Set. Match a token's kid header to a key; keys are published ahead of use and stay listed until every token they signed has expired.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (c *KeyControllerImpl) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	helpers.WriteResponseBody(w, c.KeyService.JWKS())
+}
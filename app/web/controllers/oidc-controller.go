@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+// oidcCookiePath keeps the state cookie to the login and callback routes.
+const oidcCookiePath = "/auth/oidc/"
+
+type OIDCController interface {
+	Login(w http.ResponseWriter, r *http.Request)
+	Callback(w http.ResponseWriter, r *http.Request)
+}
+
+type OIDCControllerImpl struct {
+	OIDCService services.OIDCService
+}
+
+func NewOIDCController(oidcService services.OIDCService) OIDCController {
+	return &OIDCControllerImpl{
+		OIDCService: oidcService,
+	}
+}
+
+// Login With Provider godoc
+// @Summary Log in with an identity provider
+// @Description Redirect to the OpenID Connect provider to log in. The provider sends the user back to the callback, which must be reached from the same browser: the login state is kept in an HttpOnly cookie and checked there.
+// @Tags User
+// @Param provider path string true "Identity provider"
+// @Success 302
+// @Failure 404 {object} web.WebResponse
+// @Router /auth/oidc/{provider}/login [get]
+func (c *OIDCControllerImpl) Login(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+
+	login := c.OIDCService.Start(r.Context(), provider)
+	helpers.SetScopedCookie(w, helpers.OIDCState, login.State, oidcCookiePath, login.ExpiresAt)
+	http.Redirect(w, r, login.AuthURL, http.StatusFound)
+}
+
+// Identity Provider Callback godoc
+// @Summary Complete a login with an identity provider
+// @Description Where the identity provider sends the user back to. Links the provider account to the user with the same verified email, or signs up a new user, and logs in like /users/login.
+// @Tags User
+// @Produce json
+// @Param provider path string true "Identity provider"
+// @Param code query string false "Authorization code"
+// @Param state query string true "Login state"
+// @Param error query string false "Error reported by the provider"
+// @Success 200 {object} web.WebResponse{data=models.UserLoginResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /auth/oidc/{provider}/callback [get]
+func (c *OIDCControllerImpl) Callback(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	query := r.URL.Query()
+
+	callback := models.OIDCCallback{
+		Code:  query.Get("code"),
+		State: query.Get("state"),
+		Error: query.Get("error"),
+	}
+	if cookie, err := r.Cookie(helpers.OIDCState); err == nil {
+		callback.BrowserState = cookie.Value
+	}
+	client := models.ClientInfo{Device: r.UserAgent(), IP: middleware.GetClientIP(r)}
+
+	// The state is spent whatever the outcome.
+	helpers.SetScopedCookie(w, helpers.OIDCState, "", oidcCookiePath, time.Unix(0, 0))
+
+	userResponse := c.OIDCService.Callback(r.Context(), provider, callback, client)
+	if userResponse.RefreshToken != "" {
+		helpers.SetCookie(w, r, helpers.RefreshToken, userResponse.RefreshToken)
+	}
+
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
@@ -8,11 +8,18 @@ import (
 	"zen-test/app/web"
 	"zen-test/app/web/models"
 	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
 )
 
 type OrderController interface {
 	FindAllOrder(w http.ResponseWriter, r *http.Request)
 	CreateOrder(w http.ResponseWriter, r *http.Request)
+	CancelOrder(w http.ResponseWriter, r *http.Request)
+	ConfirmPayment(w http.ResponseWriter, r *http.Request)
+	ShipOrder(w http.ResponseWriter, r *http.Request)
+	RunOrderExpiry(w http.ResponseWriter, r *http.Request)
+	OrderExpiryStats(w http.ResponseWriter, r *http.Request)
 }
 
 type OrderControllerImpl struct {
@@ -36,8 +43,9 @@ func NewOrderController(orderService services.OrderService) OrderController {
 // @Router /orders [get]
 // @Security BearerAuth
 func (c *OrderControllerImpl) FindAllOrder(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
 
-	data, err := c.OrderService.FindAllOrder(r.Context())
+	data, err := c.OrderService.FindAllOrder(r.Context(), userId)
 	helpers.PanicIfError(err)
 
 	webResponse := web.WebResponse{
@@ -68,8 +76,17 @@ func (c *OrderControllerImpl) CreateOrder(w http.ResponseWriter, r *http.Request
 
 	responseChan := make(chan web.WebResponse)
 	errorChan := make(chan error)
+	panicChan := make(chan any)
 
 	go func() {
+		// Hand panics back to the request goroutine so the recover middleware
+		// answers them instead of the process crashing.
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+
 		OrderResponse, err := c.OrderService.CreateOrder(r.Context(), createOrderRequest, userId)
 		if err != nil {
 			errorChan <- err
@@ -89,5 +106,147 @@ func (c *OrderControllerImpl) CreateOrder(w http.ResponseWriter, r *http.Request
 		helpers.WriteResponseBody(w, webResponse)
 	case err := <-errorChan:
 		helpers.PanicIfError(err)
+	case p := <-panicChan:
+		panic(p)
+	}
+}
+
+// Cancel Order godoc
+// @Summary Cancel an Order
+// @Description Cancel an unpaid or not yet shipped Order, restoring stock and refunding paid orders
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param orderId path string true "Order ID"
+// @Param Order body models.OrderCancel true "Order cancel"
+// @Success 200 {object} web.WebResponse{data=models.OrderResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /orders/{orderId}/cancel [post]
+// @Security BearerAuth
+func (c *OrderControllerImpl) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	cancelOrderRequest := models.OrderCancel{}
+	helpers.ToRequestBody(r, &cancelOrderRequest)
+
+	vars := mux.Vars(r)
+	orderId := vars["orderId"]
+
+	userId := middleware.GetUserID(r)
+
+	orderResponse, err := c.OrderService.CancelOrder(r.Context(), orderId, cancelOrderRequest, userId)
+	helpers.PanicIfError(err)
+
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   orderResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Confirm Order Payment godoc
+// @Summary Confirm the payment of an Order
+// @Description Mark an unpaid Order as paid with the reference of the payment, which is settled outside the application
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param orderId path string true "Order ID"
+// @Param Order body models.OrderPayment true "Order payment"
+// @Success 200 {object} web.WebResponse{data=models.OrderResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /orders/{orderId}/pay [post]
+// @Security BearerAuth
+func (c *OrderControllerImpl) ConfirmPayment(w http.ResponseWriter, r *http.Request) {
+	paymentRequest := models.OrderPayment{}
+	helpers.ToRequestBody(r, &paymentRequest)
+
+	vars := mux.Vars(r)
+	orderId := vars["orderId"]
+
+	staffId := middleware.GetUserID(r)
+
+	orderResponse := c.OrderService.ConfirmPayment(r.Context(), orderId, paymentRequest, staffId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   orderResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Ship Order godoc
+// @Summary Ship an Order
+// @Description Mark a paid Order as shipped with its carrier and tracking number
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param orderId path string true "Order ID"
+// @Param Order body models.OrderShip true "Order shipment"
+// @Success 200 {object} web.WebResponse{data=models.OrderResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /orders/{orderId}/ship [post]
+// @Security BearerAuth
+func (c *OrderControllerImpl) ShipOrder(w http.ResponseWriter, r *http.Request) {
+	shipOrderRequest := models.OrderShip{}
+	helpers.ToRequestBody(r, &shipOrderRequest)
+
+	vars := mux.Vars(r)
+	orderId := vars["orderId"]
+
+	staffId := middleware.GetUserID(r)
+
+	orderResponse := c.OrderService.ShipOrder(r.Context(), orderId, shipOrderRequest, staffId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   orderResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Run Order Expiry godoc
+// @Summary Run the unpaid order expiry job
+// @Description Cancel every unpaid Order older than the expiry window right away
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=models.OrderExpiryRun}
+// @Failure 403 {object} web.WebResponse
+// @Router /admin/jobs/order-expiry/run [post]
+// @Security BearerAuth
+func (c *OrderControllerImpl) RunOrderExpiry(w http.ResponseWriter, r *http.Request) {
+	run := c.OrderService.RunOrderExpiry(r.Context())
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   run,
 	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Order Expiry Stats godoc
+// @Summary Unpaid order expiry job statistics
+// @Description Configuration, totals and the last run of the unpaid order expiry job
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=models.OrderExpiryStats}
+// @Failure 403 {object} web.WebResponse
+// @Router /admin/jobs/order-expiry [get]
+// @Security BearerAuth
+func (c *OrderControllerImpl) OrderExpiryStats(w http.ResponseWriter, r *http.Request) {
+	stats := c.OrderService.OrderExpiryStats(r.Context())
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   stats,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
 }
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"zen-test/app/events"
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/realtime"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+type OrderEventController interface {
+	StreamOrders(w http.ResponseWriter, r *http.Request)
+	StreamOrder(w http.ResponseWriter, r *http.Request)
+}
+
+type OrderEventControllerImpl struct {
+	OrderEventService services.OrderEventService
+	Hub               *realtime.Hub
+	Heartbeat         time.Duration
+}
+
+func NewOrderEventController(orderEventService services.OrderEventService, hub *realtime.Hub, heartbeat time.Duration) OrderEventController {
+	return &OrderEventControllerImpl{
+		OrderEventService: orderEventService,
+		Hub:               hub,
+		Heartbeat:         heartbeat,
+	}
+}
+
+// Stream Orders godoc
+// @Summary Stream events of the user's Orders
+// @Description Server-Sent Events stream of status, payment and shipment events for every Order of the user. Send Last-Event-ID to resume after a disconnect.
+// @Tags Order
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {string} string "event stream"
+// @Failure 401 {object} web.WebResponse
+// @Router /orders/events [get]
+// @Security BearerAuth
+func (c *OrderEventControllerImpl) StreamOrders(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	filter := c.OrderEventService.OrdersFilter(r.Context(), userId)
+	c.stream(w, r, filter)
+}
+
+// Stream Order godoc
+// @Summary Stream events of an Order
+// @Description Server-Sent Events stream of status, payment and shipment events for one Order. Send Last-Event-ID to resume after a disconnect.
+// @Tags Order
+// @Produce text/event-stream
+// @Param orderId path string true "Order ID"
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {string} string "event stream"
+// @Failure 404 {object} web.WebResponse
+// @Router /orders/{orderId}/events [get]
+// @Security BearerAuth
+func (c *OrderEventControllerImpl) StreamOrder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderId := vars["orderId"]
+
+	userId := middleware.GetUserID(r)
+
+	filter := c.OrderEventService.OrderFilter(r.Context(), orderId, userId)
+	c.stream(w, r, filter)
+}
+
+func (c *OrderEventControllerImpl) stream(w http.ResponseWriter, r *http.Request, filter models.OutboxFilter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		panic("streaming is not supported by this connection")
+	}
+
+	// Subscribe before replaying so nothing published in between is lost.
+	subscription := c.Hub.Subscribe(realtime.Matching(filter))
+	defer subscription.Close()
+
+	missed := c.OrderEventService.Replay(r.Context(), filter, r.Header.Get("Last-Event-ID"))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", c.Heartbeat.Milliseconds())
+	sent := make(map[string]struct{})
+	for _, event := range missed {
+		writeServerSentEvent(w, event)
+		sent[event.ID] = struct{}{}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(c.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event, open := <-subscription.Events():
+			// A closed subscription means the client fell behind or the server
+			// is shutting down; it reconnects and resumes from its last event.
+			if !open {
+				return
+			}
+			if _, ok := sent[event.ID]; ok {
+				continue
+			}
+			writeServerSentEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event events.Event) {
+	data, err := json.Marshal(event)
+	helpers.PanicIfError(err)
+
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+)
+
+type PasswordResetController interface {
+	Forgot(w http.ResponseWriter, r *http.Request)
+	Reset(w http.ResponseWriter, r *http.Request)
+}
+
+type PasswordResetControllerImpl struct {
+	PasswordResetService services.PasswordResetService
+}
+
+func NewPasswordResetController(passwordResetService services.PasswordResetService) PasswordResetController {
+	return &PasswordResetControllerImpl{
+		PasswordResetService: passwordResetService,
+	}
+}
+
+// Forgot Password godoc
+// @Summary Request a password reset
+// @Description Email a single-use, time-limited password reset link. The response is the same whether or not an account uses the email.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordForgot true "Account email"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/password/forgot [post]
+func (c *PasswordResetControllerImpl) Forgot(w http.ResponseWriter, r *http.Request) {
+	request := models.PasswordForgot{}
+	helpers.ToRequestBody(r, &request)
+
+	c.PasswordResetService.Forgot(r.Context(), request)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "If an account uses this email, a reset link is on its way",
+	}
+	helpers.WriteResponseBody(w, response)
+}
+
+// Reset Password godoc
+// @Summary Reset a password
+// @Description Choose a new password with the token of a reset link. Every session of the account is signed out.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordReset true "Reset token and new password"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/password/reset [post]
+func (c *PasswordResetControllerImpl) Reset(w http.ResponseWriter, r *http.Request) {
+	request := models.PasswordReset{}
+	helpers.ToRequestBody(r, &request)
+
+	c.PasswordResetService.Reset(r.Context(), request)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Password has been reset",
+	}
+	helpers.WriteResponseBody(w, response)
+}
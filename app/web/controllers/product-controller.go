@@ -38,6 +38,7 @@ func NewProductController(productService services.ProductService) ProductControl
 // @Param Product body models.ProductDto true "Product create"
 // @Success 200 {object} web.WebResponse{data=models.ProductResponse}
 // @Failure 401 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
 // @Router /products [post]
 // @Security BearerAuth
 func (c *ProductControllerImpl) Create(w http.ResponseWriter, r *http.Request) {
@@ -64,6 +65,7 @@ func (c *ProductControllerImpl) Create(w http.ResponseWriter, r *http.Request) {
 // @Param productId path string true "Product ID"
 // @Success 200 {object} web.WebResponse{data=models.ProductResponse}
 // @Failure 401 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
 // @Router /products/{productId} [put]
 // @Security BearerAuth
 func (c *ProductControllerImpl) Update(w http.ResponseWriter, r *http.Request) {
@@ -92,6 +94,7 @@ func (c *ProductControllerImpl) Update(w http.ResponseWriter, r *http.Request) {
 // @Param productId path string true "Product ID"
 // @Success 200 {object} web.WebResponse
 // @Failure 401 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
 // @Router /products/{productId} [delete]
 // @Security BearerAuth
 func (c *ProductControllerImpl) Delete(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+type ReturnController interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	FindAll(w http.ResponseWriter, r *http.Request)
+	FindById(w http.ResponseWriter, r *http.Request)
+	Approve(w http.ResponseWriter, r *http.Request)
+	Reject(w http.ResponseWriter, r *http.Request)
+	Receive(w http.ResponseWriter, r *http.Request)
+}
+
+type ReturnControllerImpl struct {
+	ReturnService services.ReturnService
+}
+
+func NewReturnController(returnService services.ReturnService) ReturnController {
+	return &ReturnControllerImpl{
+		ReturnService: returnService,
+	}
+}
+
+// Create Return godoc
+// @Summary Open a return request
+// @Description Open a return request for items of a paid order
+// @Tags Return
+// @Accept json
+// @Produce json
+// @Param Return body models.ReturnRequestCreate true "Return create"
+// @Success 200 {object} web.WebResponse{data=models.ReturnRequestResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /returns [post]
+// @Security BearerAuth
+func (c *ReturnControllerImpl) Create(w http.ResponseWriter, r *http.Request) {
+	returnCreateRequest := models.ReturnRequestCreate{}
+	helpers.ToRequestBody(r, &returnCreateRequest)
+
+	userId := middleware.GetUserID(r)
+
+	returnResponse := c.ReturnService.Create(r.Context(), returnCreateRequest, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   returnResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindAll Return godoc
+// @Summary FindAll Return requests
+// @Description FindAll Return requests of the user, or every request for staff
+// @Tags Return
+// @Accept json
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=[]models.ReturnRequestResponse}
+// @Failure 401 {object} web.WebResponse
+// @Router /returns [get]
+// @Security BearerAuth
+func (c *ReturnControllerImpl) FindAll(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	returnResponses := c.ReturnService.FindAll(r.Context(), userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   returnResponses,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindById Return godoc
+// @Summary FindById Return request
+// @Description FindById Return request
+// @Tags Return
+// @Accept json
+// @Produce json
+// @Param returnId path string true "Return ID"
+// @Success 200 {object} web.WebResponse{data=models.ReturnRequestResponse}
+// @Failure 404 {object} web.WebResponse
+// @Router /returns/{returnId} [get]
+// @Security BearerAuth
+func (c *ReturnControllerImpl) FindById(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	returnId := vars["returnId"]
+
+	userId := middleware.GetUserID(r)
+
+	returnResponse := c.ReturnService.FindById(r.Context(), returnId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   returnResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Approve Return godoc
+// @Summary Approve a return request
+// @Description Approve a return request and refund the returned items, fully or partially
+// @Tags Return
+// @Accept json
+// @Produce json
+// @Param returnId path string true "Return ID"
+// @Param Review body models.ReturnRequestReview true "Return review"
+// @Success 200 {object} web.WebResponse{data=models.ReturnRequestResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /returns/{returnId}/approve [post]
+// @Security BearerAuth
+func (c *ReturnControllerImpl) Approve(w http.ResponseWriter, r *http.Request) {
+	reviewRequest := models.ReturnRequestReview{}
+	helpers.ToRequestBody(r, &reviewRequest)
+
+	vars := mux.Vars(r)
+	returnId := vars["returnId"]
+
+	staffId := middleware.GetUserID(r)
+
+	returnResponse := c.ReturnService.Approve(r.Context(), returnId, reviewRequest, staffId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   returnResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Reject Return godoc
+// @Summary Reject a return request
+// @Description Reject a return request
+// @Tags Return
+// @Accept json
+// @Produce json
+// @Param returnId path string true "Return ID"
+// @Param Review body models.ReturnRequestReview true "Return review"
+// @Success 200 {object} web.WebResponse{data=models.ReturnRequestResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /returns/{returnId}/reject [post]
+// @Security BearerAuth
+func (c *ReturnControllerImpl) Reject(w http.ResponseWriter, r *http.Request) {
+	reviewRequest := models.ReturnRequestReview{}
+	helpers.ToRequestBody(r, &reviewRequest)
+
+	vars := mux.Vars(r)
+	returnId := vars["returnId"]
+
+	staffId := middleware.GetUserID(r)
+
+	returnResponse := c.ReturnService.Reject(r.Context(), returnId, reviewRequest, staffId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   returnResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Receive Return godoc
+// @Summary Receive returned items
+// @Description Mark the items of an approved return as received and restock them
+// @Tags Return
+// @Accept json
+// @Produce json
+// @Param returnId path string true "Return ID"
+// @Success 200 {object} web.WebResponse{data=models.ReturnRequestResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /returns/{returnId}/receive [post]
+// @Security BearerAuth
+func (c *ReturnControllerImpl) Receive(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	returnId := vars["returnId"]
+
+	staffId := middleware.GetUserID(r)
+
+	returnResponse := c.ReturnService.Receive(r.Context(), returnId, staffId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   returnResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
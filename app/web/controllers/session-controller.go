@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+type SessionController interface {
+	FindAll(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+}
+
+type SessionControllerImpl struct {
+	SessionService services.SessionService
+}
+
+func NewSessionController(sessionService services.SessionService) SessionController {
+	return &SessionControllerImpl{
+		SessionService: sessionService,
+	}
+}
+
+// FindAll Session godoc
+// @Summary List active sessions
+// @Description List the active login sessions of the authenticated user, marking the one making the request
+// @Tags User
+// @Accept json
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=[]models.SessionResponse}
+// @Failure 401 {object} web.WebResponse
+// @Router /users/me/sessions [get]
+// @Security BearerAuth
+func (c *SessionControllerImpl) FindAll(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+	sessionId := middleware.GetSessionID(r)
+
+	sessionResponses := c.SessionService.FindAll(r.Context(), userId, sessionId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   sessionResponses,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Revoke Session godoc
+// @Summary Revoke a session
+// @Description Sign a session of the authenticated user out, revoking its access and refresh tokens
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /users/me/sessions/{sessionId} [delete]
+// @Security BearerAuth
+func (c *SessionControllerImpl) Revoke(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionId := vars["sessionId"]
+
+	userId := middleware.GetUserID(r)
+
+	c.SessionService.Revoke(r.Context(), sessionId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
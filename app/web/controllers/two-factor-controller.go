@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+)
+
+type TwoFactorController interface {
+	Enroll(w http.ResponseWriter, r *http.Request)
+	Confirm(w http.ResponseWriter, r *http.Request)
+	Disable(w http.ResponseWriter, r *http.Request)
+	RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request)
+}
+
+type TwoFactorControllerImpl struct {
+	TwoFactorService services.TwoFactorService
+}
+
+func NewTwoFactorController(twoFactorService services.TwoFactorService) TwoFactorController {
+	return &TwoFactorControllerImpl{
+		TwoFactorService: twoFactorService,
+	}
+}
+
+// Enroll Two Factor godoc
+// @Summary Start two-factor enrollment
+// @Description Generate a TOTP secret for the authenticated user, who gives their password again. Add it to an authenticator app with the otpauth URI, then confirm with a first code.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorEnroll true "Password"
+// @Success 200 {object} web.WebResponse{data=models.TwoFactorEnrollResponse}
+// @Failure 400 {object} web.WebResponse
+// @Router /users/2fa/enroll [post]
+// @Security BearerAuth
+func (c *TwoFactorControllerImpl) Enroll(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	request := models.TwoFactorEnroll{}
+	helpers.ToRequestBody(r, &request)
+
+	enrollResponse := c.TwoFactorService.Enroll(r.Context(), request, userId)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   enrollResponse,
+	}
+	helpers.WriteResponseBody(w, response)
+}
+
+// Confirm Two Factor godoc
+// @Summary Confirm two-factor enrollment
+// @Description Turn on two-factor authentication with a first code from the authenticator app. The recovery codes in the response are shown only once.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorConfirm true "TOTP code"
+// @Success 200 {object} web.WebResponse{data=models.TwoFactorConfirmResponse}
+// @Failure 400 {object} web.WebResponse
+// @Router /users/2fa/confirm [post]
+// @Security BearerAuth
+func (c *TwoFactorControllerImpl) Confirm(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	request := models.TwoFactorConfirm{}
+	helpers.ToRequestBody(r, &request)
+
+	confirmResponse := c.TwoFactorService.Confirm(r.Context(), request, userId)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Two-factor authentication enabled",
+		Data:   confirmResponse,
+	}
+	helpers.WriteResponseBody(w, response)
+}
+
+// Disable Two Factor godoc
+// @Summary Turn off two-factor authentication
+// @Description Turn off two-factor authentication with the password and a code from the authenticator app, or an unused recovery code when the authenticator is lost. The recovery codes are deleted too.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorVerify true "Password and code"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/2fa/disable [post]
+// @Security BearerAuth
+func (c *TwoFactorControllerImpl) Disable(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	request := models.TwoFactorVerify{}
+	helpers.ToRequestBody(r, &request)
+
+	c.TwoFactorService.Disable(r.Context(), request, userId)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Two-factor authentication disabled",
+	}
+	helpers.WriteResponseBody(w, response)
+}
+
+// Regenerate Recovery Codes godoc
+// @Summary Replace the two-factor recovery codes
+// @Description Replace every recovery code with new ones, given the password and a code from the authenticator app or an unused recovery code. The new codes are shown only once.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorVerify true "Password and code"
+// @Success 200 {object} web.WebResponse{data=models.TwoFactorConfirmResponse}
+// @Failure 400 {object} web.WebResponse
+// @Router /users/2fa/recovery-codes [post]
+// @Security BearerAuth
+func (c *TwoFactorControllerImpl) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	request := models.TwoFactorVerify{}
+	helpers.ToRequestBody(r, &request)
+
+	codesResponse := c.TwoFactorService.RegenerateRecoveryCodes(r.Context(), request, userId)
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   codesResponse,
+	}
+	helpers.WriteResponseBody(w, response)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+type UserAdminController interface {
+	Search(w http.ResponseWriter, r *http.Request)
+	FindById(w http.ResponseWriter, r *http.Request)
+	Disable(w http.ResponseWriter, r *http.Request)
+	Enable(w http.ResponseWriter, r *http.Request)
+	ChangeRole(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+}
+
+type UserAdminControllerImpl struct {
+	UserAdminService services.UserAdminService
+}
+
+func NewUserAdminController(userAdminService services.UserAdminService) UserAdminController {
+	return &UserAdminControllerImpl{
+		UserAdminService: userAdminService,
+	}
+}
+
+// Search User godoc
+// @Summary Search users
+// @Description List users newest first, filtered by a name or email prefix, role and status
+// @Tags Admin
+// @Produce json
+// @Param q query string false "Name or email prefix, or user ID"
+// @Param role query string false "Role" Enums(CUSTOMER, STAFF, ADMIN)
+// @Param status query string false "Status" Enums(active, disabled)
+// @Param page query int false "Page, from 1"
+// @Param size query int false "Page size, at most 100"
+// @Success 200 {object} web.WebResponse{data=models.UserPage}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /admin/users [get]
+// @Security BearerAuth
+func (c *UserAdminControllerImpl) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := models.UserSearch{
+		Query:  query.Get("q"),
+		Role:   query.Get("role"),
+		Status: query.Get("status"),
+		Page:   queryInt(r, "page"),
+		Size:   queryInt(r, "size"),
+	}
+
+	userPage := c.UserAdminService.Search(r.Context(), search)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userPage,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindById User godoc
+// @Summary Get a user
+// @Description Get a user along with their orders
+// @Tags Admin
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} web.WebResponse{data=models.UserDetailResponse}
+// @Failure 403 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /admin/users/{userId} [get]
+// @Security BearerAuth
+func (c *UserAdminControllerImpl) FindById(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+
+	userResponse := c.UserAdminService.FindById(r.Context(), userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Disable User godoc
+// @Summary Disable a user
+// @Description Disable an account and sign it out everywhere. Disabled users cannot log in and their tokens and API keys are refused. Staff and admin accounts can only be disabled by admins.
+// @Tags Admin
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} web.WebResponse{data=models.UserResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /admin/users/{userId}/disable [post]
+// @Security BearerAuth
+func (c *UserAdminControllerImpl) Disable(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	callerId := middleware.GetUserID(r)
+
+	userResponse := c.UserAdminService.Disable(r.Context(), userId, callerId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Enable User godoc
+// @Summary Enable a user
+// @Description Enable a disabled account again. Deleted accounts stay disabled.
+// @Tags Admin
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} web.WebResponse{data=models.UserResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /admin/users/{userId}/enable [post]
+// @Security BearerAuth
+func (c *UserAdminControllerImpl) Enable(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+
+	userResponse := c.UserAdminService.Enable(r.Context(), userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// ChangeRole User godoc
+// @Summary Change the role of a user
+// @Description Give a user another role. Admins cannot change their own role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param userId path string true "User ID"
+// @Param request body models.UserRoleChange true "Role"
+// @Success 200 {object} web.WebResponse{data=models.UserResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /admin/users/{userId}/role [put]
+// @Security BearerAuth
+func (c *UserAdminControllerImpl) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	callerId := middleware.GetUserID(r)
+
+	request := models.UserRoleChange{}
+	helpers.ToRequestBody(r, &request)
+
+	userResponse := c.UserAdminService.ChangeRole(r.Context(), request, userId, callerId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Logout User godoc
+// @Summary Sign a user out everywhere
+// @Description Revoke every session and refresh token of a user without disabling the account. Staff and admin accounts can only be signed out by admins.
+// @Tags Admin
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /admin/users/{userId}/logout [post]
+// @Security BearerAuth
+func (c *UserAdminControllerImpl) Logout(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+
+	c.UserAdminService.Logout(r.Context(), userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// queryInt reads a whole number from the query, zero when it is missing.
+func queryInt(r *http.Request, name string) int {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		panic(exceptions.NewBadRequestError(name + " must be a number"))
+	}
+	return number
+}
@@ -1,14 +1,17 @@
 package controllers
 
 import (
+	"encoding/json"
 	"net/http"
 
-	"zen-test/app/auth"
+	"zen-test/app/exceptions"
 	"zen-test/app/helpers"
 	"zen-test/app/middleware"
 	"zen-test/app/web"
 	"zen-test/app/web/models"
 	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
 )
 
 type UserControllerImpl struct {
@@ -18,7 +21,12 @@ type UserControllerImpl struct {
 type UserController interface {
 	SignUp(w http.ResponseWriter, r *http.Request)
 	Login(w http.ResponseWriter, r *http.Request)
+	LoginTwoFactor(w http.ResponseWriter, r *http.Request)
 	Update(w http.ResponseWriter, r *http.Request)
+	FindMe(w http.ResponseWriter, r *http.Request)
+	Patch(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+	ChangeEmail(w http.ResponseWriter, r *http.Request)
 	Logout(w http.ResponseWriter, r *http.Request)
 	RefreshToken(w http.ResponseWriter, r *http.Request)
 }
@@ -55,39 +63,64 @@ func (c *UserControllerImpl) SignUp(w http.ResponseWriter, r *http.Request) {
 
 // Login godoc
 // @Summary Log in a user
-// @Description Authenticate a user and set a session cookie
+// @Description Authenticate a user and set a session cookie. Users with two-factor authentication get a challenge token to complete the login at /users/login/2fa instead.
 // @Tags User
 // @Accept json
 // @Produce json
 // @Param user body models.UserLogin true "User Login"
-// @Success 200 {object} web.WebResponse{data=models.UserResponse}
+// @Success 200 {object} web.WebResponse{data=models.UserLoginResponse}
 // @Failure 401 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
 // @Router /users/login [post]
 func (c *UserControllerImpl) Login(w http.ResponseWriter, r *http.Request) {
 	userLogin := models.UserLogin{}
 	helpers.ToRequestBody(r, &userLogin)
 
-	userResponse, loggedIn := c.UserService.Login(r.Context(), userLogin)
-	if loggedIn {
+	client := models.ClientInfo{Device: r.UserAgent(), IP: middleware.GetClientIP(r)}
+
+	userResponse := c.UserService.Login(r.Context(), userLogin, client)
+	if userResponse.RefreshToken != "" {
 		helpers.SetCookie(w, r, helpers.RefreshToken, userResponse.RefreshToken)
-		webResponse := web.WebResponse{
-			Code:   http.StatusOK,
-			Status: "Ok",
-			Data:   userResponse,
-		}
-		helpers.WriteResponseBody(w, webResponse)
-	} else {
-		webResponse := web.WebResponse{
-			Code:   http.StatusUnauthorized,
-			Status: "Your enail or password is not valid",
-		}
-		helpers.WriteResponseBody(w, webResponse)
 	}
+
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Login Two Factor godoc
+// @Summary Complete a two-factor login
+// @Description Exchange the challenge token returned by login, together with a TOTP code or a recovery code, for the access and refresh tokens
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorLogin true "Challenge token and code"
+// @Success 200 {object} web.WebResponse{data=models.UserLoginResponse}
+// @Failure 401 {object} web.WebResponse
+// @Router /users/login/2fa [post]
+func (c *UserControllerImpl) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	request := models.TwoFactorLogin{}
+	helpers.ToRequestBody(r, &request)
+
+	client := models.ClientInfo{Device: r.UserAgent(), IP: middleware.GetClientIP(r)}
+
+	userResponse := c.UserService.LoginTwoFactor(r.Context(), request, client)
+	helpers.SetCookie(w, r, helpers.RefreshToken, userResponse.RefreshToken)
+
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   userResponse,
+	}
+	helpers.WriteResponseBody(w, webResponse)
 }
 
 // Update godoc
 // @Summary Update user for the user
-// @Description Update user for the authenticated user
+// @Description Replace the phone and address of the user of the path, which has to be the authenticated user. Use POST /users/me/password to change the password.
 // @Tags User
 // @Accept json
 // @Produce json
@@ -95,35 +128,138 @@ func (c *UserControllerImpl) Login(w http.ResponseWriter, r *http.Request) {
 // @Param userId path string true "User ID"
 // @Success 200 {object} web.WebResponse{data=models.UserResponse}
 // @Failure 401 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
 // @Router /users/{userId} [put]
 // @Security BearerAuth
 func (c *UserControllerImpl) Update(w http.ResponseWriter, r *http.Request) {
 	userUpdateRequest := models.UserUpdate{}
 	helpers.ToRequestBody(r, &userUpdateRequest)
 
-	userId := middleware.GetUserID(r)
-	user := c.UserService.Update(r.Context(), userUpdateRequest, userId)
+	userId := mux.Vars(r)["userId"]
+	user := c.UserService.Update(r.Context(), userUpdateRequest, userId, middleware.GetUserID(r))
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   user,
+	}
+
+	helpers.WriteResponseBody(w, response)
+}
+
+// FindMe godoc
+// @Summary Current user
+// @Description Get the profile of the authenticated user
+// @Tags User
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=models.UserResponse}
+// @Failure 401 {object} web.WebResponse
+// @Router /users/me [get]
+// @Security BearerAuth
+func (c *UserControllerImpl) FindMe(w http.ResponseWriter, r *http.Request) {
+	user := c.UserService.FindMe(r.Context(), middleware.GetUserID(r))
+
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   user,
+	}
+	helpers.WriteResponseBody(w, response)
+}
+
+// Patch godoc
+// @Summary Update the current user
+// @Description Change the profile fields that are sent and keep the others
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param user body models.UserPatch true "Fields to change"
+// @Success 200 {object} web.WebResponse{data=models.UserResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /users/me [patch]
+// @Security BearerAuth
+func (c *UserControllerImpl) Patch(w http.ResponseWriter, r *http.Request) {
+	request := models.UserPatch{}
+	helpers.ToRequestBody(r, &request)
+
+	user := c.UserService.Patch(r.Context(), request, middleware.GetUserID(r))
+
 	response := web.WebResponse{
 		Code:   http.StatusOK,
 		Status: "Ok",
 		Data:   user,
 	}
+	helpers.WriteResponseBody(w, response)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Set a new password after confirming the current one. Every other session of the user is signed out.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param password body models.PasswordChange true "Current and new password"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /users/me/password [post]
+// @Security BearerAuth
+func (c *UserControllerImpl) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	request := models.PasswordChange{}
+	helpers.ToRequestBody(r, &request)
+
+	c.UserService.ChangePassword(r.Context(), request, middleware.GetUserID(r), middleware.GetSessionID(r))
+
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Password changed",
+	}
+	helpers.WriteResponseBody(w, response)
+}
 
+// ChangeEmail godoc
+// @Summary Change email address
+// @Description Mail a verification link to a new address after confirming the password. The account moves to the new address once the link is opened with POST /users/email/verify.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param email body models.EmailChange true "New address and current password"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /users/me/email [post]
+// @Security BearerAuth
+func (c *UserControllerImpl) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	request := models.EmailChange{}
+	helpers.ToRequestBody(r, &request)
+
+	c.UserService.ChangeEmail(r.Context(), request, middleware.GetUserID(r))
+
+	response := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Verification email sent",
+	}
 	helpers.WriteResponseBody(w, response)
 }
 
 // Logout godoc
 // @Summary Logout for the user
-// @Description Logout for the authenticated user
+// @Description Logout for the authenticated user, ending the session of the access token so its access and refresh tokens stop working
 // @Tags User
 // @Accept json
 // @Produce json
+// @Param token body models.RefreshTokenRequest false "Refresh token, when not sent as a cookie"
 // @Success 200 {object} web.WebResponse
 // @Failure 401 {object} web.WebResponse
 // @Router /users/logout [post]
 // @Security BearerAuth
 func (c *UserControllerImpl) Logout(w http.ResponseWriter, r *http.Request) {
-	helpers.DeleteCookieHandler(w, r, "refresh_token")
+	userId := middleware.GetUserID(r)
+	sessionId := middleware.GetSessionID(r)
+
+	c.UserService.Logout(r.Context(), sessionId, refreshTokenFrom(r), userId)
+
+	helpers.DeleteCookieHandler(w, r, helpers.RefreshToken)
 	response := web.WebResponse{
 		Code:   http.StatusOK,
 		Status: "Logout success",
@@ -133,40 +269,41 @@ func (c *UserControllerImpl) Logout(w http.ResponseWriter, r *http.Request) {
 
 // Refresh Token godoc
 // @Summary Refresh Token for the user
-// @Description Refresh Token for the authenticated user
+// @Description Exchange a refresh token, from the cookie or body, for a new access token and a new refresh token. Each refresh token works once.
 // @Tags User
 // @Accept json
 // @Produce json
-// @Success 200 {object} web.WebResponse
+// @Param token body models.RefreshTokenRequest false "Refresh token, when not sent as a cookie"
+// @Success 200 {object} web.WebResponse{data=models.TokenResponse}
 // @Failure 401 {object} web.WebResponse
 // @Router /users/refresh-token [post]
-// @Security BearerAuth
 func (c *UserControllerImpl) RefreshToken(w http.ResponseWriter, r *http.Request) {
-	cookie, err := helpers.GetCookie(w, r, helpers.RefreshToken)
-	if err != nil {
-		http.Error(w, "Refresh token not found", http.StatusUnauthorized)
-		return
-	}
-
-	claims, err := auth.VerifyToken(cookie.Value)
-	if err != nil {
-		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
-		return
+	refreshToken := refreshTokenFrom(r)
+	if refreshToken == "" {
+		panic(exceptions.NewUnauthorizedError("refresh token not found"))
 	}
 
-	id := claims["id"].(string)
-	newAccessToken, err := auth.CreateToken(id)
-	if err != nil {
-		http.Error(w, "Failed to create new access token", http.StatusInternalServerError)
-		return
-	}
+	tokenResponse := c.UserService.Refresh(r.Context(), refreshToken)
+	helpers.SetCookie(w, r, helpers.RefreshToken, tokenResponse.RefreshToken)
 
 	response := web.WebResponse{
 		Code:   http.StatusOK,
 		Status: "Token refreshed",
-		Data: map[string]string{
-			"access_token": newAccessToken,
-		},
+		Data:   tokenResponse,
 	}
 	helpers.WriteResponseBody(w, response)
 }
+
+// refreshTokenFrom reads the refresh token from its cookie, falling back to
+// the request body for clients that do not keep cookies.
+func refreshTokenFrom(r *http.Request) string {
+	if cookie, err := r.Cookie(helpers.RefreshToken); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	request := models.RefreshTokenRequest{}
+	if r.Body != nil && r.ContentLength != 0 {
+		json.NewDecoder(r.Body).Decode(&request)
+	}
+	return request.RefreshToken
+}
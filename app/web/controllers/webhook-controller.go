@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"net/http"
+
+	"zen-test/app/helpers"
+	"zen-test/app/middleware"
+	"zen-test/app/web"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookController interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	Update(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	FindAll(w http.ResponseWriter, r *http.Request)
+	FindById(w http.ResponseWriter, r *http.Request)
+	FindDeliveries(w http.ResponseWriter, r *http.Request)
+	Redeliver(w http.ResponseWriter, r *http.Request)
+}
+
+type WebhookControllerImpl struct {
+	WebhookService services.WebhookService
+}
+
+func NewWebhookController(webhookService services.WebhookService) WebhookController {
+	return &WebhookControllerImpl{
+		WebhookService: webhookService,
+	}
+}
+
+// Create Webhook godoc
+// @Summary Subscribe a webhook endpoint
+// @Description Subscribe an endpoint to order and product events. The signing secret is only returned here.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param Webhook body models.WebhookSubscriptionCreate true "Webhook create"
+// @Success 200 {object} web.WebResponse{data=models.WebhookSubscriptionResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /webhooks [post]
+// @Security BearerAuth
+func (c *WebhookControllerImpl) Create(w http.ResponseWriter, r *http.Request) {
+	webhookCreateRequest := models.WebhookSubscriptionCreate{}
+	helpers.ToRequestBody(r, &webhookCreateRequest)
+
+	userId := middleware.GetUserID(r)
+
+	webhookResponse := c.WebhookService.Create(r.Context(), webhookCreateRequest, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   webhookResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Update Webhook godoc
+// @Summary Update a webhook subscription
+// @Description Change the endpoint, subscribed events or active flag of a webhook subscription
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook ID"
+// @Param Webhook body models.WebhookSubscriptionUpdate true "Webhook update"
+// @Success 200 {object} web.WebResponse{data=models.WebhookSubscriptionResponse}
+// @Failure 400 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /webhooks/{webhookId} [put]
+// @Security BearerAuth
+func (c *WebhookControllerImpl) Update(w http.ResponseWriter, r *http.Request) {
+	webhookUpdateRequest := models.WebhookSubscriptionUpdate{}
+	helpers.ToRequestBody(r, &webhookUpdateRequest)
+
+	vars := mux.Vars(r)
+	webhookId := vars["webhookId"]
+
+	userId := middleware.GetUserID(r)
+
+	webhookResponse := c.WebhookService.Update(r.Context(), webhookId, webhookUpdateRequest, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   webhookResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Delete Webhook godoc
+// @Summary Delete a webhook subscription
+// @Description Delete a webhook subscription
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 404 {object} web.WebResponse
+// @Router /webhooks/{webhookId} [delete]
+// @Security BearerAuth
+func (c *WebhookControllerImpl) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookId := vars["webhookId"]
+
+	userId := middleware.GetUserID(r)
+
+	c.WebhookService.Delete(r.Context(), webhookId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+	}
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindAll Webhook godoc
+// @Summary FindAll Webhook subscriptions
+// @Description FindAll Webhook subscriptions
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Success 200 {object} web.WebResponse{data=[]models.WebhookSubscriptionResponse}
+// @Failure 403 {object} web.WebResponse
+// @Router /webhooks [get]
+// @Security BearerAuth
+func (c *WebhookControllerImpl) FindAll(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r)
+
+	webhookResponses := c.WebhookService.FindAll(r.Context(), userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   webhookResponses,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindById Webhook godoc
+// @Summary FindById Webhook subscription
+// @Description FindById Webhook subscription
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook ID"
+// @Success 200 {object} web.WebResponse{data=models.WebhookSubscriptionResponse}
+// @Failure 404 {object} web.WebResponse
+// @Router /webhooks/{webhookId} [get]
+// @Security BearerAuth
+func (c *WebhookControllerImpl) FindById(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookId := vars["webhookId"]
+
+	userId := middleware.GetUserID(r)
+
+	webhookResponse := c.WebhookService.FindById(r.Context(), webhookId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   webhookResponse,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// FindDeliveries Webhook godoc
+// @Summary Webhook delivery log
+// @Description List the deliveries of a webhook subscription, newest first
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook ID"
+// @Success 200 {object} web.WebResponse{data=[]models.WebhookDelivery}
+// @Failure 404 {object} web.WebResponse
+// @Router /webhooks/{webhookId}/deliveries [get]
+// @Security BearerAuth
+func (c *WebhookControllerImpl) FindDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookId := vars["webhookId"]
+
+	userId := middleware.GetUserID(r)
+
+	deliveries := c.WebhookService.FindDeliveries(r.Context(), webhookId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   deliveries,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
+
+// Redeliver Webhook godoc
+// @Summary Redeliver a webhook delivery
+// @Description Queue another attempt of a webhook delivery with its original payload
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param deliveryId path string true "Delivery ID"
+// @Success 200 {object} web.WebResponse{data=models.WebhookDelivery}
+// @Failure 404 {object} web.WebResponse
+// @Router /webhooks/deliveries/{deliveryId}/redeliver [post]
+// @Security BearerAuth
+func (c *WebhookControllerImpl) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deliveryId := vars["deliveryId"]
+
+	userId := middleware.GetUserID(r)
+
+	delivery := c.WebhookService.Redeliver(r.Context(), deliveryId, userId)
+	webResponse := web.WebResponse{
+		Code:   http.StatusOK,
+		Status: "Ok",
+		Data:   delivery,
+	}
+
+	helpers.WriteResponseBody(w, webResponse)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AccountDelete confirms deleting the account of the authenticated user.
+type AccountDelete struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// UserExport is a copy of the data kept about a user.
+type UserExport struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	Profile    UserResponse      `json:"profile"`
+	Addresses  []AddressResponse `json:"addresses"`
+	Orders     []OrderResponse   `json:"orders"`
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Address is one entry of a user's address book. At most one address of a
+// user is the default, which orders ship to unless another is chosen.
+type Address struct {
+	ID         string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID     string    `json:"user_id" gorm:"not null;index"`
+	Label      string    `json:"label" gorm:"type:varchar(50)"`
+	Recipient  string    `json:"recipient" gorm:"not null;type:varchar(100)"`
+	Street     string    `json:"street" gorm:"not null;type:varchar(255)"`
+	City       string    `json:"city" gorm:"not null;type:varchar(100)"`
+	Province   string    `json:"province" gorm:"not null;type:varchar(100)"`
+	PostalCode string    `json:"postal_code" gorm:"not null;type:varchar(20)"`
+	Country    string    `json:"country" gorm:"not null;type:varchar(2)"`
+	Phone      string    `json:"phone" gorm:"not null;type:varchar(20)"`
+	IsDefault  bool      `json:"is_default" gorm:"not null;default:false"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ShippingAddress is the copy of an address kept on an order, so editing or
+// deleting the address later does not change where the order went.
+type ShippingAddress struct {
+	Recipient  string `json:"recipient" gorm:"type:varchar(100)"`
+	Street     string `json:"street" gorm:"type:varchar(255)"`
+	City       string `json:"city" gorm:"type:varchar(100)"`
+	Province   string `json:"province" gorm:"type:varchar(100)"`
+	PostalCode string `json:"postal_code" gorm:"type:varchar(20)"`
+	Country    string `json:"country" gorm:"type:varchar(2)"`
+	Phone      string `json:"phone" gorm:"type:varchar(20)"`
+}
+
+type AddressResponse struct {
+	ID         string    `json:"id"`
+	Label      string    `json:"label"`
+	Recipient  string    `json:"recipient"`
+	Street     string    `json:"street"`
+	City       string    `json:"city"`
+	Province   string    `json:"province"`
+	PostalCode string    `json:"postal_code"`
+	Country    string    `json:"country"`
+	Phone      string    `json:"phone"`
+	IsDefault  bool      `json:"is_default"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// AddressCreateUpdate takes the country as an ISO 3166-1 alpha-2 code, such
+// as ID.
+type AddressCreateUpdate struct {
+	Label      string `json:"label" validate:"max=50"`
+	Recipient  string `json:"recipient" validate:"required,max=100"`
+	Street     string `json:"street" validate:"required,max=255"`
+	City       string `json:"city" validate:"required,max=100"`
+	Province   string `json:"province" validate:"required,max=100"`
+	PostalCode string `json:"postal_code" validate:"required,max=20"`
+	Country    string `json:"country" validate:"required,iso3166_1_alpha2"`
+	Phone      string `json:"phone" validate:"required,min=8,max=20"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+// Snapshot copies the parts of the address an order ships to.
+func (address Address) Snapshot() ShippingAddress {
+	return ShippingAddress{
+		Recipient:  address.Recipient,
+		Street:     address.Street,
+		City:       address.City,
+		Province:   address.Province,
+		PostalCode: address.PostalCode,
+		Country:    address.Country,
+		Phone:      address.Phone,
+	}
+}
+
+// String writes the address on one line, as orders stored it before they
+// kept the structured copy.
+func (address ShippingAddress) String() string {
+	var parts []string
+	for _, part := range []string{address.Street, address.City, address.Province, address.PostalCode, address.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func ToAddressResponse(address Address) AddressResponse {
+	return AddressResponse{
+		ID:         address.ID,
+		Label:      address.Label,
+		Recipient:  address.Recipient,
+		Street:     address.Street,
+		City:       address.City,
+		Province:   address.Province,
+		PostalCode: address.PostalCode,
+		Country:    address.Country,
+		Phone:      address.Phone,
+		IsDefault:  address.IsDefault,
+		CreatedAt:  address.CreatedAt,
+		UpdatedAt:  address.UpdatedAt,
+	}
+}
+
+func ToAddressResponses(addresses []Address) []AddressResponse {
+	var responses []AddressResponse
+
+	for _, address := range addresses {
+		responses = append(responses, ToAddressResponse(address))
+	}
+	return responses
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey lets an integration act as a user within its scopes. The key is
+// shown once; only its prefix, to find it by, and its hash are stored.
+type APIKey struct {
+	ID         string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID     string     `json:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"not null;type:varchar(100)"`
+	Prefix     string     `json:"prefix" gorm:"not null;uniqueIndex;type:varchar(16)"`
+	KeyHash    string     `json:"-" gorm:"not null;type:varchar(64)"`
+	Scopes     string     `json:"scopes" gorm:"not null;type:varchar(255)"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type APIKeyCreate struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Scopes limit the key to part of what the user may do; a key never
+	// gets a scope the user's role does not grant.
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=products:read products:write orders:read:own orders:read:all orders:write:own orders:write:all returns:read:own returns:read:all returns:write:own returns:write:all webhooks:read webhooks:write dashboard:read users:read users:write users:roles"`
+	// ExpiresAt is when the key stops working; it never does when empty.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func ToAPIKeyResponse(key APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     strings.Split(key.Scopes, ","),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+func ToAPIKeyResponses(keys []APIKey) []APIKeyResponse {
+	var responses []APIKeyResponse
+
+	for _, key := range keys {
+		responses = append(responses, ToAPIKeyResponse(key))
+	}
+	return responses
+}
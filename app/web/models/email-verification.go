@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailVerificationToken proves the owner of an address signed up with it,
+// or asked to change to it: Email is the new address of an email change and
+// empty otherwise. Only the hash is stored and a token works once.
+type EmailVerificationToken struct {
+	ID        string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID    string     `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex;type:varchar(64)"`
+	Email     string     `json:"email" gorm:"type:varchar(100)"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type EmailVerify struct {
+	Token string `validate:"required" json:"token"`
+}
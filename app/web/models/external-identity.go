@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// ExternalIdentity links an account at an identity provider to a user, who
+// can then log in through that provider.
+type ExternalIdentity struct {
+	ID        string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID    string    `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"type:varchar(50);not null;uniqueIndex:idx_external_identities_subject"`
+	Subject   string    `json:"subject" gorm:"type:varchar(255);not null;uniqueIndex:idx_external_identities_subject"`
+	Email     string    `json:"email" gorm:"type:varchar(255)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// OIDCLoginState remembers a login sent to an identity provider until the
+// user comes back. Only the hash of the state parameter is stored and it
+// works once.
+type OIDCLoginState struct {
+	ID           string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	Provider     string     `json:"provider" gorm:"type:varchar(50);not null"`
+	StateHash    string     `json:"-" gorm:"not null;uniqueIndex;type:varchar(64)"`
+	CodeVerifier string     `json:"-" gorm:"type:varchar(128);not null"`
+	Nonce        string     `json:"-" gorm:"type:varchar(128);not null"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// OIDCCallback is what the identity provider sends the user back with.
+type OIDCCallback struct {
+	Code  string
+	State string
+	Error string
+	// BrowserState is the state of the login started in this browser, from
+	// its state cookie. A callback carrying another state was not started
+	// by this browser.
+	BrowserState string
+}
+
+// OIDCLoginStart is the page of the identity provider to send the user to,
+// and the state their browser keeps until they return.
+type OIDCLoginStart struct {
+	AuthURL   string
+	State     string
+	ExpiresAt time.Time
+}
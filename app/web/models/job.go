@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+type Job struct {
+	ID        string  `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	Type      string  `json:"type" gorm:"not null;type:varchar(100);index"`
+	Payload   string  `json:"payload" gorm:"type:text"`
+	UniqueKey *string `json:"unique_key" gorm:"type:varchar(191);uniqueIndex"`
+	// UserID names the user the job holds personal data of, if any, so the
+	// job can be found when their account is deleted.
+	UserID      string     `json:"user_id" gorm:"type:varchar(191);index"`
+	Status      string     `json:"status" gorm:"not null;type:varchar(20);index"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"not null"`
+	RunAt       time.Time  `json:"run_at" gorm:"not null;index"`
+	LockedAt    *time.Time `json:"locked_at"`
+	LockedBy    string     `json:"locked_by" gorm:"type:varchar(100)"`
+	LastError   string     `json:"last_error" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type DeadJob struct {
+	ID        string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	Type      string    `json:"type" gorm:"not null;type:varchar(100);index"`
+	Payload   string    `json:"payload" gorm:"type:text"`
+	UserID    string    `json:"user_id" gorm:"type:varchar(191);index"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error" gorm:"type:text"`
+	FailedAt  time.Time `json:"failed_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type JobLease struct {
+	Name      string    `json:"name" gorm:"not null;primary_key;type:varchar(191)"`
+	Holder    string    `json:"holder" gorm:"not null;type:varchar(100)"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
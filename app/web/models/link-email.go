@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// LinkEmail is the payload of the job mailing a user a link that carries a
+// secret token, such as a password reset link. It bypasses the outbox so the
+// token never reaches webhooks or event sinks. To, when set, is the address
+// to send to instead of the user's own, such as the new address of an email
+// change.
+type LinkEmail struct {
+	UserID    string    `json:"user_id"`
+	To        string    `json:"to,omitempty"`
+	Template  string    `json:"template"`
+	Link      string    `json:"link"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
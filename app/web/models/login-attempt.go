@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	LoginSucceeded  = "succeeded"
+	LoginFailed     = "failed"
+	LoginBlocked    = "blocked"
+	LoginChallenged = "challenged"
+)
+
+// LoginAttempt records one try to log in, whether or not the email belongs to
+// an account. Failed attempts are what login throttling counts.
+type LoginAttempt struct {
+	ID        string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID    string    `json:"user_id" gorm:"index"`
+	Email     string    `json:"email" gorm:"type:varchar(255);not null;index:idx_login_attempts_email"`
+	IP        string    `json:"ip" gorm:"type:varchar(45);index:idx_login_attempts_ip"`
+	Device    string    `json:"device" gorm:"type:varchar(255)"`
+	Result    string    `json:"result" gorm:"type:varchar(20);not null"`
+	Reason    string    `json:"reason" gorm:"type:varchar(50)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
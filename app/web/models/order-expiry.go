@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+type OrderExpiryRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Scanned    int       `json:"scanned"`
+	Cancelled  int       `json:"cancelled"`
+	Failed     int       `json:"failed"`
+}
+
+type OrderExpiryStats struct {
+	Window         string          `json:"window"`
+	Interval       string          `json:"interval"`
+	Runs           int             `json:"runs"`
+	TotalScanned   int             `json:"total_scanned"`
+	TotalCancelled int             `json:"total_cancelled"`
+	TotalFailed    int             `json:"total_failed"`
+	LastRun        *OrderExpiryRun `json:"last_run"`
+}
+
+// OrderExpiryTotals is the one row the expiry job adds each run to, so every
+// replica reports the same statistics whichever of them ran the job.
+type OrderExpiryTotals struct {
+	ID             string         `json:"id" gorm:"not null;primary_key;type:varchar(100)"`
+	Runs           int            `json:"runs" gorm:"not null;default:0"`
+	TotalScanned   int            `json:"total_scanned" gorm:"not null;default:0"`
+	TotalCancelled int            `json:"total_cancelled" gorm:"not null;default:0"`
+	TotalFailed    int            `json:"total_failed" gorm:"not null;default:0"`
+	LastRun        OrderExpiryRun `json:"last_run" gorm:"embedded;embeddedPrefix:last_run_"`
+	UpdatedAt      time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func ToOrderExpiryStats(totals OrderExpiryTotals) OrderExpiryStats {
+	stats := OrderExpiryStats{
+		Runs:           totals.Runs,
+		TotalScanned:   totals.TotalScanned,
+		TotalCancelled: totals.TotalCancelled,
+		TotalFailed:    totals.TotalFailed,
+	}
+	if totals.Runs > 0 {
+		lastRun := totals.LastRun
+		stats.LastRun = &lastRun
+	}
+	return stats
+}
@@ -11,6 +11,7 @@ type OrderItem struct {
 	ProductID string    `json:"product_id" gorm:"not null;index"`
 	Product   Product   `gorm:"foreignKey:ProductID" json:"product"`
 	Quantity  uint32    `json:"quantity" gorm:"not null"`
+	Price     float64   `json:"price"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
@@ -21,6 +22,7 @@ type OrderItemResponse struct {
 	ProductID string    `json:"product_id"`
 	Product   Product   `json:"product"`
 	Quantity  uint32    `json:"quantity"`
+	Price     float64   `json:"price"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -29,11 +31,15 @@ type OrderItemCreateUpdate struct {
 	OrderID   string `json:"order_id"`
 	ProductID string `json:"product_id"`
 	Quantity  uint32 `json:"quantity" validate:"required"`
+	// AddressID is the address book entry to ship to, the default address
+	// when empty.
+	AddressID string `json:"address_id"`
 }
 
 type OrderItemDto struct {
 	ProductID string `json:"product_id" validate:"required"`
 	Quantity  uint32 `json:"quantity" validate:"required,min=1"`
+	AddressID string `json:"address_id"`
 }
 
 func ToOrderItemResponse(orderItem OrderItem) OrderItemResponse {
@@ -43,6 +49,7 @@ func ToOrderItemResponse(orderItem OrderItem) OrderItemResponse {
 		ProductID: orderItem.ProductID,
 		Product:   orderItem.Product,
 		Quantity:  orderItem.Quantity,
+		Price:     orderItem.Price,
 		CreatedAt: orderItem.CreatedAt,
 		UpdatedAt: orderItem.UpdatedAt,
 	}
@@ -5,30 +5,49 @@ import (
 )
 
 type Order struct {
-	ID           string      `json:"id" gorm:"not null;uniqueIndex;primary_key"`
-	UserID       string      `json:"user_id" gorm:"not null"`
-	OrderItems   []OrderItem `json:"order_items" gorm:"foreignKey:OrderID"`
-	IsPaid       bool        `json:"is_paid"`
-	Status       string      `json:"status"`
-	CustomerName string      `json:"customer_name"`
-	Phone        string      `json:"phone"`
-	TotalPrice   float64     `json:"total_price"`
-	Address      string      `json:"address"`
-	CreatedAt    time.Time   `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt    time.Time   `json:"updated_at" gorm:"autoUpdateTime"`
+	ID              string          `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID          string          `json:"user_id" gorm:"not null"`
+	OrderItems      []OrderItem     `json:"order_items" gorm:"foreignKey:OrderID"`
+	IsPaid          bool            `json:"is_paid"`
+	Status          string          `json:"status"`
+	CustomerName    string          `json:"customer_name"`
+	Phone           string          `json:"phone"`
+	TotalPrice      float64         `json:"total_price"`
+	RefundedAmount  float64         `json:"refunded_amount"`
+	Address         string          `json:"address"`
+	ShippingAddress ShippingAddress `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"`
+	CancelReason    string          `json:"cancel_reason"`
+	CancelledAt     *time.Time      `json:"cancelled_at"`
+	PaymentRef      string          `json:"payment_reference" gorm:"type:varchar(100)"`
+	PaidAt          *time.Time      `json:"paid_at"`
+	Carrier         string          `json:"carrier"`
+	TrackingNumber  string          `json:"tracking_number"`
+	ShippedAt       *time.Time      `json:"shipped_at"`
+	CreatedAt       time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type OrderResponse struct {
-	ID           string              `json:"id"`
-	UserID       string              `json:"user_id"`
-	OrderItems   []OrderItemResponse `json:"order_items"`
-	IsPaid       bool                `json:"is_paid"`
-	CustomerName string              `json:"customer_name"`
-	Phone        string              `json:"phone"`
-	Address      string              `json:"address"`
-	TotalPrice   float64             `json:"total_price"`
-	CreatedAt    time.Time           `json:"created_at"`
-	UpdatedAt    time.Time           `json:"updated_at"`
+	ID              string              `json:"id"`
+	UserID          string              `json:"user_id"`
+	OrderItems      []OrderItemResponse `json:"order_items"`
+	IsPaid          bool                `json:"is_paid"`
+	Status          string              `json:"status"`
+	CustomerName    string              `json:"customer_name"`
+	Phone           string              `json:"phone"`
+	Address         string              `json:"address"`
+	ShippingAddress ShippingAddress     `json:"shipping_address"`
+	TotalPrice      float64             `json:"total_price"`
+	RefundedAmount  float64             `json:"refunded_amount"`
+	CancelReason    string              `json:"cancel_reason"`
+	CancelledAt     *time.Time          `json:"cancelled_at"`
+	PaymentRef      string              `json:"payment_reference"`
+	PaidAt          *time.Time          `json:"paid_at"`
+	Carrier         string              `json:"carrier"`
+	TrackingNumber  string              `json:"tracking_number"`
+	ShippedAt       *time.Time          `json:"shipped_at"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
 }
 
 type OrderCreateUpdate struct {
@@ -40,6 +59,21 @@ type OrderCreateUpdate struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+type OrderCancel struct {
+	Reason string `json:"reason" validate:"required,min=4,max=255"`
+}
+
+// OrderPayment confirms a payment settled outside the application, the way
+// the manual gateway settles refunds.
+type OrderPayment struct {
+	Reference string `json:"reference" validate:"required,max=100"`
+}
+
+type OrderShip struct {
+	Carrier        string `json:"carrier" validate:"required,max=50"`
+	TrackingNumber string `json:"tracking_number" validate:"required,max=100"`
+}
+
 func ToOrderResponse(order Order) OrderResponse {
 	var orderItems []OrderItemResponse
 
@@ -47,16 +81,26 @@ func ToOrderResponse(order Order) OrderResponse {
 		orderItems = append(orderItems, ToOrderItemResponse(orderItem))
 	}
 	return OrderResponse{
-		ID:           order.ID,
-		UserID:       order.UserID,
-		OrderItems:   orderItems,
-		IsPaid:       order.IsPaid,
-		CustomerName: order.CustomerName,
-		Phone:        order.Phone,
-		Address:      order.Address,
-		TotalPrice:   order.TotalPrice,
-		CreatedAt:    order.CreatedAt,
-		UpdatedAt:    order.UpdatedAt,
+		ID:              order.ID,
+		UserID:          order.UserID,
+		OrderItems:      orderItems,
+		IsPaid:          order.IsPaid,
+		Status:          order.Status,
+		CustomerName:    order.CustomerName,
+		Phone:           order.Phone,
+		Address:         order.Address,
+		ShippingAddress: order.ShippingAddress,
+		TotalPrice:      order.TotalPrice,
+		RefundedAmount:  order.RefundedAmount,
+		CancelReason:    order.CancelReason,
+		CancelledAt:     order.CancelledAt,
+		PaymentRef:      order.PaymentRef,
+		PaidAt:          order.PaidAt,
+		Carrier:         order.Carrier,
+		TrackingNumber:  order.TrackingNumber,
+		ShippedAt:       order.ShippedAt,
+		CreatedAt:       order.CreatedAt,
+		UpdatedAt:       order.UpdatedAt,
 	}
 }
 
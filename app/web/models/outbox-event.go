@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent is a domain event waiting to be, or already, relayed to the bus.
+// Delivered lists, comma separated, the subscribers and sinks that have it,
+// so a retry only goes to the rest. After too many failed attempts the event
+// is parked with DeadAt set and no longer retried.
+type OutboxEvent struct {
+	ID            string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	Type          string     `json:"type" gorm:"not null;type:varchar(100);index"`
+	AggregateType string     `json:"aggregate_type" gorm:"not null;type:varchar(50)"`
+	AggregateID   string     `json:"aggregate_id" gorm:"not null;type:varchar(100);index"`
+	UserID        string     `json:"user_id" gorm:"type:varchar(100);index"`
+	Payload       string     `json:"payload" gorm:"type:text"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	Delivered     string     `json:"delivered" gorm:"type:text"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" gorm:"index"`
+	DeadAt        *time.Time `json:"dead_at" gorm:"index"`
+	PublishedAt   *time.Time `json:"published_at" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+type OutboxFilter struct {
+	AggregateType string
+	AggregateID   string
+	UserID        string
+}
+
+type StockChangedPayload struct {
+	ProductID string `json:"product_id"`
+	Delta     int64  `json:"delta"`
+	Stock     uint32 `json:"stock"`
+}
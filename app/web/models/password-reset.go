@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordResetToken lets a user who forgot their password choose a new one.
+// Only the hash is stored and a token works once.
+type PasswordResetToken struct {
+	ID        string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID    string     `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex;type:varchar(64)"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type PasswordForgot struct {
+	Email string `validate:"required,email" json:"email"`
+}
+
+type PasswordReset struct {
+	Token    string `validate:"required" json:"token"`
+	Password string `validate:"required,min=6,max=50" json:"password"`
+}
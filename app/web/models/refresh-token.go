@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is one link of a rotation chain. Every token issued from the
+// same login shares a FamilyID, so presenting a rotated token again can revoke
+// the whole chain.
+type RefreshToken struct {
+	ID         string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID     string     `json:"user_id" gorm:"not null;index"`
+	FamilyID   string     `json:"family_id" gorm:"not null;index"`
+	TokenHash  string     `json:"-" gorm:"not null;uniqueIndex;type:varchar(64)"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy string     `json:"replaced_by"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
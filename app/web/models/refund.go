@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+type Refund struct {
+	ID              string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	OrderID         string    `json:"order_id" gorm:"not null;index"`
+	ReturnRequestID string    `json:"return_request_id" gorm:"index"`
+	Amount          float64   `json:"amount" gorm:"not null"`
+	Status          string    `json:"status" gorm:"not null;type:varchar(20)"`
+	Reference       string    `json:"reference"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
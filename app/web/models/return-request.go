@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+)
+
+type ReturnRequest struct {
+	ID           string       `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	OrderID      string       `json:"order_id" gorm:"not null;index"`
+	Order        Order        `gorm:"foreignKey:OrderID" json:"order"`
+	UserID       string       `json:"user_id" gorm:"not null;index"`
+	Reason       string       `json:"reason" gorm:"not null;type:varchar(255)"`
+	Status       string       `json:"status" gorm:"not null;type:varchar(20)"`
+	ReviewNote   string       `json:"review_note"`
+	ReviewedBy   string       `json:"reviewed_by"`
+	RefundAmount float64      `json:"refund_amount"`
+	Items        []ReturnItem `gorm:"foreignKey:ReturnRequestID" json:"items"`
+	CreatedAt    time.Time    `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type ReturnItem struct {
+	ID              string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	ReturnRequestID string    `json:"return_request_id" gorm:"not null;index"`
+	OrderItemID     string    `json:"order_item_id" gorm:"not null;index"`
+	OrderItem       OrderItem `gorm:"foreignKey:OrderItemID" json:"order_item"`
+	Quantity        uint32    `json:"quantity" gorm:"not null"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type ReturnRequestResponse struct {
+	ID           string               `json:"id"`
+	OrderID      string               `json:"order_id"`
+	UserID       string               `json:"user_id"`
+	Reason       string               `json:"reason"`
+	Status       string               `json:"status"`
+	ReviewNote   string               `json:"review_note"`
+	ReviewedBy   string               `json:"reviewed_by"`
+	RefundAmount float64              `json:"refund_amount"`
+	Items        []ReturnItemResponse `json:"items"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+type ReturnItemResponse struct {
+	ID          string    `json:"id"`
+	OrderItemID string    `json:"order_item_id"`
+	ProductID   string    `json:"product_id"`
+	Quantity    uint32    `json:"quantity"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ReturnRequestCreate struct {
+	OrderID string          `json:"order_id" validate:"required"`
+	Reason  string          `json:"reason" validate:"required,min=4,max=255"`
+	Items   []ReturnItemDto `json:"items" validate:"required,min=1,dive"`
+}
+
+type ReturnItemDto struct {
+	OrderItemID string `json:"order_item_id" validate:"required"`
+	Quantity    uint32 `json:"quantity" validate:"required,min=1"`
+}
+
+type ReturnRequestReview struct {
+	Note string `json:"note" validate:"max=255"`
+	// RefundAmount issues a partial refund when set; otherwise the full value
+	// of the returned items is refunded.
+	RefundAmount float64 `json:"refund_amount" validate:"min=0"`
+}
+
+func ToReturnItemResponse(item ReturnItem) ReturnItemResponse {
+	return ReturnItemResponse{
+		ID:          item.ID,
+		OrderItemID: item.OrderItemID,
+		ProductID:   item.OrderItem.ProductID,
+		Quantity:    item.Quantity,
+		CreatedAt:   item.CreatedAt,
+	}
+}
+
+func ToReturnRequestResponse(returnRequest ReturnRequest) ReturnRequestResponse {
+	var items []ReturnItemResponse
+
+	for _, item := range returnRequest.Items {
+		items = append(items, ToReturnItemResponse(item))
+	}
+	return ReturnRequestResponse{
+		ID:           returnRequest.ID,
+		OrderID:      returnRequest.OrderID,
+		UserID:       returnRequest.UserID,
+		Reason:       returnRequest.Reason,
+		Status:       returnRequest.Status,
+		ReviewNote:   returnRequest.ReviewNote,
+		ReviewedBy:   returnRequest.ReviewedBy,
+		RefundAmount: returnRequest.RefundAmount,
+		Items:        items,
+		CreatedAt:    returnRequest.CreatedAt,
+		UpdatedAt:    returnRequest.UpdatedAt,
+	}
+}
+
+func ToReturnRequestResponses(returnRequests []ReturnRequest) []ReturnRequestResponse {
+	var responses []ReturnRequestResponse
+
+	for _, returnRequest := range returnRequests {
+		responses = append(responses, ToReturnRequestResponse(returnRequest))
+	}
+	return responses
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is one login of a user on a device. Its ID is the family ID of the
+// refresh tokens issued for it and the sid claim of its access tokens.
+type Session struct {
+	ID         string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID     string     `json:"user_id" gorm:"not null;index"`
+	Device     string     `json:"device" gorm:"type:varchar(255)"`
+	IP         string     `json:"ip" gorm:"type:varchar(45)"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ClientInfo describes where a request came from.
+type ClientInfo struct {
+	Device string
+	IP     string
+}
+
+func ToSessionResponse(session Session, currentSessionId string) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		Device:     session.Device,
+		IP:         session.IP,
+		Current:    session.ID == currentSessionId,
+		LastSeenAt: session.LastSeenAt,
+		CreatedAt:  session.CreatedAt,
+	}
+}
+
+func ToSessionResponses(sessions []Session, currentSessionId string) []SessionResponse {
+	var responses []SessionResponse
+
+	for _, session := range sessions {
+		responses = append(responses, ToSessionResponse(session, currentSessionId))
+	}
+	return responses
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey is a persisted token signing key. The private key is sealed with
+// the application secret so every replica can load the same keys.
+type SigningKey struct {
+	ID          string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	Algorithm   string     `json:"algorithm" gorm:"not null"`
+	PrivateKey  string     `json:"-" gorm:"not null;type:text"`
+	ActivatesAt time.Time  `json:"activates_at" gorm:"not null;index"`
+	RetiresAt   *time.Time `json:"retires_at" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
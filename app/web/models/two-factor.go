@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+)
+
+// TwoFactor is the TOTP authenticator of a user. The secret is sealed, and
+// login only asks for a code once the enrollment is confirmed with one.
+type TwoFactor struct {
+	UserID       string     `json:"user_id" gorm:"not null;uniqueIndex;primary_key"`
+	Secret       string     `json:"-" gorm:"type:text;not null"`
+	LastUsedStep int64      `json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// RecoveryCode logs a user in once in place of a TOTP code. Only the hash is
+// stored.
+type RecoveryCode struct {
+	ID        string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID    string     `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;index;type:varchar(64)"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// LoginChallenge is handed out when the password of a user with two-factor
+// authentication is right, and is exchanged with a code for the tokens.
+type LoginChallenge struct {
+	ID        string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID    string     `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex;type:varchar(64)"`
+	Attempts  int        `json:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorEnroll asks for the password again before an authenticator is
+// set up, so an unattended session cannot add one.
+type TwoFactorEnroll struct {
+	Password string `validate:"required" json:"password"`
+}
+
+// TwoFactorVerify proves both factors before two-factor authentication is
+// turned off or its recovery codes are replaced: the password, and a code
+// from the authenticator or an unused recovery code.
+type TwoFactorVerify struct {
+	Password string `validate:"required" json:"password"`
+	Code     string `validate:"required,max=50" json:"code"`
+}
+
+type TwoFactorConfirm struct {
+	Code string `validate:"required,numeric,len=6" json:"code"`
+}
+
+type TwoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorLogin completes a login with a TOTP code or a recovery code.
+type TwoFactorLogin struct {
+	ChallengeToken string `validate:"required" json:"challenge_token"`
+	Code           string `validate:"required,max=50" json:"code"`
+}
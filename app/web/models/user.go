@@ -4,33 +4,50 @@ import (
 	"time"
 )
 
+// User is an account. A disabled account, one with DisabledAt set, cannot log
+// in and its tokens and API keys are refused. A deleted account is disabled
+// and keeps only its ID, with AnonymizedAt set.
 type User struct {
-	ID        string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
-	Name      string    `json:"name" gorm:"not null;type:varchar(50)"`
-	Email     string    `json:"email" gorm:"not null;unique;type:varchar(100)"`
-	Password  string    `json:"password" gorm:"not null;type:varchar(100)"`
-	Phone     string    `json:"phone"`
-	Address   string    `json:"address"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID              string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	Name            string     `json:"name" gorm:"not null;type:varchar(50)"`
+	Email           string     `json:"email" gorm:"not null;unique;type:varchar(100)"`
+	Password        string     `json:"password" gorm:"not null;type:varchar(100)"`
+	Phone           string     `json:"phone"`
+	Address         string     `json:"address"`
+	Role            string     `json:"role" gorm:"not null;type:varchar(20);default:CUSTOMER"`
+	Locale          string     `json:"locale" gorm:"not null;type:varchar(10);default:en"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	DisabledAt      *time.Time `json:"disabled_at"`
+	AnonymizedAt    *time.Time `json:"anonymized_at"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type UserResponse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
-	Address   string    `json:"address"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Phone         string    `json:"phone"`
+	Address       string    `json:"address"`
+	Role          string    `json:"role"`
+	Locale        string    `json:"locale"`
+	EmailVerified bool      `json:"email_verified"`
+	Disabled      bool      `json:"disabled"`
+	Deleted       bool      `json:"deleted"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// UserLoginResponse carries the tokens, or for users with two-factor
+// authentication only the challenge token to complete the login with.
 type UserLoginResponse struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	Email        string `json:"email"`
-	Token        string `json:"token"`
-	RefreshToken string `json:"refresh_token"`
+	ID                string `json:"id,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+	Token             string `json:"token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
 }
 
 type UserCreate struct {
@@ -39,12 +56,59 @@ type UserCreate struct {
 	Phone    string `validate:"required,min=8,max=13"`
 	Password string `validate:"required,min=6,max=50"`
 	Address  string `validate:"required,min=6,max=100"`
+	Locale   string `validate:"omitempty,oneof=en id"`
 }
 
 type UserUpdate struct {
-	Phone    string `validate:"required,min=8,max=13"`
-	Password string `validate:"required,min=6,max=50"`
-	Address  string `validate:"required,min=6,max=100"`
+	Phone   string `validate:"required,min=8,max=13"`
+	Address string `validate:"required,min=6,max=100"`
+}
+
+// UserPatch changes only the fields that are sent.
+type UserPatch struct {
+	Name    *string `json:"name" validate:"omitnil,min=4,max=50"`
+	Phone   *string `json:"phone" validate:"omitnil,min=8,max=13"`
+	Address *string `json:"address" validate:"omitnil,min=6,max=100"`
+	Locale  *string `json:"locale" validate:"omitnil,oneof=en id"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required,min=6,max=50"`
+}
+
+// EmailChange asks to move the account to a new address, which takes effect
+// once the link mailed there is opened.
+type EmailChange struct {
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UserSearch filters and pages the users staff look through. Query matches
+// the start of a name or email, or a whole user ID.
+type UserSearch struct {
+	Query  string `json:"q" validate:"max=100"`
+	Role   string `json:"role" validate:"omitempty,oneof=CUSTOMER STAFF ADMIN"`
+	Status string `json:"status" validate:"omitempty,oneof=active disabled"`
+	Page   int    `json:"page" validate:"min=1"`
+	Size   int    `json:"size" validate:"min=1,max=100"`
+}
+
+type UserPage struct {
+	Users []UserResponse `json:"users"`
+	Page  int            `json:"page"`
+	Size  int            `json:"size"`
+	Total int64          `json:"total"`
+}
+
+// UserDetailResponse is a user as staff see them, with their orders.
+type UserDetailResponse struct {
+	User   UserResponse    `json:"user"`
+	Orders []OrderResponse `json:"orders"`
+}
+
+type UserRoleChange struct {
+	Role string `json:"role" validate:"required,oneof=CUSTOMER STAFF ADMIN"`
 }
 
 type UserLogin struct {
@@ -54,12 +118,26 @@ type UserLogin struct {
 
 func ToUserReponse(user User) UserResponse {
 	return UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Phone:     user.Phone,
-		Address:   user.Address,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		Phone:         user.Phone,
+		Address:       user.Address,
+		Role:          user.Role,
+		Locale:        user.Locale,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Disabled:      user.DisabledAt != nil,
+		Deleted:       user.AnonymizedAt != nil,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+func ToUserResponses(users []User) []UserResponse {
+	var responses []UserResponse
+
+	for _, user := range users {
+		responses = append(responses, ToUserReponse(user))
 	}
+	return responses
 }
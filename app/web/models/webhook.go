@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type WebhookSubscription struct {
+	ID         string    `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	UserID     string    `json:"user_id" gorm:"not null;index"`
+	URL        string    `json:"url" gorm:"not null;type:varchar(255)"`
+	Secret     string    `json:"-" gorm:"not null;type:varchar(100)"`
+	EventTypes string    `json:"event_types" gorm:"not null;type:varchar(255)"`
+	Active     bool      `json:"active" gorm:"not null;default:true"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type WebhookDelivery struct {
+	ID             string     `json:"id" gorm:"not null;uniqueIndex;primary_key"`
+	SubscriptionID string     `json:"subscription_id" gorm:"not null;uniqueIndex:idx_webhook_delivery_event"`
+	EventID        string     `json:"event_id" gorm:"not null;uniqueIndex:idx_webhook_delivery_event"`
+	EventType      string     `json:"event_type" gorm:"not null;type:varchar(100)"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	StatusCode     int        `json:"status_code"`
+	ResponseBody   string     `json:"response_body" gorm:"type:text"`
+	Error          string     `json:"error" gorm:"type:text"`
+	Succeeded      bool       `json:"succeeded"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type WebhookSubscriptionResponse struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookSubscriptionCreate struct {
+	URL        string   `json:"url" validate:"required,url,max=255"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=order.created order.paid order.cancelled order.shipped product.updated stock.changed"`
+	// Secret signs every delivery; one is generated when left empty.
+	Secret string `json:"secret" validate:"omitempty,min=16,max=100"`
+}
+
+type WebhookSubscriptionUpdate struct {
+	URL        string   `json:"url" validate:"required,url,max=255"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=order.created order.paid order.cancelled order.shipped product.updated stock.changed"`
+	Active     bool     `json:"active"`
+}
+
+type WebhookDeliveryJob struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+func (subscription WebhookSubscription) Subscribes(eventType string) bool {
+	for _, subscribed := range strings.Split(subscription.EventTypes, ",") {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func ToWebhookSubscriptionResponse(subscription WebhookSubscription) WebhookSubscriptionResponse {
+	return WebhookSubscriptionResponse{
+		ID:         subscription.ID,
+		URL:        subscription.URL,
+		EventTypes: strings.Split(subscription.EventTypes, ","),
+		Active:     subscription.Active,
+		CreatedAt:  subscription.CreatedAt,
+		UpdatedAt:  subscription.UpdatedAt,
+	}
+}
+
+func ToWebhookSubscriptionResponses(subscriptions []WebhookSubscription) []WebhookSubscriptionResponse {
+	var responses []WebhookSubscriptionResponse
+
+	for _, subscription := range subscriptions {
+		responses = append(responses, ToWebhookSubscriptionResponse(subscription))
+	}
+	return responses
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type AddressRepository interface {
+	Create(ctx context.Context, db *gorm.DB, address models.Address) (models.Address, error)
+	Update(ctx context.Context, db *gorm.DB, address models.Address) (models.Address, error)
+	Delete(ctx context.Context, db *gorm.DB, addressId string) error
+	FindAddress(ctx context.Context, db *gorm.DB, addressId string) (models.Address, error)
+	FindAddresses(ctx context.Context, db *gorm.DB, userId string) ([]models.Address, error)
+	FindDefault(ctx context.Context, db *gorm.DB, userId string) (models.Address, error)
+	ClearDefault(ctx context.Context, db *gorm.DB, userId string) error
+}
+
+type addressRepositoryImpl struct {
+}
+
+func NewAddressRepository() AddressRepository {
+	return &addressRepositoryImpl{}
+}
+
+func (r *addressRepositoryImpl) Create(ctx context.Context, db *gorm.DB, address models.Address) (models.Address, error) {
+	err := db.WithContext(ctx).Create(&address).Error
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	return address, nil
+}
+
+func (r *addressRepositoryImpl) Update(ctx context.Context, db *gorm.DB, address models.Address) (models.Address, error) {
+	err := db.WithContext(ctx).Save(&address).Error
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	return address, nil
+}
+
+func (r *addressRepositoryImpl) Delete(ctx context.Context, db *gorm.DB, addressId string) error {
+	return db.WithContext(ctx).Where("id = ?", addressId).Delete(&models.Address{}).Error
+}
+
+func (r *addressRepositoryImpl) FindAddress(ctx context.Context, db *gorm.DB, addressId string) (models.Address, error) {
+	var address models.Address
+
+	err := db.WithContext(ctx).Where("id = ?", addressId).Take(&address).Error
+	return address, err
+}
+
+// FindAddresses lists the address book of a user, the default address first.
+func (r *addressRepositoryImpl) FindAddresses(ctx context.Context, db *gorm.DB, userId string) ([]models.Address, error) {
+	var addresses []models.Address
+
+	err := db.WithContext(ctx).
+		Where("user_id = ?", userId).
+		Order("is_default desc").
+		Order("created_at").
+		Find(&addresses).Error
+	return addresses, err
+}
+
+func (r *addressRepositoryImpl) FindDefault(ctx context.Context, db *gorm.DB, userId string) (models.Address, error) {
+	var address models.Address
+
+	err := db.WithContext(ctx).Where("user_id = ? AND is_default = ?", userId, true).Take(&address).Error
+	return address, err
+}
+
+func (r *addressRepositoryImpl) ClearDefault(ctx context.Context, db *gorm.DB, userId string) error {
+	return db.WithContext(ctx).Model(&models.Address{}).
+		Where("user_id = ? AND is_default = ?", userId, true).
+		Update("is_default", false).Error
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, db *gorm.DB, key models.APIKey) (models.APIKey, error)
+	FindKey(ctx context.Context, db *gorm.DB, keyId string) (models.APIKey, error)
+	FindByPrefix(ctx context.Context, db *gorm.DB, prefix string) (models.APIKey, error)
+	FindActiveKeys(ctx context.Context, db *gorm.DB, userId string) ([]models.APIKey, error)
+	Revoke(ctx context.Context, db *gorm.DB, keyId string) error
+	Touch(ctx context.Context, db *gorm.DB, keyId string, usedAt time.Time, interval time.Duration) error
+}
+
+type apiKeyRepositoryImpl struct {
+}
+
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepositoryImpl{}
+}
+
+func (r *apiKeyRepositoryImpl) Create(ctx context.Context, db *gorm.DB, key models.APIKey) (models.APIKey, error) {
+	err := db.WithContext(ctx).Create(&key).Error
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepositoryImpl) FindKey(ctx context.Context, db *gorm.DB, keyId string) (models.APIKey, error) {
+	var key models.APIKey
+
+	err := db.WithContext(ctx).Where("id = ?", keyId).Take(&key).Error
+	return key, err
+}
+
+func (r *apiKeyRepositoryImpl) FindByPrefix(ctx context.Context, db *gorm.DB, prefix string) (models.APIKey, error) {
+	var key models.APIKey
+
+	err := db.WithContext(ctx).Where("prefix = ?", prefix).Take(&key).Error
+	return key, err
+}
+
+func (r *apiKeyRepositoryImpl) FindActiveKeys(ctx context.Context, db *gorm.DB, userId string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+
+	err := db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Order("created_at desc").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepositoryImpl) Revoke(ctx context.Context, db *gorm.DB, keyId string) error {
+	return db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", keyId).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Touch records that the key was used, at most once per interval, so a busy
+// integration does not write on every request.
+func (r *apiKeyRepositoryImpl) Touch(ctx context.Context, db *gorm.DB, keyId string, usedAt time.Time, interval time.Duration) error {
+	return db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", keyId, usedAt.Add(-interval)).
+		Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, db *gorm.DB, token models.EmailVerificationToken) (models.EmailVerificationToken, error)
+	FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.EmailVerificationToken, error)
+	FindLatest(ctx context.Context, db *gorm.DB, userId string) (models.EmailVerificationToken, error)
+	CountSince(ctx context.Context, db *gorm.DB, userId string, since time.Time) (int64, error)
+	Use(ctx context.Context, db *gorm.DB, tokenId string) (bool, error)
+	InvalidateUser(ctx context.Context, db *gorm.DB, userId string) error
+}
+
+type emailVerificationRepositoryImpl struct {
+}
+
+func NewEmailVerificationRepository() EmailVerificationRepository {
+	return &emailVerificationRepositoryImpl{}
+}
+
+func (r *emailVerificationRepositoryImpl) Create(ctx context.Context, db *gorm.DB, token models.EmailVerificationToken) (models.EmailVerificationToken, error) {
+	err := db.WithContext(ctx).Create(&token).Error
+	if err != nil {
+		return models.EmailVerificationToken{}, err
+	}
+
+	return token, nil
+}
+
+func (r *emailVerificationRepositoryImpl) FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+
+	err := db.WithContext(ctx).Where("token_hash = ?", tokenHash).Take(&token).Error
+	return token, err
+}
+
+func (r *emailVerificationRepositoryImpl) FindLatest(ctx context.Context, db *gorm.DB, userId string) (models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+
+	err := db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at desc").Take(&token).Error
+	return token, err
+}
+
+func (r *emailVerificationRepositoryImpl) CountSince(ctx context.Context, db *gorm.DB, userId string, since time.Time) (int64, error) {
+	var count int64
+
+	err := db.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND created_at > ?", userId, since).
+		Count(&count).Error
+	return count, err
+}
+
+// Use marks the token used and reports whether this call was the one that did.
+func (r *emailVerificationRepositoryImpl) Use(ctx context.Context, db *gorm.DB, tokenId string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", tokenId).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *emailVerificationRepositoryImpl) InvalidateUser(ctx context.Context, db *gorm.DB, userId string) error {
+	return db.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userId).
+		Update("used_at", time.Now()).Error
+}
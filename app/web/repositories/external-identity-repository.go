@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type ExternalIdentityRepository interface {
+	Create(ctx context.Context, db *gorm.DB, identity models.ExternalIdentity) (models.ExternalIdentity, error)
+	FindBySubject(ctx context.Context, db *gorm.DB, provider string, subject string) (models.ExternalIdentity, error)
+	CreateState(ctx context.Context, db *gorm.DB, state models.OIDCLoginState) (models.OIDCLoginState, error)
+	FindState(ctx context.Context, db *gorm.DB, stateHash string) (models.OIDCLoginState, error)
+	UseState(ctx context.Context, db *gorm.DB, stateId string) (bool, error)
+}
+
+type externalIdentityRepositoryImpl struct {
+}
+
+func NewExternalIdentityRepository() ExternalIdentityRepository {
+	return &externalIdentityRepositoryImpl{}
+}
+
+func (r *externalIdentityRepositoryImpl) Create(ctx context.Context, db *gorm.DB, identity models.ExternalIdentity) (models.ExternalIdentity, error) {
+	err := db.WithContext(ctx).Create(&identity).Error
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+
+	return identity, nil
+}
+
+func (r *externalIdentityRepositoryImpl) FindBySubject(ctx context.Context, db *gorm.DB, provider string, subject string) (models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+
+	err := db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).Take(&identity).Error
+	return identity, err
+}
+
+func (r *externalIdentityRepositoryImpl) CreateState(ctx context.Context, db *gorm.DB, state models.OIDCLoginState) (models.OIDCLoginState, error) {
+	err := db.WithContext(ctx).Create(&state).Error
+	if err != nil {
+		return models.OIDCLoginState{}, err
+	}
+
+	return state, nil
+}
+
+func (r *externalIdentityRepositoryImpl) FindState(ctx context.Context, db *gorm.DB, stateHash string) (models.OIDCLoginState, error) {
+	var state models.OIDCLoginState
+
+	err := db.WithContext(ctx).Where("state_hash = ?", stateHash).Take(&state).Error
+	return state, err
+}
+
+// UseState marks the state used and reports whether this call was the one
+// that did.
+func (r *externalIdentityRepositoryImpl) UseState(ctx context.Context, db *gorm.DB, stateId string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.OIDCLoginState{}).
+		Where("id = ? AND used_at IS NULL", stateId).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
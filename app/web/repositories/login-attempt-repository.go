@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, db *gorm.DB, attempt models.LoginAttempt) (models.LoginAttempt, error)
+	LastSuccess(ctx context.Context, db *gorm.DB, email string) (time.Time, error)
+	EmailFailures(ctx context.Context, db *gorm.DB, email string, since time.Time) ([]time.Time, error)
+	IPFailures(ctx context.Context, db *gorm.DB, ip string, since time.Time) ([]time.Time, error)
+}
+
+type loginAttemptRepositoryImpl struct {
+}
+
+func NewLoginAttemptRepository() LoginAttemptRepository {
+	return &loginAttemptRepositoryImpl{}
+}
+
+func (r *loginAttemptRepositoryImpl) Create(ctx context.Context, db *gorm.DB, attempt models.LoginAttempt) (models.LoginAttempt, error) {
+	err := db.WithContext(ctx).Create(&attempt).Error
+	if err != nil {
+		return models.LoginAttempt{}, err
+	}
+
+	return attempt, nil
+}
+
+// LastSuccess returns when the email last logged in, or the zero time.
+func (r *loginAttemptRepositoryImpl) LastSuccess(ctx context.Context, db *gorm.DB, email string) (time.Time, error) {
+	var attempts []models.LoginAttempt
+
+	err := db.WithContext(ctx).
+		Where("email = ? AND result = ?", email, models.LoginSucceeded).
+		Order("created_at desc").Limit(1).
+		Find(&attempts).Error
+	if err != nil || len(attempts) == 0 {
+		return time.Time{}, err
+	}
+	return attempts[0].CreatedAt, nil
+}
+
+// EmailFailures returns when the failed attempts of the email since then
+// were made, oldest first.
+func (r *loginAttemptRepositoryImpl) EmailFailures(ctx context.Context, db *gorm.DB, email string, since time.Time) ([]time.Time, error) {
+	return r.failures(ctx, db, "email = ?", email, since)
+}
+
+// IPFailures returns when the failed attempts from the IP since then were
+// made, oldest first.
+func (r *loginAttemptRepositoryImpl) IPFailures(ctx context.Context, db *gorm.DB, ip string, since time.Time) ([]time.Time, error) {
+	return r.failures(ctx, db, "ip = ?", ip, since)
+}
+
+func (r *loginAttemptRepositoryImpl) failures(ctx context.Context, db *gorm.DB, condition string, value string, since time.Time) ([]time.Time, error) {
+	var attempts []models.LoginAttempt
+
+	err := db.WithContext(ctx).Select("created_at").
+		Where(condition, value).
+		Where("result = ? AND created_at > ?", models.LoginFailed, since).
+		Order("created_at").
+		Find(&attempts).Error
+
+	failures := make([]time.Time, len(attempts))
+	for i, attempt := range attempts {
+		failures[i] = attempt.CreatedAt
+	}
+	return failures, err
+}
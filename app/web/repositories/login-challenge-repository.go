@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type LoginChallengeRepository interface {
+	Create(ctx context.Context, db *gorm.DB, challenge models.LoginChallenge) (models.LoginChallenge, error)
+	FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.LoginChallenge, error)
+	AddAttempt(ctx context.Context, db *gorm.DB, challengeId string, maxAttempts int) (bool, error)
+	Use(ctx context.Context, db *gorm.DB, challengeId string) (bool, error)
+}
+
+type loginChallengeRepositoryImpl struct {
+}
+
+func NewLoginChallengeRepository() LoginChallengeRepository {
+	return &loginChallengeRepositoryImpl{}
+}
+
+func (r *loginChallengeRepositoryImpl) Create(ctx context.Context, db *gorm.DB, challenge models.LoginChallenge) (models.LoginChallenge, error) {
+	err := db.WithContext(ctx).Create(&challenge).Error
+	if err != nil {
+		return models.LoginChallenge{}, err
+	}
+
+	return challenge, nil
+}
+
+func (r *loginChallengeRepositoryImpl) FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+
+	err := db.WithContext(ctx).Where("token_hash = ?", tokenHash).Take(&challenge).Error
+	return challenge, err
+}
+
+// AddAttempt counts a code tried against the challenge and reports whether
+// it was still below maxAttempts.
+func (r *loginChallengeRepositoryImpl) AddAttempt(ctx context.Context, db *gorm.DB, challengeId string, maxAttempts int) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.LoginChallenge{}).
+		Where("id = ? AND attempts < ? AND used_at IS NULL", challengeId, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected == 1, result.Error
+}
+
+// Use marks the challenge used and reports whether this call was the one
+// that did.
+func (r *loginChallengeRepositoryImpl) Use(ctx context.Context, db *gorm.DB, challengeId string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", challengeId).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
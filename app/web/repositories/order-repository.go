@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"zen-test/app/consts"
@@ -9,15 +10,21 @@ import (
 	"zen-test/app/web/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository interface {
 	CreateOrder(ctx context.Context, db *gorm.DB, order models.Order) (models.Order, error)
 	UpdateOrder(ctx context.Context, db *gorm.DB, order models.Order) (models.Order, error)
 	CreateOrderItem(ctx context.Context, db *gorm.DB, orderItem models.OrderItem) (models.OrderItem, error)
-	FindAllOrder(ctx context.Context, db *gorm.DB) ([]models.Order, error)
+	FindAllOrder(ctx context.Context, db *gorm.DB, userId string) ([]models.Order, error)
 	GetUnpaidOrdersOlderThan(ctx context.Context, tx *gorm.DB, duration time.Duration) ([]models.Order, error)
 	FindOrder(ctx context.Context, db *gorm.DB, orderId string) (models.Order, error)
+	LookupOrder(ctx context.Context, db *gorm.DB, orderId string) (models.Order, error)
+	LockOrder(ctx context.Context, db *gorm.DB, orderId string) (models.Order, error)
+	AnonymizeOrders(ctx context.Context, db *gorm.DB, userId string) error
+	RecordExpiryRun(ctx context.Context, db *gorm.DB, run models.OrderExpiryRun) error
+	FindExpiryTotals(ctx context.Context, db *gorm.DB) (models.OrderExpiryTotals, error)
 }
 
 type orderRepositoryImpl struct {
@@ -49,18 +56,34 @@ func (r *orderRepositoryImpl) UpdateOrder(ctx context.Context, db *gorm.DB, orde
 }
 
 func (r *orderRepositoryImpl) FindOrder(ctx context.Context, db *gorm.DB, orderId string) (models.Order, error) {
+	order, err := r.LookupOrder(ctx, db, orderId)
+	helpers.PanicIfError(err)
+
+	return order, nil
+}
+
+// LookupOrder is FindOrder reporting a missing order as an error rather than
+// panicking, for callers that answer not found.
+func (r *orderRepositoryImpl) LookupOrder(ctx context.Context, db *gorm.DB, orderId string) (models.Order, error) {
+	return takeOrder(db.WithContext(ctx), orderId)
+}
+
+// LockOrder is LookupOrder holding the order row until the transaction ends,
+// so two requests cannot both act on the status they read.
+func (r *orderRepositoryImpl) LockOrder(ctx context.Context, db *gorm.DB, orderId string) (models.Order, error) {
+	return takeOrder(db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}), orderId)
+}
+
+func takeOrder(db *gorm.DB, orderId string) (models.Order, error) {
 	var order models.Order
 
-	err := db.WithContext(ctx).
-		Model(&models.Order{}).
+	err := db.Model(&models.Order{}).
 		Preload("OrderItems").
 		Preload("OrderItems.Product").
 		Preload("OrderItems.Product.Images").
 		Where("id = ?", orderId).
 		Take(&order).Error
-	helpers.PanicIfError(err)
-
-	return order, nil
+	return order, err
 }
 
 func (r *orderRepositoryImpl) CreateOrderItem(ctx context.Context, db *gorm.DB, orderItem models.OrderItem) (models.OrderItem, error) {
@@ -78,16 +101,77 @@ func (r *orderRepositoryImpl) GetUnpaidOrdersOlderThan(ctx context.Context, tx *
 	return orders, err
 }
 
-func (r *orderRepositoryImpl) FindAllOrder(ctx context.Context, db *gorm.DB) ([]models.Order, error) {
+// FindAllOrder finds the orders of a user, or of everyone when userId is empty.
+func (r *orderRepositoryImpl) FindAllOrder(ctx context.Context, db *gorm.DB, userId string) ([]models.Order, error) {
 	var Orders []models.Order
 
-	err := db.WithContext(ctx).
+	query := db.WithContext(ctx).
 		Model(&models.Order{}).
 		Preload("OrderItems").
 		Preload("OrderItems.Product").
-		Preload("OrderItems.Product.Images").
-		Find(&Orders).Error
+		Preload("OrderItems.Product.Images")
+	if userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+
+	err := query.Find(&Orders).Error
 	helpers.PanicIfError(err)
 
 	return Orders, nil
 }
+
+// AnonymizeOrders blanks who the orders of the user went to, keeping the
+// amounts, items and the city, province and country they shipped to.
+func (r *orderRepositoryImpl) AnonymizeOrders(ctx context.Context, db *gorm.DB, userId string) error {
+	return db.WithContext(ctx).Model(&models.Order{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"customer_name":        anonymizedName,
+		"phone":                "",
+		"address":              "",
+		"shipping_recipient":   "",
+		"shipping_street":      "",
+		"shipping_postal_code": "",
+		"shipping_phone":       "",
+	}).Error
+}
+
+// RecordExpiryRun adds a run of the expiry job to its totals and keeps it as
+// the last run. The totals are added to in the statement, so replicas taking
+// turns at the job do not overwrite each other.
+func (r *orderRepositoryImpl) RecordExpiryRun(ctx context.Context, db *gorm.DB, run models.OrderExpiryRun) error {
+	totals := models.OrderExpiryTotals{
+		ID:             consts.JobTypeOrderExpiry,
+		Runs:           1,
+		TotalScanned:   run.Scanned,
+		TotalCancelled: run.Cancelled,
+		TotalFailed:    run.Failed,
+		LastRun:        run,
+	}
+
+	return db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"runs":                 gorm.Expr("runs + 1"),
+			"total_scanned":        gorm.Expr("total_scanned + ?", run.Scanned),
+			"total_cancelled":      gorm.Expr("total_cancelled + ?", run.Cancelled),
+			"total_failed":         gorm.Expr("total_failed + ?", run.Failed),
+			"last_run_started_at":  run.StartedAt,
+			"last_run_finished_at": run.FinishedAt,
+			"last_run_scanned":     run.Scanned,
+			"last_run_cancelled":   run.Cancelled,
+			"last_run_failed":      run.Failed,
+			"updated_at":           time.Now(),
+		}),
+	}).Create(&totals).Error
+}
+
+// FindExpiryTotals returns the totals of the expiry job, all zero before its
+// first run.
+func (r *orderRepositoryImpl) FindExpiryTotals(ctx context.Context, db *gorm.DB) (models.OrderExpiryTotals, error) {
+	var totals models.OrderExpiryTotals
+
+	err := db.WithContext(ctx).Where("id = ?", consts.JobTypeOrderExpiry).Take(&totals).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.OrderExpiryTotals{ID: consts.JobTypeOrderExpiry}, nil
+	}
+	return totals, err
+}
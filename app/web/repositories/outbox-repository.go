@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository interface {
+	SaveEvent(ctx context.Context, db *gorm.DB, event models.OutboxEvent) (models.OutboxEvent, error)
+	FindUnpublished(ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]models.OutboxEvent, error)
+	MarkPublished(ctx context.Context, db *gorm.DB, eventId string) error
+	MarkFailed(ctx context.Context, db *gorm.DB, eventId string, cause string, delivered string, retryAt time.Time) error
+	MarkDead(ctx context.Context, db *gorm.DB, eventId string, cause string, delivered string) error
+	AnonymizeEvents(ctx context.Context, db *gorm.DB, userId string) ([]string, error)
+	FindEvent(ctx context.Context, db *gorm.DB, eventId string) (models.OutboxEvent, error)
+	FindPublishedSince(ctx context.Context, db *gorm.DB, since time.Time, filter models.OutboxFilter, limit int) ([]models.OutboxEvent, error)
+}
+
+type outboxRepositoryImpl struct {
+}
+
+func NewOutboxRepository() OutboxRepository {
+	return &outboxRepositoryImpl{}
+}
+
+func (r *outboxRepositoryImpl) SaveEvent(ctx context.Context, db *gorm.DB, event models.OutboxEvent) (models.OutboxEvent, error) {
+	err := db.WithContext(ctx).Create(&event).Error
+	if err != nil {
+		return models.OutboxEvent{}, err
+	}
+
+	return event, nil
+}
+
+// FindUnpublished returns the pending events that may be relayed at now, in
+// the order they were written. Events of an aggregate wait behind an earlier
+// one that is backing off, so they are never relayed out of order; parked
+// events hold nothing up.
+func (r *outboxRepositoryImpl) FindUnpublished(ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+
+	err := db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("published_at IS NULL AND dead_at IS NULL").
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Where(`NOT EXISTS (SELECT 1 FROM outbox_events earlier
+			WHERE earlier.aggregate_type = outbox_events.aggregate_type
+			AND earlier.aggregate_id = outbox_events.aggregate_id
+			AND earlier.published_at IS NULL AND earlier.dead_at IS NULL
+			AND earlier.next_attempt_at > ?
+			AND (earlier.created_at < outbox_events.created_at OR (earlier.created_at = outbox_events.created_at AND earlier.id < outbox_events.id)))`, now).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&events).Error
+
+	return events, err
+}
+
+func (r *outboxRepositoryImpl) MarkPublished(ctx context.Context, db *gorm.DB, eventId string) error {
+	return db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("id = ?", eventId).
+		Updates(map[string]interface{}{"published_at": time.Now(), "last_error": ""}).
+		Error
+}
+
+// MarkFailed records a failed attempt and when to try the event again.
+func (r *outboxRepositoryImpl) MarkFailed(ctx context.Context, db *gorm.DB, eventId string, cause string, delivered string, retryAt time.Time) error {
+	return db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("id = ?", eventId).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      cause,
+			"delivered":       delivered,
+			"next_attempt_at": retryAt,
+		}).Error
+}
+
+// MarkDead records the last failed attempt and parks the event.
+func (r *outboxRepositoryImpl) MarkDead(ctx context.Context, db *gorm.DB, eventId string, cause string, delivered string) error {
+	return db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("id = ?", eventId).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": cause,
+			"delivered":  delivered,
+			"dead_at":    time.Now(),
+		}).Error
+}
+
+func (r *outboxRepositoryImpl) FindEvent(ctx context.Context, db *gorm.DB, eventId string) (models.OutboxEvent, error) {
+	var event models.OutboxEvent
+
+	err := db.WithContext(ctx).Where("id = ?", eventId).Take(&event).Error
+	return event, err
+}
+
+// FindPublishedSince returns events published at or after since, in the order
+// they were published. Empty filter fields match every event.
+func (r *outboxRepositoryImpl) FindPublishedSince(ctx context.Context, db *gorm.DB, since time.Time, filter models.OutboxFilter, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+
+	query := db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("published_at IS NOT NULL AND published_at >= ?", since)
+
+	if filter.AggregateType != "" {
+		query = query.Where("aggregate_type = ?", filter.AggregateType)
+	}
+	if filter.AggregateID != "" {
+		query = query.Where("aggregate_id = ?", filter.AggregateID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	err := query.Order("published_at, id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// AnonymizeEvents empties the payloads of the events about the user and their
+// orders, which carry their profile and shipping details, and returns the IDs
+// of those events.
+func (r *outboxRepositoryImpl) AnonymizeEvents(ctx context.Context, db *gorm.DB, userId string) ([]string, error) {
+	db = db.WithContext(ctx)
+
+	var eventIds []string
+	err := db.Model(&models.OutboxEvent{}).
+		Where("user_id = ?", userId).
+		Or("aggregate_type = ? AND aggregate_id = ?", "user", userId).
+		Or("aggregate_type = ? AND aggregate_id IN (?)", "order", db.Model(&models.Order{}).Select("id").Where("user_id = ?", userId)).
+		Pluck("id", &eventIds).Error
+	if err != nil || len(eventIds) == 0 {
+		return nil, err
+	}
+
+	err = db.Model(&models.OutboxEvent{}).Where("id IN ?", eventIds).Update("payload", "{}").Error
+	if err != nil {
+		return nil, err
+	}
+	return eventIds, nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, db *gorm.DB, token models.PasswordResetToken) (models.PasswordResetToken, error)
+	FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.PasswordResetToken, error)
+	FindLatest(ctx context.Context, db *gorm.DB, userId string) (models.PasswordResetToken, error)
+	Use(ctx context.Context, db *gorm.DB, tokenId string) (bool, error)
+	InvalidateUser(ctx context.Context, db *gorm.DB, userId string) error
+}
+
+type passwordResetRepositoryImpl struct {
+}
+
+func NewPasswordResetRepository() PasswordResetRepository {
+	return &passwordResetRepositoryImpl{}
+}
+
+func (r *passwordResetRepositoryImpl) Create(ctx context.Context, db *gorm.DB, token models.PasswordResetToken) (models.PasswordResetToken, error) {
+	err := db.WithContext(ctx).Create(&token).Error
+	if err != nil {
+		return models.PasswordResetToken{}, err
+	}
+
+	return token, nil
+}
+
+func (r *passwordResetRepositoryImpl) FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+
+	err := db.WithContext(ctx).Where("token_hash = ?", tokenHash).Take(&token).Error
+	return token, err
+}
+
+func (r *passwordResetRepositoryImpl) FindLatest(ctx context.Context, db *gorm.DB, userId string) (models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+
+	err := db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at desc").Take(&token).Error
+	return token, err
+}
+
+// Use marks the token used and reports whether this call was the one that did,
+// so two concurrent resets cannot both succeed.
+func (r *passwordResetRepositoryImpl) Use(ctx context.Context, db *gorm.DB, tokenId string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", tokenId).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *passwordResetRepositoryImpl) InvalidateUser(ctx context.Context, db *gorm.DB, userId string) error {
+	return db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userId).
+		Update("used_at", time.Now()).Error
+}
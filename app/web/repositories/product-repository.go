@@ -18,6 +18,7 @@ type ProductRepository interface {
 	DeleteProduct(ctx context.Context, db *gorm.DB, product models.Product) error
 	GetProductById(ctx context.Context, db *gorm.DB, productId string) (models.Product, error)
 	FindAllProducts(ctx context.Context, db *gorm.DB) ([]models.Product, error)
+	IncreaseStock(ctx context.Context, db *gorm.DB, productId string, quantity uint32) error
 }
 
 func NewProductRepository() ProductRepository {
@@ -73,3 +74,10 @@ func (r *ProductRepositoryImpl) FindAllProducts(ctx context.Context, db *gorm.DB
 
 	return products, nil
 }
+
+func (r *ProductRepositoryImpl) IncreaseStock(ctx context.Context, db *gorm.DB, productId string, quantity uint32) error {
+	return db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ?", productId).
+		Update("stock", gorm.Expr("stock + ?", quantity)).
+		Error
+}
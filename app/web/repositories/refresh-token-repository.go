@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, db *gorm.DB, token models.RefreshToken) (models.RefreshToken, error)
+	FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.RefreshToken, error)
+	Rotate(ctx context.Context, db *gorm.DB, tokenId string, replacedBy string) (bool, error)
+	RevokeFamily(ctx context.Context, db *gorm.DB, familyId string) error
+	RevokeUser(ctx context.Context, db *gorm.DB, userId string) error
+}
+
+type refreshTokenRepositoryImpl struct {
+}
+
+func NewRefreshTokenRepository() RefreshTokenRepository {
+	return &refreshTokenRepositoryImpl{}
+}
+
+func (r *refreshTokenRepositoryImpl) Create(ctx context.Context, db *gorm.DB, token models.RefreshToken) (models.RefreshToken, error) {
+	err := db.WithContext(ctx).Create(&token).Error
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+
+	return token, nil
+}
+
+func (r *refreshTokenRepositoryImpl) FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	err := db.WithContext(ctx).Where("token_hash = ?", tokenHash).Take(&token).Error
+	return token, err
+}
+
+// Rotate revokes a token in favour of its replacement. It reports false when
+// the token was already revoked, which means it has been used before.
+func (r *refreshTokenRepositoryImpl) Rotate(ctx context.Context, db *gorm.DB, tokenId string, replacedBy string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", tokenId).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by": replacedBy})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepositoryImpl) RevokeFamily(ctx context.Context, db *gorm.DB, familyId string) error {
+	return db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepositoryImpl) RevokeUser(ctx context.Context, db *gorm.DB, userId string) error {
+	return db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
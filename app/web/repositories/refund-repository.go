@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type RefundRepository interface {
+	CreateRefund(ctx context.Context, db *gorm.DB, refund models.Refund) (models.Refund, error)
+}
+
+type refundRepositoryImpl struct {
+}
+
+func NewRefundRepository() RefundRepository {
+	return &refundRepositoryImpl{}
+}
+
+func (r *refundRepositoryImpl) CreateRefund(ctx context.Context, db *gorm.DB, refund models.Refund) (models.Refund, error) {
+	err := db.WithContext(ctx).Create(&refund).Error
+	if err != nil {
+		return models.Refund{}, err
+	}
+
+	return refund, nil
+}
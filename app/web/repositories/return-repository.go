@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+
+	"zen-test/app/consts"
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type ReturnRepository interface {
+	CreateReturnRequest(ctx context.Context, db *gorm.DB, returnRequest models.ReturnRequest) (models.ReturnRequest, error)
+	UpdateReturnRequest(ctx context.Context, db *gorm.DB, returnRequest models.ReturnRequest) (models.ReturnRequest, error)
+	MoveReturnRequest(ctx context.Context, db *gorm.DB, returnId string, from string, to string) (bool, error)
+	FindReturnRequest(ctx context.Context, db *gorm.DB, returnId string) (models.ReturnRequest, error)
+	FindReturnRequests(ctx context.Context, db *gorm.DB, userId string) ([]models.ReturnRequest, error)
+	CountReturnedQuantity(ctx context.Context, db *gorm.DB, orderItemId string) (uint32, error)
+}
+
+type returnRepositoryImpl struct {
+}
+
+func NewReturnRepository() ReturnRepository {
+	return &returnRepositoryImpl{}
+}
+
+func (r *returnRepositoryImpl) CreateReturnRequest(ctx context.Context, db *gorm.DB, returnRequest models.ReturnRequest) (models.ReturnRequest, error) {
+	err := db.WithContext(ctx).Omit("Order", "Items.OrderItem").Create(&returnRequest).Error
+	if err != nil {
+		return models.ReturnRequest{}, err
+	}
+
+	return returnRequest, nil
+}
+
+func (r *returnRepositoryImpl) UpdateReturnRequest(ctx context.Context, db *gorm.DB, returnRequest models.ReturnRequest) (models.ReturnRequest, error) {
+	err := db.WithContext(ctx).
+		Model(&models.ReturnRequest{}).
+		Where("id = ?", returnRequest.ID).
+		Omit("Order", "Items").
+		Updates(&returnRequest).Error
+	if err != nil {
+		return models.ReturnRequest{}, err
+	}
+
+	return returnRequest, nil
+}
+
+// MoveReturnRequest sets the status of the return request to to, only if it
+// is still from; moved reports whether it was.
+func (r *returnRepositoryImpl) MoveReturnRequest(ctx context.Context, db *gorm.DB, returnId string, from string, to string) (bool, error) {
+	result := db.WithContext(ctx).
+		Model(&models.ReturnRequest{}).
+		Where("id = ? AND status = ?", returnId, from).
+		Update("status", to)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *returnRepositoryImpl) FindReturnRequest(ctx context.Context, db *gorm.DB, returnId string) (models.ReturnRequest, error) {
+	var returnRequest models.ReturnRequest
+
+	err := db.WithContext(ctx).
+		Model(&models.ReturnRequest{}).
+		Preload("Items").
+		Preload("Items.OrderItem").
+		Where("id = ?", returnId).
+		Take(&returnRequest).Error
+	if err != nil {
+		return models.ReturnRequest{}, err
+	}
+
+	return returnRequest, nil
+}
+
+func (r *returnRepositoryImpl) FindReturnRequests(ctx context.Context, db *gorm.DB, userId string) ([]models.ReturnRequest, error) {
+	var returnRequests []models.ReturnRequest
+
+	query := db.WithContext(ctx).
+		Model(&models.ReturnRequest{}).
+		Preload("Items").
+		Preload("Items.OrderItem")
+	if userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+
+	err := query.Order("created_at desc").Find(&returnRequests).Error
+	return returnRequests, err
+}
+
+func (r *returnRepositoryImpl) CountReturnedQuantity(ctx context.Context, db *gorm.DB, orderItemId string) (uint32, error) {
+	var quantity uint32
+
+	err := db.WithContext(ctx).
+		Model(&models.ReturnItem{}).
+		Select("COALESCE(SUM(return_items.quantity), 0)").
+		Joins("JOIN return_requests ON return_requests.id = return_items.return_request_id").
+		Where("return_items.order_item_id = ? AND return_requests.status <> ?", orderItemId, consts.ReturnStatusRejected).
+		Scan(&quantity).Error
+
+	return quantity, err
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, db *gorm.DB, session models.Session) (models.Session, error)
+	FindSession(ctx context.Context, db *gorm.DB, sessionId string) (models.Session, error)
+	FindActiveSessions(ctx context.Context, db *gorm.DB, userId string) ([]models.Session, error)
+	Touch(ctx context.Context, db *gorm.DB, sessionId string, expiresAt time.Time) error
+	Revoke(ctx context.Context, db *gorm.DB, sessionId string) error
+	RevokeUser(ctx context.Context, db *gorm.DB, userId string) error
+}
+
+type sessionRepositoryImpl struct {
+}
+
+func NewSessionRepository() SessionRepository {
+	return &sessionRepositoryImpl{}
+}
+
+func (r *sessionRepositoryImpl) Create(ctx context.Context, db *gorm.DB, session models.Session) (models.Session, error) {
+	err := db.WithContext(ctx).Create(&session).Error
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+func (r *sessionRepositoryImpl) FindSession(ctx context.Context, db *gorm.DB, sessionId string) (models.Session, error) {
+	var session models.Session
+
+	err := db.WithContext(ctx).Where("id = ?", sessionId).Take(&session).Error
+	return session, err
+}
+
+func (r *sessionRepositoryImpl) FindActiveSessions(ctx context.Context, db *gorm.DB, userId string) ([]models.Session, error) {
+	var sessions []models.Session
+
+	err := db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Touch records activity on a session; a zero expiresAt keeps the expiry.
+func (r *sessionRepositoryImpl) Touch(ctx context.Context, db *gorm.DB, sessionId string, expiresAt time.Time) error {
+	updates := map[string]interface{}{"last_seen_at": time.Now()}
+	if !expiresAt.IsZero() {
+		updates["expires_at"] = expiresAt
+	}
+
+	return db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", sessionId).Updates(updates).Error
+}
+
+func (r *sessionRepositoryImpl) Revoke(ctx context.Context, db *gorm.DB, sessionId string) error {
+	return db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionId).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepositoryImpl) RevokeUser(ctx context.Context, db *gorm.DB, userId string) error {
+	return db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
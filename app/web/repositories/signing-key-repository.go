@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	Create(ctx context.Context, db *gorm.DB, signingKey models.SigningKey) (models.SigningKey, error)
+	FindUsable(ctx context.Context, db *gorm.DB, now time.Time) ([]models.SigningKey, error)
+	RetireOthers(ctx context.Context, db *gorm.DB, keepId string, retiresAt time.Time) error
+}
+
+type signingKeyRepositoryImpl struct {
+}
+
+func NewSigningKeyRepository() SigningKeyRepository {
+	return &signingKeyRepositoryImpl{}
+}
+
+func (r *signingKeyRepositoryImpl) Create(ctx context.Context, db *gorm.DB, signingKey models.SigningKey) (models.SigningKey, error) {
+	err := db.WithContext(ctx).Create(&signingKey).Error
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	return signingKey, nil
+}
+
+// FindUsable returns the keys that have not retired, oldest first.
+func (r *signingKeyRepositoryImpl) FindUsable(ctx context.Context, db *gorm.DB, now time.Time) ([]models.SigningKey, error) {
+	var signingKeys []models.SigningKey
+
+	err := db.WithContext(ctx).
+		Where("retires_at IS NULL OR retires_at > ?", now).
+		Order("activates_at asc").
+		Find(&signingKeys).Error
+	return signingKeys, err
+}
+
+// RetireOthers schedules the retirement of every key but keepId that has no
+// retirement scheduled yet.
+func (r *signingKeyRepositoryImpl) RetireOthers(ctx context.Context, db *gorm.DB, keepId string, retiresAt time.Time) error {
+	return db.WithContext(ctx).Model(&models.SigningKey{}).
+		Where("id <> ? AND retires_at IS NULL", keepId).
+		Update("retires_at", retiresAt).Error
+}
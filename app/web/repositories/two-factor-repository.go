@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+)
+
+type TwoFactorRepository interface {
+	FindByUser(ctx context.Context, db *gorm.DB, userId string) (models.TwoFactor, error)
+	Save(ctx context.Context, db *gorm.DB, twoFactor models.TwoFactor) (models.TwoFactor, error)
+	UseStep(ctx context.Context, db *gorm.DB, userId string, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, db *gorm.DB, userId string, codes []models.RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, db *gorm.DB, userId string, codeHash string) (bool, error)
+	Delete(ctx context.Context, db *gorm.DB, userId string) error
+}
+
+type twoFactorRepositoryImpl struct {
+}
+
+func NewTwoFactorRepository() TwoFactorRepository {
+	return &twoFactorRepositoryImpl{}
+}
+
+func (r *twoFactorRepositoryImpl) FindByUser(ctx context.Context, db *gorm.DB, userId string) (models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+
+	err := db.WithContext(ctx).Where("user_id = ?", userId).Take(&twoFactor).Error
+	return twoFactor, err
+}
+
+func (r *twoFactorRepositoryImpl) Save(ctx context.Context, db *gorm.DB, twoFactor models.TwoFactor) (models.TwoFactor, error) {
+	err := db.WithContext(ctx).Save(&twoFactor).Error
+	if err != nil {
+		return models.TwoFactor{}, err
+	}
+
+	return twoFactor, nil
+}
+
+// UseStep records the time step of an accepted code and reports whether it
+// is newer than the last one, so that one code logs in once.
+func (r *twoFactorRepositoryImpl) UseStep(ctx context.Context, db *gorm.DB, userId string, step int64) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.TwoFactor{}).
+		Where("user_id = ? AND last_used_step < ?", userId, step).
+		Update("last_used_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *twoFactorRepositoryImpl) ReplaceRecoveryCodes(ctx context.Context, db *gorm.DB, userId string, codes []models.RecoveryCode) error {
+	err := db.WithContext(ctx).Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Create(&codes).Error
+}
+
+// UseRecoveryCode marks the code used and reports whether this call was the
+// one that did.
+func (r *twoFactorRepositoryImpl) UseRecoveryCode(ctx context.Context, db *gorm.DB, userId string, codeHash string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// Delete removes the authenticator of the user and their recovery codes.
+func (r *twoFactorRepositoryImpl) Delete(ctx context.Context, db *gorm.DB, userId string) error {
+	err := db.WithContext(ctx).Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Where("user_id = ?", userId).Delete(&models.TwoFactor{}).Error
+}
@@ -2,7 +2,10 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"zen-test/app/consts"
 	"zen-test/app/helpers"
 	"zen-test/app/web/models"
 
@@ -15,10 +18,17 @@ type UserRepositoryImpl struct {
 type UserRepository interface {
 	RegisterUser(ctx context.Context, db *gorm.DB, user models.User) (models.User, error)
 	UpdateUser(ctx context.Context, db *gorm.DB, user models.User) (models.User, error)
-	GetUserByEmail(ctx context.Context, db *gorm.DB, email string) (models.User, error)
+	FindUserByEmail(ctx context.Context, db *gorm.DB, email string) (models.User, error)
 	GetUserById(ctx context.Context, db *gorm.DB, userId string) (models.User, error)
+	FindUser(ctx context.Context, db *gorm.DB, userId string) (models.User, error)
+	SearchUsers(ctx context.Context, db *gorm.DB, search models.UserSearch) ([]models.User, int64, error)
+	SetDisabled(ctx context.Context, db *gorm.DB, userId string, disabledAt *time.Time) error
+	AnonymizeUser(ctx context.Context, db *gorm.DB, userId string, at time.Time) error
 }
 
+// anonymizedName replaces the name of a deleted user wherever it was kept.
+const anonymizedName = "Deleted user"
+
 func NewUserRepository() UserRepository {
 	return &UserRepositoryImpl{}
 }
@@ -31,12 +41,12 @@ func (r *UserRepositoryImpl) RegisterUser(ctx context.Context, db *gorm.DB, user
 	return user, nil
 }
 
-func (r *UserRepositoryImpl) GetUserByEmail(ctx context.Context, db *gorm.DB, email string) (models.User, error) {
+// FindUserByEmail reports a missing user as an error rather than panicking,
+// for callers that must not reveal whether the email exists.
+func (r *UserRepositoryImpl) FindUserByEmail(ctx context.Context, db *gorm.DB, email string) (models.User, error) {
 	var user models.User
 	err := db.WithContext(ctx).Model(&models.User{}).Where("email = ?", email).Take(&user).Error
-
-	helpers.PanicIfError(err)
-	return user, nil
+	return user, err
 }
 
 func (r *UserRepositoryImpl) UpdateUser(ctx context.Context, db *gorm.DB, user models.User) (models.User, error) {
@@ -53,3 +63,105 @@ func (r *UserRepositoryImpl) GetUserById(ctx context.Context, db *gorm.DB, userI
 	helpers.PanicIfError(err)
 	return user, nil
 }
+
+// FindUser is GetUserById reporting a missing user as an error rather than
+// panicking, for checks made on every request.
+func (r *UserRepositoryImpl) FindUser(ctx context.Context, db *gorm.DB, userId string) (models.User, error) {
+	var user models.User
+	err := db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userId).Take(&user).Error
+	return user, err
+}
+
+// SearchUsers returns one page of the users matching search, newest first,
+// along with how many match in all.
+func (r *UserRepositoryImpl) SearchUsers(ctx context.Context, db *gorm.DB, search models.UserSearch) ([]models.User, int64, error) {
+	query := db.WithContext(ctx).Model(&models.User{})
+	if search.Query != "" {
+		prefix := search.Query + "%"
+		query = query.Where("name LIKE ? OR email LIKE ? OR id = ?", prefix, prefix, search.Query)
+	}
+	if search.Role != "" {
+		query = query.Where("role = ?", search.Role)
+	}
+	switch search.Status {
+	case "active":
+		query = query.Where("disabled_at IS NULL")
+	case "disabled":
+		query = query.Where("disabled_at IS NOT NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	err := query.Order("created_at desc, id").
+		Offset((search.Page - 1) * search.Size).
+		Limit(search.Size).
+		Find(&users).Error
+	return users, total, err
+}
+
+// SetDisabled disables the user at disabledAt, or enables them when it is nil,
+// which UpdateUser cannot do as it skips empty fields.
+func (r *UserRepositoryImpl) SetDisabled(ctx context.Context, db *gorm.DB, userId string, disabledAt *time.Time) error {
+	return db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userId).Update("disabled_at", disabledAt).Error
+}
+
+// AnonymizeUser blanks the personal fields of the user, who is disabled from
+// at, and deletes what was kept only about them: addresses, sessions, tokens,
+// API keys, second factors, linked identities and login attempts. The user
+// row stays so orders and refunds still point at an account.
+func (r *UserRepositoryImpl) AnonymizeUser(ctx context.Context, db *gorm.DB, userId string, at time.Time) error {
+	db = db.WithContext(ctx)
+
+	err := db.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"name":              anonymizedName,
+		"email":             fmt.Sprintf("deleted-%s@invalid", userId),
+		"password":          "",
+		"phone":             "",
+		"address":           "",
+		"email_verified_at": nil,
+		"disabled_at":       at,
+		"anonymized_at":     at,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, model := range []interface{}{
+		&models.Address{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.APIKey{},
+		&models.TwoFactor{},
+		&models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.ExternalIdentity{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.LoginAttempt{},
+	} {
+		if err := db.Where("user_id = ?", userId).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	// Emails to the user, queued, sent or given up on, hold their address.
+	// A job a worker is running is left to finish, but with nothing personal
+	// in its row and no attempts left, so a failure buries it as it is.
+	err = db.Where("user_id = ? AND status <> ?", userId, consts.JobStatusRunning).Delete(&models.Job{}).Error
+	if err != nil {
+		return err
+	}
+	err = db.Model(&models.Job{}).Where("user_id = ? AND status = ?", userId, consts.JobStatusRunning).Updates(map[string]interface{}{
+		"payload":      "{}",
+		"user_id":      "",
+		"max_attempts": gorm.Expr("attempts"),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return db.Where("user_id = ?", userId).Delete(&models.DeadJob{}).Error
+}
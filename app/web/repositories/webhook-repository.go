@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"zen-test/app/web/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, db *gorm.DB, subscription models.WebhookSubscription) (models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, db *gorm.DB, subscription models.WebhookSubscription) (models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, db *gorm.DB, subscriptionId string) error
+	FindSubscription(ctx context.Context, db *gorm.DB, subscriptionId string) (models.WebhookSubscription, error)
+	FindSubscriptions(ctx context.Context, db *gorm.DB) ([]models.WebhookSubscription, error)
+	FindActiveSubscriptions(ctx context.Context, db *gorm.DB) ([]models.WebhookSubscription, error)
+	CreateDelivery(ctx context.Context, db *gorm.DB, delivery models.WebhookDelivery) (models.WebhookDelivery, bool, error)
+	UpdateDelivery(ctx context.Context, db *gorm.DB, delivery models.WebhookDelivery) error
+	FindDelivery(ctx context.Context, db *gorm.DB, deliveryId string) (models.WebhookDelivery, error)
+	FindDeliveries(ctx context.Context, db *gorm.DB, subscriptionId string) ([]models.WebhookDelivery, error)
+	AnonymizeDeliveries(ctx context.Context, db *gorm.DB, eventIds []string) error
+}
+
+type webhookRepositoryImpl struct {
+}
+
+func NewWebhookRepository() WebhookRepository {
+	return &webhookRepositoryImpl{}
+}
+
+func (r *webhookRepositoryImpl) CreateSubscription(ctx context.Context, db *gorm.DB, subscription models.WebhookSubscription) (models.WebhookSubscription, error) {
+	err := db.WithContext(ctx).Create(&subscription).Error
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	return subscription, nil
+}
+
+func (r *webhookRepositoryImpl) UpdateSubscription(ctx context.Context, db *gorm.DB, subscription models.WebhookSubscription) (models.WebhookSubscription, error) {
+	err := db.WithContext(ctx).Model(&models.WebhookSubscription{}).
+		Where("id = ?", subscription.ID).
+		Select("url", "event_types", "active").
+		Updates(&subscription).Error
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	return subscription, nil
+}
+
+func (r *webhookRepositoryImpl) DeleteSubscription(ctx context.Context, db *gorm.DB, subscriptionId string) error {
+	return db.WithContext(ctx).Where("id = ?", subscriptionId).Delete(&models.WebhookSubscription{}).Error
+}
+
+func (r *webhookRepositoryImpl) FindSubscription(ctx context.Context, db *gorm.DB, subscriptionId string) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+
+	err := db.WithContext(ctx).Where("id = ?", subscriptionId).Take(&subscription).Error
+	return subscription, err
+}
+
+func (r *webhookRepositoryImpl) FindSubscriptions(ctx context.Context, db *gorm.DB) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+
+	err := db.WithContext(ctx).Order("created_at").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *webhookRepositoryImpl) FindActiveSubscriptions(ctx context.Context, db *gorm.DB) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+
+	err := db.WithContext(ctx).Where("active = ?", true).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// CreateDelivery ignores a delivery that already exists for the same
+// subscription and event; created reports whether a new row was written.
+func (r *webhookRepositoryImpl) CreateDelivery(ctx context.Context, db *gorm.DB, delivery models.WebhookDelivery) (models.WebhookDelivery, bool, error) {
+	result := db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery)
+	if result.Error != nil {
+		return models.WebhookDelivery{}, false, result.Error
+	}
+
+	return delivery, result.RowsAffected == 1, nil
+}
+
+func (r *webhookRepositoryImpl) UpdateDelivery(ctx context.Context, db *gorm.DB, delivery models.WebhookDelivery) error {
+	return db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Select("attempts", "status_code", "response_body", "error", "succeeded", "delivered_at").
+		Updates(&delivery).Error
+}
+
+func (r *webhookRepositoryImpl) FindDelivery(ctx context.Context, db *gorm.DB, deliveryId string) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+
+	err := db.WithContext(ctx).Where("id = ?", deliveryId).Take(&delivery).Error
+	return delivery, err
+}
+
+func (r *webhookRepositoryImpl) FindDeliveries(ctx context.Context, db *gorm.DB, subscriptionId string) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	err := db.WithContext(ctx).Where("subscription_id = ?", subscriptionId).Order("created_at desc").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// AnonymizeDeliveries empties the event payload inside the deliveries of the
+// events, along with whatever the receivers answered. The rest of the event
+// envelope is kept, so a delivery still due tells what happened.
+func (r *webhookRepositoryImpl) AnonymizeDeliveries(ctx context.Context, db *gorm.DB, eventIds []string) error {
+	if len(eventIds) == 0 {
+		return nil
+	}
+	db = db.WithContext(ctx)
+
+	var deliveries []models.WebhookDelivery
+	err := db.Where("event_id IN ?", eventIds).Find(&deliveries).Error
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		payload := "{}"
+		var envelope map[string]json.RawMessage
+		if json.Unmarshal([]byte(delivery.Payload), &envelope) == nil {
+			envelope["payload"] = json.RawMessage("{}")
+			encoded, err := json.Marshal(envelope)
+			if err != nil {
+				return err
+			}
+			payload = string(encoded)
+		}
+
+		err = db.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).
+			Updates(map[string]interface{}{"payload": payload, "response_body": ""}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
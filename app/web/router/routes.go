@@ -1,6 +1,7 @@
 package router
 
 import (
+	"zen-test/app/consts"
 	"zen-test/app/middleware"
 	"zen-test/app/web/controllers"
 
@@ -9,27 +10,117 @@ import (
 
 func InitializeRouter(
 	userController controllers.UserController,
+	sessionController controllers.SessionController,
+	passwordResetController controllers.PasswordResetController,
+	emailVerificationController controllers.EmailVerificationController,
+	twoFactorController controllers.TwoFactorController,
+	oidcController controllers.OIDCController,
+	apiKeyController controllers.APIKeyController,
+	addressController controllers.AddressController,
+	accountController controllers.AccountController,
 	productController controllers.ProductController,
 	orderController controllers.OrderController,
+	orderEventController controllers.OrderEventController,
+	returnController controllers.ReturnController,
+	webhookController controllers.WebhookController,
+	dashboardController controllers.DashboardController,
+	userAdminController controllers.UserAdminController,
+	keyController controllers.KeyController,
+	scopes middleware.ScopeResolver,
 ) *mux.Router {
 	router := mux.NewRouter()
 
+	// routeScopes holds the scope each route requires, keyed by method and
+	// path template; routes without one are open to any signed in user but
+	// not to API keys.
+	routeScopes := make(map[string]string)
+	requires := func(scope string, route *mux.Route) {
+		template, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			routeScopes[method+" "+template] = scope
+		}
+	}
+
+	router.HandleFunc("/.well-known/jwks.json", keyController.JWKS).Methods("GET")
+
+	router.HandleFunc("/auth/oidc/{provider}/login", oidcController.Login).Methods("GET")
+	router.HandleFunc("/auth/oidc/{provider}/callback", oidcController.Callback).Methods("GET")
+
 	router.HandleFunc("/users/login", userController.Login).Methods("POST")
+	router.HandleFunc("/users/login/2fa", userController.LoginTwoFactor).Methods("POST")
+	router.HandleFunc("/users/password/forgot", passwordResetController.Forgot).Methods("POST")
+	router.HandleFunc("/users/password/reset", passwordResetController.Reset).Methods("POST")
+	router.HandleFunc("/users/email/verify", emailVerificationController.Verify).Methods("POST")
+	router.HandleFunc("/users/email/verify/resend", emailVerificationController.Resend).Methods("POST")
+	router.HandleFunc("/users/2fa/enroll", twoFactorController.Enroll).Methods("POST")
+	router.HandleFunc("/users/2fa/confirm", twoFactorController.Confirm).Methods("POST")
+	router.HandleFunc("/users/2fa/disable", twoFactorController.Disable).Methods("POST")
+	router.HandleFunc("/users/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes).Methods("POST")
 	router.HandleFunc("/users/signup", userController.SignUp).Methods("POST")
 	router.HandleFunc("/users/{userId}", userController.Update).Methods("PUT")
 	router.HandleFunc("/users/logout", userController.Logout).Methods("POST")
 	router.HandleFunc("/users/refresh-token", userController.RefreshToken).Methods("POST")
+	router.HandleFunc("/users/me", userController.FindMe).Methods("GET")
+	router.HandleFunc("/users/me", userController.Patch).Methods("PATCH")
+	router.HandleFunc("/users/me", accountController.Delete).Methods("DELETE")
+	router.HandleFunc("/users/me/export", accountController.Export).Methods("GET")
+	router.HandleFunc("/users/me/password", userController.ChangePassword).Methods("POST")
+	router.HandleFunc("/users/me/email", userController.ChangeEmail).Methods("POST")
+	router.HandleFunc("/users/me/sessions", sessionController.FindAll).Methods("GET")
+	router.HandleFunc("/users/me/sessions/{sessionId}", sessionController.Revoke).Methods("DELETE")
+	router.HandleFunc("/users/me/api-keys", apiKeyController.Create).Methods("POST")
+	router.HandleFunc("/users/me/api-keys", apiKeyController.FindAll).Methods("GET")
+	router.HandleFunc("/users/me/api-keys/{keyId}", apiKeyController.Revoke).Methods("DELETE")
+	router.HandleFunc("/users/me/addresses", addressController.Create).Methods("POST")
+	router.HandleFunc("/users/me/addresses", addressController.FindAll).Methods("GET")
+	router.HandleFunc("/users/me/addresses/{addressId}", addressController.FindById).Methods("GET")
+	router.HandleFunc("/users/me/addresses/{addressId}", addressController.Update).Methods("PUT")
+	router.HandleFunc("/users/me/addresses/{addressId}", addressController.Delete).Methods("DELETE")
+	router.HandleFunc("/users/me/addresses/{addressId}/default", addressController.SetDefault).Methods("POST")
+
+	requires(consts.ScopeProductsWrite, router.HandleFunc("/products", productController.Create).Methods("POST"))
+	requires(consts.ScopeProductsRead, router.HandleFunc("/products", productController.FindAll).Methods("GET"))
+	requires(consts.ScopeProductsWrite, router.HandleFunc("/products/{productId}", productController.Update).Methods("PUT"))
+	requires(consts.ScopeProductsRead, router.HandleFunc("/products/{productId}", productController.FindById).Methods("GET"))
+	requires(consts.ScopeProductsWrite, router.HandleFunc("/products/{productId}", productController.Delete).Methods("DELETE"))
+
+	requires(consts.ScopeOrdersReadOwn, router.HandleFunc("/orders", orderController.FindAllOrder).Methods("GET"))
+	requires(consts.ScopeOrdersWriteOwn, router.HandleFunc("/orders", orderController.CreateOrder).Methods("POST"))
+	requires(consts.ScopeOrdersReadOwn, router.HandleFunc("/orders/events", orderEventController.StreamOrders).Methods("GET"))
+	requires(consts.ScopeOrdersWriteOwn, router.HandleFunc("/orders/{orderId}/cancel", orderController.CancelOrder).Methods("POST"))
+	requires(consts.ScopeOrdersWriteAll, router.HandleFunc("/orders/{orderId}/pay", orderController.ConfirmPayment).Methods("POST"))
+	requires(consts.ScopeOrdersWriteAll, router.HandleFunc("/orders/{orderId}/ship", orderController.ShipOrder).Methods("POST"))
+	requires(consts.ScopeOrdersReadOwn, router.HandleFunc("/orders/{orderId}/events", orderEventController.StreamOrder).Methods("GET"))
+
+	requires(consts.ScopeReturnsWriteOwn, router.HandleFunc("/returns", returnController.Create).Methods("POST"))
+	requires(consts.ScopeReturnsReadOwn, router.HandleFunc("/returns", returnController.FindAll).Methods("GET"))
+	requires(consts.ScopeReturnsReadOwn, router.HandleFunc("/returns/{returnId}", returnController.FindById).Methods("GET"))
+	requires(consts.ScopeReturnsWriteAll, router.HandleFunc("/returns/{returnId}/approve", returnController.Approve).Methods("POST"))
+	requires(consts.ScopeReturnsWriteAll, router.HandleFunc("/returns/{returnId}/reject", returnController.Reject).Methods("POST"))
+	requires(consts.ScopeReturnsWriteAll, router.HandleFunc("/returns/{returnId}/receive", returnController.Receive).Methods("POST"))
+
+	requires(consts.ScopeWebhooksWrite, router.HandleFunc("/webhooks", webhookController.Create).Methods("POST"))
+	requires(consts.ScopeWebhooksRead, router.HandleFunc("/webhooks", webhookController.FindAll).Methods("GET"))
+	requires(consts.ScopeWebhooksWrite, router.HandleFunc("/webhooks/{webhookId}", webhookController.Update).Methods("PUT"))
+	requires(consts.ScopeWebhooksRead, router.HandleFunc("/webhooks/{webhookId}", webhookController.FindById).Methods("GET"))
+	requires(consts.ScopeWebhooksWrite, router.HandleFunc("/webhooks/{webhookId}", webhookController.Delete).Methods("DELETE"))
+	requires(consts.ScopeWebhooksRead, router.HandleFunc("/webhooks/{webhookId}/deliveries", webhookController.FindDeliveries).Methods("GET"))
+	requires(consts.ScopeWebhooksWrite, router.HandleFunc("/webhooks/deliveries/{deliveryId}/redeliver", webhookController.Redeliver).Methods("POST"))
 
-	router.HandleFunc("/products", productController.Create).Methods("POST")
-	router.HandleFunc("/products", productController.FindAll).Methods("GET")
-	router.HandleFunc("/products/{productId}", productController.Update).Methods("PUT")
-	router.HandleFunc("/products/{productId}", productController.FindById).Methods("GET")
-	router.HandleFunc("/products/{productId}", productController.Delete).Methods("DELETE")
+	requires(consts.ScopeOrdersReadAll, router.HandleFunc("/admin/jobs/order-expiry", orderController.OrderExpiryStats).Methods("GET"))
+	requires(consts.ScopeOrdersWriteAll, router.HandleFunc("/admin/jobs/order-expiry/run", orderController.RunOrderExpiry).Methods("POST"))
+	requires(consts.ScopeDashboardRead, router.HandleFunc("/admin/dashboard/ws", dashboardController.Feed).Methods("GET"))
 
-	router.HandleFunc("/orders", orderController.FindAllOrder).Methods("GET")
-	router.HandleFunc("/orders", orderController.CreateOrder).Methods("POST")
+	requires(consts.ScopeUsersRead, router.HandleFunc("/admin/users", userAdminController.Search).Methods("GET"))
+	requires(consts.ScopeUsersRead, router.HandleFunc("/admin/users/{userId}", userAdminController.FindById).Methods("GET"))
+	requires(consts.ScopeUsersWrite, router.HandleFunc("/admin/users/{userId}/disable", userAdminController.Disable).Methods("POST"))
+	requires(consts.ScopeUsersWrite, router.HandleFunc("/admin/users/{userId}/enable", userAdminController.Enable).Methods("POST"))
+	requires(consts.ScopeUsersRoles, router.HandleFunc("/admin/users/{userId}/role", userAdminController.ChangeRole).Methods("PUT"))
+	requires(consts.ScopeUsersWrite, router.HandleFunc("/admin/users/{userId}/logout", userAdminController.Logout).Methods("POST"))
 
 	router.Use(middleware.RecoverMiddleware)
+	router.Use(middleware.RequireScopes(routeScopes, scopes))
 
 	return router
 }
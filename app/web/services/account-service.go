@@ -0,0 +1,137 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// AccountService hands users a copy of their data and deletes their account.
+type AccountService interface {
+	Export(ctx context.Context, userId string) models.UserExport
+	ExportArchive(ctx context.Context, userId string) []byte
+	Delete(ctx context.Context, request models.AccountDelete, userId string)
+}
+
+type AccountServiceImpl struct {
+	UserRepository    repositories.UserRepository
+	AddressRepository repositories.AddressRepository
+	OrderRepository   repositories.OrderRepository
+	OutboxRepository  repositories.OutboxRepository
+	WebhookRepository repositories.WebhookRepository
+	Sessions          SessionService
+	DB                *gorm.DB
+	Validate          *validator.Validate
+}
+
+func NewAccountService(userRepo repositories.UserRepository, addressRepo repositories.AddressRepository, orderRepo repositories.OrderRepository, outboxRepo repositories.OutboxRepository, webhookRepo repositories.WebhookRepository, sessions SessionService, db *gorm.DB, validate *validator.Validate) AccountService {
+	return &AccountServiceImpl{
+		UserRepository:    userRepo,
+		AddressRepository: addressRepo,
+		OrderRepository:   orderRepo,
+		OutboxRepository:  outboxRepo,
+		WebhookRepository: webhookRepo,
+		Sessions:          sessions,
+		DB:                db,
+		Validate:          validate,
+	}
+}
+
+func (s *AccountServiceImpl) Export(ctx context.Context, userId string) models.UserExport {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user, err := s.UserRepository.FindUser(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError("user not found"))
+	}
+
+	addresses, err := s.AddressRepository.FindAddresses(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	orders, err := s.OrderRepository.FindAllOrder(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	return models.UserExport{
+		ExportedAt: time.Now(),
+		Profile:    models.ToUserReponse(user),
+		Addresses:  models.ToAddressResponses(addresses),
+		Orders:     models.ToOrderResponses(orders),
+	}
+}
+
+// ExportArchive is Export as a ZIP archive holding a JSON file for each part.
+func (s *AccountServiceImpl) ExportArchive(ctx context.Context, userId string) []byte {
+	export := s.Export(ctx, userId)
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"addresses.json", export.Addresses},
+		{"orders.json", export.Orders},
+	} {
+		writer, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		helpers.PanicIfError(err)
+
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(file.data)
+		helpers.PanicIfError(err)
+	}
+
+	err := archive.Close()
+	helpers.PanicIfError(err)
+
+	return buffer.Bytes()
+}
+
+// Delete anonymizes the account once the password is confirmed: the user is
+// signed out everywhere, their personal data is removed, and their orders
+// keep the amounts but no longer say who they went to. Copies of their data
+// in events, webhook deliveries and emails are removed too.
+func (s *AccountServiceImpl) Delete(ctx context.Context, request models.AccountDelete, userId string) {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	// Runs after the commit so the anonymized account is what gets cached next.
+	defer s.Sessions.ForgetAccount(userId)
+	defer helpers.CommitOrRollback(tx)
+
+	user, err := s.UserRepository.FindUser(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError("user not found"))
+	}
+
+	if !helpers.ComparePassword(request.Password, user.Password) {
+		panic(exceptions.NewBadRequestError("password is incorrect"))
+	}
+
+	s.Sessions.RevokeAll(ctx, tx, user.ID)
+
+	err = s.UserRepository.AnonymizeUser(ctx, tx, user.ID, time.Now())
+	helpers.PanicIfError(err)
+
+	eventIds, err := s.OutboxRepository.AnonymizeEvents(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	err = s.WebhookRepository.AnonymizeDeliveries(ctx, tx, eventIds)
+	helpers.PanicIfError(err)
+
+	err = s.OrderRepository.AnonymizeOrders(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+}
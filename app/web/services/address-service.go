@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AddressService interface {
+	Create(ctx context.Context, request models.AddressCreateUpdate, userId string) models.AddressResponse
+	FindAll(ctx context.Context, userId string) []models.AddressResponse
+	FindById(ctx context.Context, addressId string, userId string) models.AddressResponse
+	Update(ctx context.Context, addressId string, request models.AddressCreateUpdate, userId string) models.AddressResponse
+	Delete(ctx context.Context, addressId string, userId string)
+	SetDefault(ctx context.Context, addressId string, userId string) models.AddressResponse
+}
+
+type AddressServiceImpl struct {
+	AddressRepository repositories.AddressRepository
+	DB                *gorm.DB
+	Validate          *validator.Validate
+}
+
+func NewAddressService(addressRepo repositories.AddressRepository, db *gorm.DB, validate *validator.Validate) AddressService {
+	return &AddressServiceImpl{
+		AddressRepository: addressRepo,
+		DB:                db,
+		Validate:          validate,
+	}
+}
+
+// Create adds an address to the user's book. The first address a user adds
+// becomes their default.
+func (s *AddressServiceImpl) Create(ctx context.Context, request models.AddressCreateUpdate, userId string) models.AddressResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	_, err = s.AddressRepository.FindDefault(ctx, tx, userId)
+	isDefault := request.IsDefault || err != nil
+	if isDefault {
+		err = s.AddressRepository.ClearDefault(ctx, tx, userId)
+		helpers.PanicIfError(err)
+	}
+
+	address := models.Address{ID: uuid.New().String(), UserID: userId, IsDefault: isDefault}
+	applyAddress(&address, request)
+
+	address, err = s.AddressRepository.Create(ctx, tx, address)
+	helpers.PanicIfError(err)
+
+	return models.ToAddressResponse(address)
+}
+
+func (s *AddressServiceImpl) FindAll(ctx context.Context, userId string) []models.AddressResponse {
+	addresses, err := s.AddressRepository.FindAddresses(ctx, s.DB, userId)
+	helpers.PanicIfError(err)
+
+	return models.ToAddressResponses(addresses)
+}
+
+func (s *AddressServiceImpl) FindById(ctx context.Context, addressId string, userId string) models.AddressResponse {
+	return models.ToAddressResponse(findOwnAddress(ctx, s.DB, s.AddressRepository, addressId, userId))
+}
+
+// Update replaces the address. Asking for it to be the default moves the
+// default to it; the default cannot be unset other than by choosing another.
+func (s *AddressServiceImpl) Update(ctx context.Context, addressId string, request models.AddressCreateUpdate, userId string) models.AddressResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	address := findOwnAddress(ctx, tx, s.AddressRepository, addressId, userId)
+	if request.IsDefault && !address.IsDefault {
+		err = s.AddressRepository.ClearDefault(ctx, tx, userId)
+		helpers.PanicIfError(err)
+		address.IsDefault = true
+	}
+	applyAddress(&address, request)
+
+	address, err = s.AddressRepository.Update(ctx, tx, address)
+	helpers.PanicIfError(err)
+
+	return models.ToAddressResponse(address)
+}
+
+// Delete removes the address. When it was the default, the oldest remaining
+// address takes its place. Orders keep their own copy of where they ship.
+func (s *AddressServiceImpl) Delete(ctx context.Context, addressId string, userId string) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	address := findOwnAddress(ctx, tx, s.AddressRepository, addressId, userId)
+
+	err := s.AddressRepository.Delete(ctx, tx, address.ID)
+	helpers.PanicIfError(err)
+
+	if !address.IsDefault {
+		return
+	}
+
+	remaining, err := s.AddressRepository.FindAddresses(ctx, tx, userId)
+	helpers.PanicIfError(err)
+	if len(remaining) == 0 {
+		return
+	}
+
+	remaining[0].IsDefault = true
+	_, err = s.AddressRepository.Update(ctx, tx, remaining[0])
+	helpers.PanicIfError(err)
+}
+
+func (s *AddressServiceImpl) SetDefault(ctx context.Context, addressId string, userId string) models.AddressResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	address := findOwnAddress(ctx, tx, s.AddressRepository, addressId, userId)
+
+	err := s.AddressRepository.ClearDefault(ctx, tx, userId)
+	helpers.PanicIfError(err)
+
+	address.IsDefault = true
+	address, err = s.AddressRepository.Update(ctx, tx, address)
+	helpers.PanicIfError(err)
+
+	return models.ToAddressResponse(address)
+}
+
+// findOwnAddress finds an address of the user, answering not found for the
+// addresses of others alike.
+func findOwnAddress(ctx context.Context, tx *gorm.DB, addressRepo repositories.AddressRepository, addressId string, userId string) models.Address {
+	address, err := addressRepo.FindAddress(ctx, tx, addressId)
+	if err != nil || address.UserID != userId {
+		panic(exceptions.NewNotFoundError("address not found"))
+	}
+	return address
+}
+
+func applyAddress(address *models.Address, request models.AddressCreateUpdate) {
+	address.Label = request.Label
+	address.Recipient = request.Recipient
+	address.Street = request.Street
+	address.City = request.City
+	address.Province = request.Province
+	address.PostalCode = request.PostalCode
+	address.Country = request.Country
+	address.Phone = request.Phone
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"log"
+	"strings"
+	"time"
+
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix marks the keys of this application, so leaked keys are easy
+// to spot by secret scanners.
+const apiKeyPrefix = "zk"
+
+type APIKeyConfig struct {
+	// TouchInterval is how often the last use of a key is written down.
+	TouchInterval time.Duration
+}
+
+type APIKeyService interface {
+	Create(ctx context.Context, request models.APIKeyCreate, userId string) models.APIKeyResponse
+	FindAll(ctx context.Context, userId string) []models.APIKeyResponse
+	Revoke(ctx context.Context, keyId string, userId string)
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, bool)
+}
+
+type APIKeyServiceImpl struct {
+	APIKeyRepository repositories.APIKeyRepository
+	Config           APIKeyConfig
+	DB               *gorm.DB
+	Validate         *validator.Validate
+}
+
+func NewAPIKeyService(apiKeyRepo repositories.APIKeyRepository, config APIKeyConfig, db *gorm.DB, validate *validator.Validate) APIKeyService {
+	return &APIKeyServiceImpl{
+		APIKeyRepository: apiKeyRepo,
+		Config:           config,
+		DB:               db,
+		Validate:         validate,
+	}
+}
+
+// Create makes a key for the user. The response is the only place the key
+// itself ever appears.
+func (s *APIKeyServiceImpl) Create(ctx context.Context, request models.APIKeyCreate, userId string) models.APIKeyResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	if request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		panic(exceptions.NewBadRequestError("expires_at must be in the future"))
+	}
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	prefix := newKeyPrefix()
+	key := apiKeyPrefix + "_" + prefix + "_" + helpers.RandomToken(32)
+
+	apiKey, err := s.APIKeyRepository.Create(ctx, tx, models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userId,
+		Name:      request.Name,
+		Prefix:    prefix,
+		KeyHash:   helpers.HashToken(key),
+		Scopes:    strings.Join(request.Scopes, ","),
+		ExpiresAt: request.ExpiresAt,
+	})
+	helpers.PanicIfError(err)
+
+	response := models.ToAPIKeyResponse(apiKey)
+	response.Key = key
+	return response
+}
+
+func (s *APIKeyServiceImpl) FindAll(ctx context.Context, userId string) []models.APIKeyResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	keys, err := s.APIKeyRepository.FindActiveKeys(ctx, tx, userId)
+	helpers.PanicIfError(err)
+
+	return models.ToAPIKeyResponses(keys)
+}
+
+func (s *APIKeyServiceImpl) Revoke(ctx context.Context, keyId string, userId string) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	apiKey, err := s.APIKeyRepository.FindKey(ctx, tx, keyId)
+	if err != nil || apiKey.UserID != userId || apiKey.RevokedAt != nil {
+		panic(exceptions.NewNotFoundError("api key not found"))
+	}
+
+	err = s.APIKeyRepository.Revoke(ctx, tx, apiKey.ID)
+	helpers.PanicIfError(err)
+}
+
+// AuthenticateAPIKey returns the key a request presented, if it is valid.
+func (s *APIKeyServiceImpl) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return models.APIKey{}, false
+	}
+
+	apiKey, err := s.APIKeyRepository.FindByPrefix(ctx, s.DB, parts[1])
+	if err != nil || subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(helpers.HashToken(key))) != 1 {
+		return models.APIKey{}, false
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now)) {
+		return models.APIKey{}, false
+	}
+
+	if err := s.APIKeyRepository.Touch(ctx, s.DB, apiKey.ID, now, s.Config.TouchInterval); err != nil {
+		log.Printf("Recording use of API key %s failed: %v", apiKey.ID, err)
+	}
+	return apiKey, true
+}
+
+// newKeyPrefix returns eight random base32 characters, which never contain
+// the underscore the parts of a key are separated by.
+func newKeyPrefix() string {
+	random := make([]byte, 5)
+	_, err := rand.Read(random)
+	helpers.PanicIfError(err)
+
+	return strings.ToLower(base32.StdEncoding.EncodeToString(random))
+}
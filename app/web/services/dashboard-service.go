@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+
+	"zen-test/app/consts"
+)
+
+type DashboardService interface {
+	Authorize(ctx context.Context, userId string)
+}
+
+type DashboardServiceImpl struct {
+}
+
+func NewDashboardService() DashboardService {
+	return &DashboardServiceImpl{}
+}
+
+// Authorize lets only users granted the dashboard scope open the live feed.
+func (s *DashboardServiceImpl) Authorize(ctx context.Context, userId string) {
+	ensureScope(ctx, consts.ScopeDashboardRead)
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/consts"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/jobs"
+	"zen-test/app/notifications"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EmailVerificationConfig struct {
+	// TokenTTL is how long a verification link works.
+	TokenTTL time.Duration
+	// ResendInterval is the least time between two verification emails to
+	// one user, and MaxPerDay caps how many they get in 24 hours.
+	ResendInterval time.Duration
+	MaxPerDay      int
+	// URL is the page the emailed link opens; the token is added as the
+	// token query parameter.
+	URL string
+}
+
+type EmailVerificationService interface {
+	Issue(ctx context.Context, tx *gorm.DB, user models.User)
+	IssueEmailChange(ctx context.Context, tx *gorm.DB, user models.User, email string)
+	Verify(ctx context.Context, request models.EmailVerify) models.UserResponse
+	Resend(ctx context.Context, userId string)
+	EmailVerified(ctx context.Context, userId string) bool
+}
+
+type EmailVerificationServiceImpl struct {
+	UserRepository              repositories.UserRepository
+	EmailVerificationRepository repositories.EmailVerificationRepository
+	JobQueue                    *jobs.Queue
+	Config                      EmailVerificationConfig
+	DB                          *gorm.DB
+	Validate                    *validator.Validate
+}
+
+func NewEmailVerificationService(userRepo repositories.UserRepository, emailVerificationRepo repositories.EmailVerificationRepository, jobQueue *jobs.Queue, config EmailVerificationConfig, db *gorm.DB, validate *validator.Validate) EmailVerificationService {
+	return &EmailVerificationServiceImpl{
+		UserRepository:              userRepo,
+		EmailVerificationRepository: emailVerificationRepo,
+		JobQueue:                    jobQueue,
+		Config:                      config,
+		DB:                          db,
+		Validate:                    validate,
+	}
+}
+
+// Issue mails the user a fresh verification link within tx, replacing any
+// link sent before.
+func (s *EmailVerificationServiceImpl) Issue(ctx context.Context, tx *gorm.DB, user models.User) {
+	s.issue(ctx, tx, user, "", notifications.TemplateEmailVerification)
+}
+
+// IssueEmailChange mails a verification link to the new address of the user
+// within tx. The account moves to it once the link is opened.
+func (s *EmailVerificationServiceImpl) IssueEmailChange(ctx context.Context, tx *gorm.DB, user models.User, email string) {
+	s.issue(ctx, tx, user, email, notifications.TemplateEmailChange)
+}
+
+func (s *EmailVerificationServiceImpl) issue(ctx context.Context, tx *gorm.DB, user models.User, email string, template string) {
+	err := s.EmailVerificationRepository.InvalidateUser(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	token := helpers.RandomToken(32)
+	verificationToken, err := s.EmailVerificationRepository.Create(ctx, tx, models.EmailVerificationToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		TokenHash: helpers.HashToken(token),
+		Email:     email,
+		ExpiresAt: time.Now().Add(s.Config.TokenTTL),
+	})
+	helpers.PanicIfError(err)
+
+	_, err = s.JobQueue.Enqueue(ctx, tx, consts.JobTypeLinkEmail, models.LinkEmail{
+		UserID:    user.ID,
+		To:        email,
+		Template:  template,
+		Link:      tokenLink(s.Config.URL, token),
+		ExpiresAt: verificationToken.ExpiresAt,
+	}, jobs.ForUser(user.ID))
+	helpers.PanicIfError(err)
+}
+
+func (s *EmailVerificationServiceImpl) Verify(ctx context.Context, request models.EmailVerify) models.UserResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	verificationToken, err := s.EmailVerificationRepository.FindByHash(ctx, tx, helpers.HashToken(request.Token))
+	if err != nil || verificationToken.UsedAt != nil || verificationToken.ExpiresAt.Before(time.Now()) {
+		panic(exceptions.NewBadRequestError("invalid or expired verification token"))
+	}
+
+	used, err := s.EmailVerificationRepository.Use(ctx, tx, verificationToken.ID)
+	helpers.PanicIfError(err)
+	if !used {
+		panic(exceptions.NewBadRequestError("invalid or expired verification token"))
+	}
+
+	user, err := s.UserRepository.GetUserById(ctx, tx, verificationToken.UserID)
+	helpers.PanicIfError(err)
+
+	if verificationToken.Email != "" {
+		owner, err := s.UserRepository.FindUserByEmail(ctx, tx, verificationToken.Email)
+		if err == nil && owner.ID != user.ID {
+			panic(exceptions.NewBadRequestError("email is already in use"))
+		}
+		user.Email = verificationToken.Email
+		user.EmailVerifiedAt = nil
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+
+		user, err = s.UserRepository.UpdateUser(ctx, tx, user)
+		helpers.PanicIfError(err)
+	}
+
+	return models.ToUserReponse(user)
+}
+
+// Resend mails a new link to a user whose email is not verified yet, at most
+// once per ResendInterval and MaxPerDay times a day.
+func (s *EmailVerificationServiceImpl) Resend(ctx context.Context, userId string) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user, err := s.UserRepository.GetUserById(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError(err.Error()))
+	}
+
+	if user.EmailVerifiedAt != nil {
+		panic(exceptions.NewBadRequestError("email is already verified"))
+	}
+
+	latest, err := s.EmailVerificationRepository.FindLatest(ctx, tx, user.ID)
+	if err == nil {
+		if wait := s.Config.ResendInterval - time.Since(latest.CreatedAt); wait > 0 {
+			panic(exceptions.NewTooManyRequestsError("a verification email was sent recently", wait))
+		}
+	}
+
+	sent, err := s.EmailVerificationRepository.CountSince(ctx, tx, user.ID, time.Now().Add(-24*time.Hour))
+	helpers.PanicIfError(err)
+	if sent >= int64(s.Config.MaxPerDay) {
+		panic(exceptions.NewTooManyRequestsError("too many verification emails today", 0))
+	}
+
+	s.Issue(ctx, tx, user)
+}
+
+func (s *EmailVerificationServiceImpl) EmailVerified(ctx context.Context, userId string) bool {
+	user, err := s.UserRepository.GetUserById(ctx, s.DB, userId)
+	return err == nil && user.EmailVerifiedAt != nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"zen-test/app/consts"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	aggregateOrder   = "order"
+	aggregateProduct = "product"
+	aggregateUser    = "user"
+)
+
+// recordEvent writes a domain event to the outbox inside tx, so the event
+// exists exactly when the business change it describes is committed.
+func recordEvent(ctx context.Context, tx *gorm.DB, outboxRepo repositories.OutboxRepository, eventType string, aggregateType string, aggregateId string, userId string, payload interface{}) {
+	encoded, err := json.Marshal(payload)
+	helpers.PanicIfError(err)
+
+	_, err = outboxRepo.SaveEvent(ctx, tx, models.OutboxEvent{
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateId,
+		UserID:        userId,
+		Payload:       string(encoded),
+	})
+	helpers.PanicIfError(err)
+}
+
+func recordStockChanged(ctx context.Context, tx *gorm.DB, productRepo repositories.ProductRepository, outboxRepo repositories.OutboxRepository, productId string, delta int64) {
+	product, err := productRepo.GetProductById(ctx, tx, productId)
+	helpers.PanicIfError(err)
+
+	recordEvent(ctx, tx, outboxRepo, consts.EventStockChanged, aggregateProduct, productId, "", models.StockChangedPayload{
+		ProductID: productId,
+		Delta:     delta,
+		Stock:     product.Stock,
+	})
+}
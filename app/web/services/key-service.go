@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"zen-test/app/auth"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"gorm.io/gorm"
+)
+
+type KeyConfig struct {
+	Algorithm string
+	// RotationInterval is how long a key signs before a successor replaces it.
+	RotationInterval time.Duration
+	// PublishLead is how long a new key is published before it starts
+	// signing, so verifiers caching the JWKS pick it up in time.
+	PublishLead time.Duration
+	// Overlap is how long a replaced key keeps verifying. It must outlast the
+	// access token lifetime.
+	Overlap time.Duration
+	// CheckInterval is how often the scheduler asks whether a rotation is due.
+	CheckInterval time.Duration
+	// RefreshInterval is how often every replica reloads the keys.
+	RefreshInterval time.Duration
+	// Secret seals the private keys at rest.
+	Secret string
+}
+
+type KeyService interface {
+	Rotate(ctx context.Context) error
+	Reload(ctx context.Context) error
+	Start(ctx context.Context)
+	JWKS() auth.JWKS
+}
+
+type KeyServiceImpl struct {
+	SigningKeyRepository repositories.SigningKeyRepository
+	KeySet               *auth.KeySet
+	Config               KeyConfig
+	DB                   *gorm.DB
+}
+
+func NewKeyService(signingKeyRepo repositories.SigningKeyRepository, keySet *auth.KeySet, config KeyConfig, db *gorm.DB) KeyService {
+	return &KeyServiceImpl{
+		SigningKeyRepository: signingKeyRepo,
+		KeySet:               keySet,
+		Config:               config,
+		DB:                   db,
+	}
+}
+
+// Rotate creates a successor once the newest key is due for replacement and
+// schedules the retirement of the keys it replaces. The first key of an empty
+// set signs straight away.
+func (s *KeyServiceImpl) Rotate(ctx context.Context) error {
+	if err := s.rotate(ctx, time.Now()); err != nil {
+		return err
+	}
+	return s.Reload(ctx)
+}
+
+func (s *KeyServiceImpl) rotate(ctx context.Context, now time.Time) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	signingKeys, err := s.SigningKeyRepository.FindUsable(ctx, tx, now)
+	helpers.PanicIfError(err)
+
+	activatesAt := now
+	if len(signingKeys) > 0 {
+		newest := signingKeys[len(signingKeys)-1]
+		if newest.ActivatesAt.Add(s.Config.RotationInterval).After(now.Add(s.Config.PublishLead)) {
+			return nil
+		}
+		activatesAt = now.Add(s.Config.PublishLead)
+	}
+
+	key, err := auth.GenerateSigningKey(s.Config.Algorithm, activatesAt)
+	helpers.PanicIfError(err)
+
+	sealed, err := auth.SealPrivateKey(key.PrivateKey, s.Config.Secret)
+	helpers.PanicIfError(err)
+
+	_, err = s.SigningKeyRepository.Create(ctx, tx, models.SigningKey{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  sealed,
+		ActivatesAt: key.ActivatesAt,
+	})
+	helpers.PanicIfError(err)
+
+	err = s.SigningKeyRepository.RetireOthers(ctx, tx, key.ID, activatesAt.Add(s.Config.Overlap))
+	helpers.PanicIfError(err)
+
+	log.Printf("Signing key %s created, signing from %s", key.ID, activatesAt.Format(time.RFC3339))
+	return nil
+}
+
+// Reload replaces the key set with the keys stored in the database.
+func (s *KeyServiceImpl) Reload(ctx context.Context) error {
+	signingKeys, err := s.SigningKeyRepository.FindUsable(ctx, s.DB, time.Now())
+	if err != nil {
+		return err
+	}
+
+	keys := make([]auth.SigningKey, 0, len(signingKeys))
+	for _, signingKey := range signingKeys {
+		privateKey, err := auth.OpenPrivateKey(signingKey.PrivateKey, s.Config.Secret)
+		if err != nil {
+			log.Printf("Signing key %s skipped: %v", signingKey.ID, err)
+			continue
+		}
+
+		keys = append(keys, auth.SigningKey{
+			ID:          signingKey.ID,
+			Algorithm:   signingKey.Algorithm,
+			PrivateKey:  privateKey,
+			ActivatesAt: signingKey.ActivatesAt,
+			RetiresAt:   signingKey.RetiresAt,
+		})
+	}
+
+	// Keep signing with what we have rather than lock everybody out when
+	// the keys cannot be opened, e.g. after SECRET changed.
+	if len(keys) == 0 && len(signingKeys) > 0 {
+		return fmt.Errorf("none of the %d signing keys could be opened", len(signingKeys))
+	}
+
+	s.KeySet.Replace(keys)
+	return nil
+}
+
+// Start reloads the keys until ctx is done, so rotations made by the
+// scheduler on any replica reach this one.
+func (s *KeyServiceImpl) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("Signing key reload failed: %v", err)
+			}
+		}
+	}
+}
+
+func (s *KeyServiceImpl) JWKS() auth.JWKS {
+	return s.KeySet.JWKS(time.Now())
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"time"
+)
+
+// LoginThrottleConfig slows down password guessing. Failed attempts are
+// counted per email, whether or not it has an account, and per IP.
+type LoginThrottleConfig struct {
+	// Window is how long a failed attempt counts. A successful login also
+	// clears the failures of its email.
+	Window time.Duration
+	// After DelayAfter failures of an email, each attempt has to wait
+	// BaseDelay after the last one, doubled for every further failure up to
+	// MaxDelay.
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// LockoutAfter failures of an email, or IPLockoutAfter failures from one
+	// IP, lock it out until the oldest of them is older than Window.
+	LockoutAfter   int
+	IPLockoutAfter int
+}
+
+// wait returns how long the next attempt has to wait, given the failures of
+// the email and the IP oldest first, and why.
+func (c LoginThrottleConfig) wait(emailFailures []time.Time, ipFailures []time.Time, now time.Time) (time.Duration, string) {
+	if c.IPLockoutAfter > 0 && len(ipFailures) >= c.IPLockoutAfter {
+		if wait := ipFailures[0].Add(c.Window).Sub(now); wait > 0 {
+			return wait, "ip_locked"
+		}
+	}
+
+	failures := len(emailFailures)
+	if c.LockoutAfter > 0 && failures >= c.LockoutAfter {
+		if wait := emailFailures[0].Add(c.Window).Sub(now); wait > 0 {
+			return wait, "account_locked"
+		}
+	}
+
+	if c.DelayAfter > 0 && failures >= c.DelayAfter {
+		delay := c.BaseDelay
+		for i := c.DelayAfter; i < failures && delay < c.MaxDelay; i++ {
+			delay *= 2
+		}
+		if delay > c.MaxDelay {
+			delay = c.MaxDelay
+		}
+
+		if wait := emailFailures[failures-1].Add(delay).Sub(now); wait > 0 {
+			return wait, "delayed"
+		}
+	}
+
+	return 0, ""
+}
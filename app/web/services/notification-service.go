@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/jobs"
+	"zen-test/app/notifications"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"gorm.io/gorm"
+)
+
+type NotificationService interface {
+	Notify(ctx context.Context, event events.Event) error
+	SendEmail(ctx context.Context, event events.Event) error
+	SendLinkEmail(ctx context.Context, email models.LinkEmail) error
+}
+
+type NotificationServiceImpl struct {
+	UserRepository repositories.UserRepository
+	JobQueue       *jobs.Queue
+	Renderer       *notifications.Renderer
+	Notifier       notifications.Notifier
+	DB             *gorm.DB
+}
+
+func NewNotificationService(userRepo repositories.UserRepository, jobQueue *jobs.Queue, renderer *notifications.Renderer, notifier notifications.Notifier, db *gorm.DB) NotificationService {
+	return &NotificationServiceImpl{
+		UserRepository: userRepo,
+		JobQueue:       jobQueue,
+		Renderer:       renderer,
+		Notifier:       notifier,
+		DB:             db,
+	}
+}
+
+type emailData struct {
+	User      models.User
+	Order     models.OrderResponse
+	Link      string
+	ExpiresAt time.Time
+	// To is the address the email goes to, the user's own unless a link
+	// email names another.
+	To string
+}
+
+// Notify queues an email for events that have a template and a user to send
+// it to. Rendering and sending happen in the job, away from the request that
+// caused the event. An event delivered again does not queue a second email.
+func (s *NotificationServiceImpl) Notify(ctx context.Context, event events.Event) error {
+	if event.UserID == "" || !s.Renderer.Has(event.Type) {
+		return nil
+	}
+
+	_, err := s.JobQueue.Enqueue(ctx, s.DB, consts.JobTypeEmail, event, jobs.UniqueKey(consts.JobTypeEmail+":"+event.ID), jobs.ForUser(event.UserID))
+	return err
+}
+
+func (s *NotificationServiceImpl) SendEmail(ctx context.Context, event events.Event) error {
+	data := emailData{}
+	if event.AggregateType == aggregateOrder {
+		err := json.Unmarshal(event.Payload, &data.Order)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.send(ctx, event.UserID, event.Type, data)
+}
+
+func (s *NotificationServiceImpl) SendLinkEmail(ctx context.Context, email models.LinkEmail) error {
+	return s.send(ctx, email.UserID, email.Template, emailData{Link: email.Link, ExpiresAt: email.ExpiresAt, To: email.To})
+}
+
+func (s *NotificationServiceImpl) send(ctx context.Context, userId string, template string, data emailData) error {
+	user, err := s.UserRepository.GetUserById(ctx, s.DB, userId)
+	if err != nil {
+		return err
+	}
+	if data.To == "" {
+		data.To = user.Email
+	}
+	if data.To == "" {
+		return errors.New("user has no email address")
+	}
+	data.User = user
+
+	message, err := s.Renderer.Render(template, user.Locale, data)
+	if err != nil {
+		return err
+	}
+	message.To = data.To
+
+	return s.Notifier.Send(ctx, message)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"strings"
+	"time"
+
+	"zen-test/app/consts"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/notifications"
+	"zen-test/app/oidc"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OIDCConfig struct {
+	// StateTTL is how long a user may take at the identity provider.
+	StateTTL time.Duration
+}
+
+type OIDCService interface {
+	Start(ctx context.Context, provider string) models.OIDCLoginStart
+	Callback(ctx context.Context, provider string, callback models.OIDCCallback, client models.ClientInfo) models.UserLoginResponse
+}
+
+type OIDCServiceImpl struct {
+	Providers                  map[string]*oidc.Provider
+	UserRepository             repositories.UserRepository
+	ExternalIdentityRepository repositories.ExternalIdentityRepository
+	OutboxRepository           repositories.OutboxRepository
+	Users                      UserService
+	Verifications              EmailVerificationService
+	Config                     OIDCConfig
+	DB                         *gorm.DB
+}
+
+func NewOIDCService(providers map[string]*oidc.Provider, userRepo repositories.UserRepository, externalIdentityRepo repositories.ExternalIdentityRepository, outboxRepo repositories.OutboxRepository, users UserService, verifications EmailVerificationService, config OIDCConfig, db *gorm.DB) OIDCService {
+	return &OIDCServiceImpl{
+		Providers:                  providers,
+		UserRepository:             userRepo,
+		ExternalIdentityRepository: externalIdentityRepo,
+		OutboxRepository:           outboxRepo,
+		Users:                      users,
+		Verifications:              verifications,
+		Config:                     config,
+		DB:                         db,
+	}
+}
+
+// Start returns the page of the identity provider to send the user to. The
+// state, nonce and PKCE verifier of the login are kept until they return.
+func (s *OIDCServiceImpl) Start(ctx context.Context, provider string) models.OIDCLoginStart {
+	identityProvider := s.provider(provider)
+
+	state := helpers.RandomToken(32)
+	nonce := helpers.RandomToken(32)
+	codeVerifier, err := oidc.NewCodeVerifier()
+	helpers.PanicIfError(err)
+
+	expiresAt := time.Now().Add(s.Config.StateTTL)
+	_, err = s.ExternalIdentityRepository.CreateState(ctx, s.DB, models.OIDCLoginState{
+		ID:           uuid.New().String(),
+		Provider:     provider,
+		StateHash:    helpers.HashToken(state),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    expiresAt,
+	})
+	helpers.PanicIfError(err)
+
+	authURL, err := identityProvider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		log.Printf("Identity provider %s is unavailable: %v", provider, err)
+		panic(exceptions.NewBadRequestError("identity provider is unavailable"))
+	}
+	return models.OIDCLoginStart{AuthURL: authURL, State: state, ExpiresAt: expiresAt}
+}
+
+// Callback finishes a login at the identity provider. The provider account
+// logs in the user it is linked to; otherwise it is linked to the user with
+// the same email if the provider verified that email, or a new user is
+// signed up for it.
+func (s *OIDCServiceImpl) Callback(ctx context.Context, provider string, callback models.OIDCCallback, client models.ClientInfo) models.UserLoginResponse {
+	identityProvider := s.provider(provider)
+
+	if callback.Error != "" {
+		panic(exceptions.NewUnauthorizedError("login was not completed at the identity provider: " + callback.Error))
+	}
+
+	// Without this a callback for the login of someone else, sent to this
+	// browser, would log it in as them.
+	if callback.BrowserState == "" || subtle.ConstantTimeCompare([]byte(callback.BrowserState), []byte(callback.State)) != 1 {
+		panic(exceptions.NewBadRequestError("login was not started in this browser"))
+	}
+
+	state := s.useState(ctx, provider, callback.State)
+
+	identity, err := identityProvider.Authenticate(ctx, callback.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Login with identity provider %s failed: %v", provider, err)
+		panic(exceptions.NewUnauthorizedError("login with the identity provider failed"))
+	}
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user := s.linkedUser(ctx, tx, provider, identity)
+	return s.Users.CompleteLogin(ctx, tx, user, client, "oidc:"+provider)
+}
+
+func (s *OIDCServiceImpl) provider(name string) *oidc.Provider {
+	identityProvider, ok := s.Providers[name]
+	if !ok {
+		panic(exceptions.NewNotFoundError("unknown identity provider " + name))
+	}
+	return identityProvider
+}
+
+func (s *OIDCServiceImpl) useState(ctx context.Context, provider string, state string) models.OIDCLoginState {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	loginState, err := s.ExternalIdentityRepository.FindState(ctx, tx, helpers.HashToken(state))
+	if err != nil || loginState.Provider != provider || loginState.UsedAt != nil || loginState.ExpiresAt.Before(time.Now()) {
+		panic(exceptions.NewBadRequestError("invalid or expired login state"))
+	}
+
+	used, err := s.ExternalIdentityRepository.UseState(ctx, tx, loginState.ID)
+	helpers.PanicIfError(err)
+	if !used {
+		panic(exceptions.NewBadRequestError("invalid or expired login state"))
+	}
+
+	return loginState
+}
+
+func (s *OIDCServiceImpl) linkedUser(ctx context.Context, tx *gorm.DB, provider string, identity oidc.Identity) models.User {
+	linked, err := s.ExternalIdentityRepository.FindBySubject(ctx, tx, provider, identity.Subject)
+	if err == nil {
+		user, err := s.UserRepository.GetUserById(ctx, tx, linked.UserID)
+		helpers.PanicIfError(err)
+		return user
+	}
+
+	if identity.Email == "" {
+		panic(exceptions.NewBadRequestError("the identity provider did not share an email address"))
+	}
+
+	user, err := s.UserRepository.FindUserByEmail(ctx, tx, identity.Email)
+	if err == nil {
+		// Anyone can claim an address at some providers, so only a verified
+		// one is trusted to take over an existing account.
+		if !identity.EmailVerified {
+			panic(exceptions.NewForbiddenError("an account with this email already exists; log in with your password"))
+		}
+
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			user, err = s.UserRepository.UpdateUser(ctx, tx, user)
+			helpers.PanicIfError(err)
+		}
+	} else {
+		user = s.signUp(ctx, tx, identity)
+	}
+
+	_, err = s.ExternalIdentityRepository.Create(ctx, tx, models.ExternalIdentity{
+		ID:       uuid.New().String(),
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	helpers.PanicIfError(err)
+
+	log.Printf("Linked %s account %s to user %s", provider, identity.Subject, user.ID)
+	return user
+}
+
+func (s *OIDCServiceImpl) signUp(ctx context.Context, tx *gorm.DB, identity oidc.Identity) models.User {
+	name := identity.Name
+	if name == "" {
+		name = strings.Split(identity.Email, "@")[0]
+	}
+
+	// Nobody knows this password; the user can set one with a reset link.
+	password, err := helpers.MakePassword(helpers.RandomToken(32))
+	helpers.PanicIfError(err)
+
+	user := models.User{
+		ID:       uuid.New().String(),
+		Name:     truncate(name, 50),
+		Email:    identity.Email,
+		Password: password,
+		Locale:   notifications.DefaultLocale,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	user, err = s.UserRepository.RegisterUser(ctx, tx, user)
+	helpers.PanicIfError(err)
+
+	if user.EmailVerifiedAt == nil {
+		s.Verifications.Issue(ctx, tx, user)
+	}
+
+	recordEvent(ctx, tx, s.OutboxRepository, consts.EventUserRegistered, aggregateUser, user.ID, user.ID, models.ToUserReponse(user))
+	return user
+}
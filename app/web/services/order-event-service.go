@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+
+	"zen-test/app/auth"
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"gorm.io/gorm"
+)
+
+// replayLimit caps how many missed events a reconnecting client is sent.
+const replayLimit = 500
+
+type OrderEventService interface {
+	OrdersFilter(ctx context.Context, userId string) models.OutboxFilter
+	OrderFilter(ctx context.Context, orderId string, userId string) models.OutboxFilter
+	Replay(ctx context.Context, filter models.OutboxFilter, lastEventId string) []events.Event
+}
+
+type OrderEventServiceImpl struct {
+	OrderRepository  repositories.OrderRepository
+	UserRepository   repositories.UserRepository
+	OutboxRepository repositories.OutboxRepository
+	DB               *gorm.DB
+}
+
+func NewOrderEventService(orderRepo repositories.OrderRepository, userRepo repositories.UserRepository, outboxRepo repositories.OutboxRepository, db *gorm.DB) OrderEventService {
+	return &OrderEventServiceImpl{
+		OrderRepository:  orderRepo,
+		UserRepository:   userRepo,
+		OutboxRepository: outboxRepo,
+		DB:               db,
+	}
+}
+
+// OrdersFilter selects the events of every order the user placed.
+func (s *OrderEventServiceImpl) OrdersFilter(ctx context.Context, userId string) models.OutboxFilter {
+	return models.OutboxFilter{AggregateType: aggregateOrder, UserID: userId}
+}
+
+// OrderFilter selects the events of a single order, which users granted
+// orders:read:all may follow for any order and others only for their own.
+func (s *OrderEventServiceImpl) OrderFilter(ctx context.Context, orderId string, userId string) models.OutboxFilter {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	order, err := s.OrderRepository.LookupOrder(ctx, tx, orderId)
+	if err != nil || (order.UserID != userId && !auth.HasScope(ctx, consts.ScopeOrdersReadAll)) {
+		panic(exceptions.NewNotFoundError("order not found"))
+	}
+
+	return models.OutboxFilter{AggregateType: aggregateOrder, AggregateID: order.ID}
+}
+
+// Replay returns the events matching filter that were published after
+// lastEventId, so a reconnecting client catches up on what it missed.
+func (s *OrderEventServiceImpl) Replay(ctx context.Context, filter models.OutboxFilter, lastEventId string) []events.Event {
+	if lastEventId == "" {
+		return nil
+	}
+
+	lastEvent, err := s.OutboxRepository.FindEvent(ctx, s.DB, lastEventId)
+	if err != nil || lastEvent.PublishedAt == nil {
+		return nil
+	}
+
+	outboxEvents, err := s.OutboxRepository.FindPublishedSince(ctx, s.DB, *lastEvent.PublishedAt, filter, replayLimit)
+	helpers.PanicIfError(err)
+
+	var missed []events.Event
+	for _, outboxEvent := range outboxEvents {
+		if outboxEvent.PublishedAt.Equal(*lastEvent.PublishedAt) && outboxEvent.ID <= lastEvent.ID {
+			continue
+		}
+		missed = append(missed, events.FromOutbox(outboxEvent))
+	}
+	return missed
+}
@@ -2,12 +2,15 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
+	"zen-test/app/auth"
 	"zen-test/app/consts"
 	"zen-test/app/exceptions"
 	"zen-test/app/helpers"
+	"zen-test/app/payments"
 	"zen-test/app/web/models"
 	"zen-test/app/web/repositories"
 
@@ -17,36 +20,63 @@ import (
 )
 
 type OrderService interface {
-	FindAllOrder(ctx context.Context) ([]models.OrderResponse, error)
+	FindAllOrder(ctx context.Context, userId string) ([]models.OrderResponse, error)
 	CreateOrder(ctx context.Context, request models.OrderItemCreateUpdate, userId string) (models.OrderResponse, error)
-	UpdateOrderStatus(ctx context.Context, orderId string, status string) error
-	AutoCancelUnpaidOrders()
-	CancelUnpaidOrders(ctx context.Context)
+	ConfirmPayment(ctx context.Context, orderId string, request models.OrderPayment, staffId string) models.OrderResponse
+	CancelOrder(ctx context.Context, orderId string, request models.OrderCancel, userId string) (models.OrderResponse, error)
+	ShipOrder(ctx context.Context, orderId string, request models.OrderShip, staffId string) models.OrderResponse
+	CancelUnpaidOrders(ctx context.Context) models.OrderExpiryRun
+	RunOrderExpiry(ctx context.Context) models.OrderExpiryRun
+	OrderExpiryStats(ctx context.Context) models.OrderExpiryStats
+}
+
+// OrderExpiryConfig controls the job that cancels orders left unpaid:
+// orders older than Window are cancelled every Interval.
+type OrderExpiryConfig struct {
+	Window   time.Duration
+	Interval time.Duration
 }
 
 type OrderRepositoryImpl struct {
 	OrderRepository   repositories.OrderRepository
 	ProductRepository repositories.ProductRepository
 	UserRepository    repositories.UserRepository
+	AddressRepository repositories.AddressRepository
+	RefundRepository  repositories.RefundRepository
+	OutboxRepository  repositories.OutboxRepository
+	PaymentGateway    payments.Gateway
+	ExpiryConfig      OrderExpiryConfig
 	DB                *gorm.DB
 	Validate          *validator.Validate
 }
 
-func NewOrderService(orderRepo repositories.OrderRepository, productRepo repositories.ProductRepository, userRepo repositories.UserRepository, db *gorm.DB, validate *validator.Validate) OrderService {
+func NewOrderService(orderRepo repositories.OrderRepository, productRepo repositories.ProductRepository, userRepo repositories.UserRepository, addressRepo repositories.AddressRepository, refundRepo repositories.RefundRepository, outboxRepo repositories.OutboxRepository, gateway payments.Gateway, expiryConfig OrderExpiryConfig, db *gorm.DB, validate *validator.Validate) OrderService {
 	return &OrderRepositoryImpl{
 		OrderRepository:   orderRepo,
 		DB:                db,
 		ProductRepository: productRepo,
 		UserRepository:    userRepo,
+		AddressRepository: addressRepo,
+		RefundRepository:  refundRepo,
+		OutboxRepository:  outboxRepo,
+		PaymentGateway:    gateway,
+		ExpiryConfig:      expiryConfig,
 		Validate:          validate,
 	}
 }
 
-func (s *OrderRepositoryImpl) FindAllOrder(ctx context.Context) ([]models.OrderResponse, error) {
+// FindAllOrder lists the orders of the user, or every order for users granted
+// orders:read:all.
+func (s *OrderRepositoryImpl) FindAllOrder(ctx context.Context, userId string) ([]models.OrderResponse, error) {
 	tx := s.DB.Begin()
 	defer helpers.CommitOrRollback(tx)
 
-	data, err := s.OrderRepository.FindAllOrder(ctx, tx)
+	ownerId := userId
+	if auth.HasScope(ctx, consts.ScopeOrdersReadAll) {
+		ownerId = ""
+	}
+
+	data, err := s.OrderRepository.FindAllOrder(ctx, tx, ownerId)
 	helpers.PanicIfError(err)
 
 	return models.ToOrderResponses(data), nil
@@ -73,18 +103,21 @@ func (s *OrderRepositoryImpl) CreateOrder(ctx context.Context, request models.Or
 		panic("Product is out of stock")
 	}
 
+	shipping := s.shippingAddress(ctx, tx, user, request.AddressID)
+
 	taxAmount := CountTax(product.Price, request.Quantity, consts.TaxRate)
 	totalPrice := (product.Price * float64(request.Quantity)) - taxAmount
 
 	order := models.Order{
-		ID:           uuid.New().String(),
-		UserID:       user.ID,
-		IsPaid:       false,
-		Status:       consts.OrderPaymentStatusUnpaid,
-		CustomerName: user.Name,
-		Phone:        user.Phone,
-		TotalPrice:   totalPrice,
-		Address:      user.Address,
+		ID:              uuid.New().String(),
+		UserID:          user.ID,
+		IsPaid:          false,
+		Status:          consts.OrderPaymentStatusUnpaid,
+		CustomerName:    user.Name,
+		Phone:           shipping.Phone,
+		TotalPrice:      totalPrice,
+		Address:         shipping.String(),
+		ShippingAddress: shipping,
 	}
 	orderCreated, err := s.OrderRepository.CreateOrder(ctx, tx, order)
 	helpers.PanicIfError(err)
@@ -99,63 +132,230 @@ func (s *OrderRepositoryImpl) CreateOrder(ctx context.Context, request models.Or
 		OrderID:   order.ID,
 		ProductID: product.ID,
 		Quantity:  request.Quantity,
+		Price:     product.Price,
 	}
 	_, err = s.OrderRepository.CreateOrderItem(ctx, tx, orderItem)
 	helpers.PanicIfError(err)
 
-	return models.ToOrderResponse(orderCreated), nil
+	orderItem.Product = product
+	orderCreated.OrderItems = []models.OrderItem{orderItem}
+	orderResponse := models.ToOrderResponse(orderCreated)
+
+	recordStockChanged(ctx, tx, s.ProductRepository, s.OutboxRepository, product.ID, -int64(request.Quantity))
+	recordEvent(ctx, tx, s.OutboxRepository, consts.EventOrderCreated, aggregateOrder, order.ID, user.ID, orderResponse)
+
+	return orderResponse, nil
 }
 
-func (s *OrderRepositoryImpl) UpdateOrderStatus(ctx context.Context, orderId string, status string) error {
+// shippingAddress picks where an order ships: the address chosen, else the
+// user's default address. Users without an address book ship to the address
+// on their account.
+func (s *OrderRepositoryImpl) shippingAddress(ctx context.Context, tx *gorm.DB, user models.User, addressId string) models.ShippingAddress {
+	if addressId != "" {
+		return findOwnAddress(ctx, tx, s.AddressRepository, addressId, user.ID).Snapshot()
+	}
+
+	address, err := s.AddressRepository.FindDefault(ctx, tx, user.ID)
+	if err != nil {
+		return models.ShippingAddress{Recipient: user.Name, Street: user.Address, Phone: user.Phone}
+	}
+	return address.Snapshot()
+}
+
+// ConfirmPayment marks an unpaid order paid once its payment has come in.
+// Payments are settled outside the application, so staff confirm them with
+// the reference the payment came with.
+func (s *OrderRepositoryImpl) ConfirmPayment(ctx context.Context, orderId string, request models.OrderPayment, staffId string) models.OrderResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
 	tx := s.DB.Begin()
 	defer helpers.CommitOrRollback(tx)
 
-	order, err := s.OrderRepository.FindOrder(ctx, tx, orderId)
+	ensureScope(ctx, consts.ScopeOrdersWriteAll)
+
+	order, err := s.OrderRepository.LockOrder(ctx, tx, orderId)
 	if err != nil {
-		panic(exceptions.NewNotFoundError(err.Error()))
+		panic(exceptions.NewNotFoundError("order not found"))
 	}
 
-	order.Status = status
-	if status == consts.OrderPaymentStatusPaid {
-		order.IsPaid = true
-		order.Status = consts.OrderPaymentStatusPaid
-	} else if status == consts.OrderPaymentStatusCancel {
-		order.IsPaid = false
-		order.Status = consts.OrderPaymentStatusCancel
+	if order.Status != consts.OrderPaymentStatusUnpaid {
+		panic(exceptions.NewBadRequestError("only unpaid orders can be paid"))
 	}
 
-	_, err = s.OrderRepository.UpdateOrder(ctx, tx, order)
-	if err != nil {
-		return err
-	}
+	paidAt := time.Now()
+	order.IsPaid = true
+	order.Status = consts.OrderPaymentStatusPaid
+	order.PaymentRef = request.Reference
+	order.PaidAt = &paidAt
 
-	return nil
+	orderUpdate := order
+	orderUpdate.OrderItems = nil
+	_, err = s.OrderRepository.UpdateOrder(ctx, tx, orderUpdate)
+	helpers.PanicIfError(err)
+
+	orderResponse := models.ToOrderResponse(order)
+	recordEvent(ctx, tx, s.OutboxRepository, consts.EventOrderPaid, aggregateOrder, order.ID, order.UserID, orderResponse)
+
+	return orderResponse
 }
 
-func (s *OrderRepositoryImpl) AutoCancelUnpaidOrders() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
+func (s *OrderRepositoryImpl) CancelOrder(ctx context.Context, orderId string, request models.OrderCancel, userId string) (models.OrderResponse, error) {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
 
-	for {
-		<-ticker.C
-		ctx := context.Background()
-		s.CancelUnpaidOrders(ctx)
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	order, err := s.OrderRepository.LockOrder(ctx, tx, orderId)
+	if err != nil || order.UserID != userId {
+		panic(exceptions.NewNotFoundError("order not found"))
 	}
+
+	if order.Status != consts.OrderPaymentStatusUnpaid && order.Status != consts.OrderPaymentStatusPaid {
+		panic(exceptions.NewBadRequestError("order can no longer be cancelled"))
+	}
+
+	order = s.cancel(ctx, tx, order, request.Reason)
+
+	return models.ToOrderResponse(order), nil
 }
 
-func (s *OrderRepositoryImpl) CancelUnpaidOrders(ctx context.Context) {
+func (s *OrderRepositoryImpl) ShipOrder(ctx context.Context, orderId string, request models.OrderShip, staffId string) models.OrderResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
 	tx := s.DB.Begin()
 	defer helpers.CommitOrRollback(tx)
 
-	orders, err := s.OrderRepository.GetUnpaidOrdersOlderThan(ctx, tx, 1*time.Hour)
+	ensureScope(ctx, consts.ScopeOrdersWriteAll)
+
+	order, err := s.OrderRepository.LockOrder(ctx, tx, orderId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError("order not found"))
+	}
+
+	if order.Status != consts.OrderPaymentStatusPaid {
+		panic(exceptions.NewBadRequestError("only paid orders can be shipped"))
+	}
+
+	shippedAt := time.Now()
+	order.Status = consts.OrderPaymentStatusShipped
+	order.Carrier = request.Carrier
+	order.TrackingNumber = request.TrackingNumber
+	order.ShippedAt = &shippedAt
+
+	orderUpdate := order
+	orderUpdate.OrderItems = nil
+	_, err = s.OrderRepository.UpdateOrder(ctx, tx, orderUpdate)
+	helpers.PanicIfError(err)
+
+	orderResponse := models.ToOrderResponse(order)
+	recordEvent(ctx, tx, s.OutboxRepository, consts.EventOrderShipped, aggregateOrder, order.ID, order.UserID, orderResponse)
+
+	return orderResponse
+}
+
+// cancel restores the stock held by the order, refunds whatever was paid and
+// marks the order cancelled with the given reason. The caller loads the order
+// with LockOrder, so concurrent cancels cannot both get here.
+func (s *OrderRepositoryImpl) cancel(ctx context.Context, tx *gorm.DB, order models.Order, reason string) models.Order {
+	for _, orderItem := range order.OrderItems {
+		err := s.ProductRepository.IncreaseStock(ctx, tx, orderItem.ProductID, orderItem.Quantity)
+		helpers.PanicIfError(err)
+
+		recordStockChanged(ctx, tx, s.ProductRepository, s.OutboxRepository, orderItem.ProductID, int64(orderItem.Quantity))
+	}
+
+	if order.IsPaid && order.TotalPrice > order.RefundedAmount {
+		order = refundOrder(ctx, tx, s.PaymentGateway, s.RefundRepository, s.OrderRepository, order, order.TotalPrice-order.RefundedAmount, "", reason)
+	}
+
+	cancelledAt := time.Now()
+	order.Status = consts.OrderPaymentStatusCancel
+	order.CancelReason = reason
+	order.CancelledAt = &cancelledAt
+
+	orderUpdate := order
+	orderUpdate.OrderItems = nil
+	_, err := s.OrderRepository.UpdateOrder(ctx, tx, orderUpdate)
+	helpers.PanicIfError(err)
+
+	recordEvent(ctx, tx, s.OutboxRepository, consts.EventOrderCancelled, aggregateOrder, order.ID, order.UserID, models.ToOrderResponse(order))
+
+	return order
+}
+
+func (s *OrderRepositoryImpl) CancelUnpaidOrders(ctx context.Context) models.OrderExpiryRun {
+	run := models.OrderExpiryRun{StartedAt: time.Now()}
+
+	orders, err := s.OrderRepository.GetUnpaidOrdersOlderThan(ctx, s.DB, s.ExpiryConfig.Window)
 	if err != nil {
 		log.Printf("Error fetching unpaid orders: %v", err)
-		return
+		run.Failed++
 	}
 
+	run.Scanned = len(orders)
 	for _, order := range orders {
-		s.UpdateOrderStatus(ctx, order.ID, consts.OrderPaymentStatusCancel)
+		if err := s.expireOrder(ctx, order.ID); err != nil {
+			log.Printf("Error cancelling unpaid order %s: %v", order.ID, err)
+			run.Failed++
+			continue
+		}
+		run.Cancelled++
+	}
+	run.FinishedAt = time.Now()
+
+	log.Printf("Order expiry run finished: scanned=%d cancelled=%d failed=%d duration=%s", run.Scanned, run.Cancelled, run.Failed, run.FinishedAt.Sub(run.StartedAt))
+
+	err = s.OrderRepository.RecordExpiryRun(ctx, s.DB, run)
+	if err != nil {
+		log.Printf("Error recording order expiry run: %v", err)
+	}
+
+	return run
+}
+
+// expireOrder cancels a single order in its own transaction so one bad order
+// does not roll back the rest of the run.
+func (s *OrderRepositoryImpl) expireOrder(ctx context.Context, orderId string) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	order, err := s.OrderRepository.LockOrder(ctx, tx, orderId)
+	helpers.PanicIfError(err)
+
+	// The order may have been paid or cancelled since it was scanned.
+	if order.Status != consts.OrderPaymentStatusUnpaid {
+		return nil
 	}
+
+	s.cancel(ctx, tx, order, "payment window expired")
+	return nil
+}
+
+func (s *OrderRepositoryImpl) RunOrderExpiry(ctx context.Context) models.OrderExpiryRun {
+	ensureScope(ctx, consts.ScopeOrdersWriteAll)
+
+	return s.CancelUnpaidOrders(ctx)
+}
+
+func (s *OrderRepositoryImpl) OrderExpiryStats(ctx context.Context) models.OrderExpiryStats {
+	ensureScope(ctx, consts.ScopeOrdersReadAll)
+
+	totals, err := s.OrderRepository.FindExpiryTotals(ctx, s.DB)
+	helpers.PanicIfError(err)
+
+	stats := models.ToOrderExpiryStats(totals)
+	stats.Window = s.ExpiryConfig.Window.String()
+	stats.Interval = s.ExpiryConfig.Interval.String()
+	return stats
 }
 
 func CountTax(price float64, qty uint32, taxRate float64) float64 {
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"zen-test/app/consts"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/jobs"
+	"zen-test/app/notifications"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordResetConfig struct {
+	// TokenTTL is how long a reset link works.
+	TokenTTL time.Duration
+	// Throttle is the least time between two reset emails to one user.
+	Throttle time.Duration
+	// URL is the page the emailed link opens; the token is added as the
+	// token query parameter.
+	URL string
+}
+
+type PasswordResetService interface {
+	Forgot(ctx context.Context, request models.PasswordForgot)
+	Reset(ctx context.Context, request models.PasswordReset)
+}
+
+type PasswordResetServiceImpl struct {
+	UserRepository          repositories.UserRepository
+	PasswordResetRepository repositories.PasswordResetRepository
+	RefreshTokenRepository  repositories.RefreshTokenRepository
+	SessionRepository       repositories.SessionRepository
+	Sessions                SessionService
+	JobQueue                *jobs.Queue
+	Config                  PasswordResetConfig
+	DB                      *gorm.DB
+	Validate                *validator.Validate
+}
+
+func NewPasswordResetService(userRepo repositories.UserRepository, passwordResetRepo repositories.PasswordResetRepository, refreshTokenRepo repositories.RefreshTokenRepository, sessionRepo repositories.SessionRepository, sessions SessionService, jobQueue *jobs.Queue, config PasswordResetConfig, db *gorm.DB, validate *validator.Validate) PasswordResetService {
+	return &PasswordResetServiceImpl{
+		UserRepository:          userRepo,
+		PasswordResetRepository: passwordResetRepo,
+		RefreshTokenRepository:  refreshTokenRepo,
+		SessionRepository:       sessionRepo,
+		Sessions:                sessions,
+		JobQueue:                jobQueue,
+		Config:                  config,
+		DB:                      db,
+		Validate:                validate,
+	}
+}
+
+// Forgot mails a reset link to the account of the email, if there is one. It
+// behaves the same either way so the endpoint cannot be used to find out who
+// has an account.
+func (s *PasswordResetServiceImpl) Forgot(ctx context.Context, request models.PasswordForgot) {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user, err := s.UserRepository.FindUserByEmail(ctx, tx, request.Email)
+	if err != nil {
+		return
+	}
+
+	latest, err := s.PasswordResetRepository.FindLatest(ctx, tx, user.ID)
+	if err == nil && time.Since(latest.CreatedAt) < s.Config.Throttle {
+		log.Printf("Password reset for user %s throttled", user.ID)
+		return
+	}
+
+	// Only the newest link works.
+	err = s.PasswordResetRepository.InvalidateUser(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	token := helpers.RandomToken(32)
+	resetToken, err := s.PasswordResetRepository.Create(ctx, tx, models.PasswordResetToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: time.Now().Add(s.Config.TokenTTL),
+	})
+	helpers.PanicIfError(err)
+
+	_, err = s.JobQueue.Enqueue(ctx, tx, consts.JobTypeLinkEmail, models.LinkEmail{
+		UserID:    user.ID,
+		Template:  notifications.TemplatePasswordReset,
+		Link:      tokenLink(s.Config.URL, token),
+		ExpiresAt: resetToken.ExpiresAt,
+	}, jobs.ForUser(user.ID))
+	helpers.PanicIfError(err)
+}
+
+// Reset sets the new password and signs the user out everywhere, since
+// whoever knew the old password may still hold a session.
+func (s *PasswordResetServiceImpl) Reset(ctx context.Context, request models.PasswordReset) {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	resetToken, err := s.PasswordResetRepository.FindByHash(ctx, tx, helpers.HashToken(request.Token))
+	if err != nil || resetToken.UsedAt != nil || resetToken.ExpiresAt.Before(time.Now()) {
+		panic(exceptions.NewBadRequestError("invalid or expired reset token"))
+	}
+
+	used, err := s.PasswordResetRepository.Use(ctx, tx, resetToken.ID)
+	helpers.PanicIfError(err)
+	if !used {
+		panic(exceptions.NewBadRequestError("invalid or expired reset token"))
+	}
+
+	user, err := s.UserRepository.GetUserById(ctx, tx, resetToken.UserID)
+	helpers.PanicIfError(err)
+
+	hashPassword, err := helpers.MakePassword(request.Password)
+	helpers.PanicIfError(err)
+	user.Password = hashPassword
+
+	_, err = s.UserRepository.UpdateUser(ctx, tx, user)
+	helpers.PanicIfError(err)
+
+	err = s.PasswordResetRepository.InvalidateUser(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	sessions, err := s.SessionRepository.FindActiveSessions(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	err = s.SessionRepository.RevokeUser(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	err = s.RefreshTokenRepository.RevokeUser(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	for _, session := range sessions {
+		s.Sessions.Forget(session.ID)
+	}
+}
+
+// tokenLink adds the token to the query of the page an emailed link opens.
+func tokenLink(page string, token string) string {
+	link, err := url.Parse(page)
+	helpers.PanicIfError(err)
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"zen-test/app/consts"
 	"zen-test/app/exceptions"
 	"zen-test/app/helpers"
 	"zen-test/app/web/models"
@@ -17,6 +18,7 @@ import (
 type ProductServiceImpl struct {
 	ProductRepository repositories.ProductRepository
 	ImageRepository   repositories.ImageRepositoy
+	OutboxRepository  repositories.OutboxRepository
 	DB                *gorm.DB
 	Validate          *validator.Validate
 }
@@ -29,10 +31,11 @@ type ProductService interface {
 	FindAll(ctx context.Context) []models.ProductResponse
 }
 
-func NewProductService(productRepo repositories.ProductRepository, imageRepo repositories.ImageRepositoy, db *gorm.DB, validate *validator.Validate) ProductService {
+func NewProductService(productRepo repositories.ProductRepository, imageRepo repositories.ImageRepositoy, outboxRepo repositories.OutboxRepository, db *gorm.DB, validate *validator.Validate) ProductService {
 	return &ProductServiceImpl{
 		ProductRepository: productRepo,
 		ImageRepository:   imageRepo,
+		OutboxRepository:  outboxRepo,
 		DB:                db,
 		Validate:          validate,
 	}
@@ -71,7 +74,11 @@ func (s *ProductServiceImpl) Create(ctx context.Context, request models.ProductC
 	data, err := s.ProductRepository.CreateProduct(ctx, tx, product)
 	helpers.PanicIfError(err)
 
-	return models.ToProductResponse(data)
+	productResponse := models.ToProductResponse(data)
+	recordEvent(ctx, tx, s.OutboxRepository, consts.EventProductUpdated, aggregateProduct, data.ID, "", productResponse)
+	recordStockChanged(ctx, tx, s.ProductRepository, s.OutboxRepository, data.ID, int64(data.Stock))
+
+	return productResponse
 }
 
 func (s *ProductServiceImpl) Update(ctx context.Context, request models.ProductCreateUpdate, productId string) models.ProductResponse {
@@ -86,6 +93,7 @@ func (s *ProductServiceImpl) Update(ctx context.Context, request models.ProductC
 		panic(exceptions.NewNotFoundError(err.Error()))
 	}
 
+	previousStock := product.Stock
 	var updatedImages []models.Image
 
 	for _, updateImage := range request.Images {
@@ -109,7 +117,13 @@ func (s *ProductServiceImpl) Update(ctx context.Context, request models.ProductC
 	data, err := s.ProductRepository.UpdateProduct(ctx, tx, product)
 	helpers.PanicIfError(err)
 
-	return models.ToProductResponse(data)
+	productResponse := models.ToProductResponse(data)
+	recordEvent(ctx, tx, s.OutboxRepository, consts.EventProductUpdated, aggregateProduct, data.ID, "", productResponse)
+	if data.Stock != previousStock {
+		recordStockChanged(ctx, tx, s.ProductRepository, s.OutboxRepository, data.ID, int64(data.Stock)-int64(previousStock))
+	}
+
+	return productResponse
 }
 
 func (s *ProductServiceImpl) Delete(ctx context.Context, productId string) {
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+
+	"zen-test/app/consts"
+	"zen-test/app/helpers"
+	"zen-test/app/payments"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// refundOrder sends the refund through the payment gateway, records it and
+// moves the order into its partially or fully refunded state, within the
+// caller's transaction. The gateway moves the money before anything is
+// written, so a transaction rolled back after it has returned leaves a refund
+// without a record: callers lock the order and run their checks first.
+func refundOrder(ctx context.Context, tx *gorm.DB, gateway payments.Gateway, refundRepo repositories.RefundRepository, orderRepo repositories.OrderRepository, order models.Order, amount float64, returnId string, reason string) models.Order {
+	result, err := gateway.Refund(ctx, payments.RefundRequest{
+		OrderID: order.ID,
+		Amount:  amount,
+		Reason:  reason,
+	})
+	helpers.PanicIfError(err)
+
+	_, err = refundRepo.CreateRefund(ctx, tx, models.Refund{
+		ID:              uuid.New().String(),
+		OrderID:         order.ID,
+		ReturnRequestID: returnId,
+		Amount:          amount,
+		Status:          consts.RefundStatusSucceeded,
+		Reference:       result.Reference,
+	})
+	helpers.PanicIfError(err)
+
+	order.RefundedAmount = order.RefundedAmount + amount
+	if order.RefundedAmount >= order.TotalPrice {
+		order.Status = consts.OrderPaymentStatusRefunded
+	} else {
+		order.Status = consts.OrderPaymentStatusPartiallyRefunded
+	}
+
+	orderUpdate := order
+	orderUpdate.OrderItems = nil
+	_, err = orderRepo.UpdateOrder(ctx, tx, orderUpdate)
+	helpers.PanicIfError(err)
+
+	return order
+}
+
+func CountLineTotal(price float64, qty uint32, taxRate float64) float64 {
+	return (price * float64(qty)) - CountTax(price, qty, taxRate)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"math"
+
+	"zen-test/app/auth"
+	"zen-test/app/consts"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/payments"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReturnService interface {
+	Create(ctx context.Context, request models.ReturnRequestCreate, userId string) models.ReturnRequestResponse
+	FindAll(ctx context.Context, userId string) []models.ReturnRequestResponse
+	FindById(ctx context.Context, returnId string, userId string) models.ReturnRequestResponse
+	Approve(ctx context.Context, returnId string, request models.ReturnRequestReview, staffId string) models.ReturnRequestResponse
+	Reject(ctx context.Context, returnId string, request models.ReturnRequestReview, staffId string) models.ReturnRequestResponse
+	Receive(ctx context.Context, returnId string, staffId string) models.ReturnRequestResponse
+}
+
+type ReturnServiceImpl struct {
+	ReturnRepository  repositories.ReturnRepository
+	OrderRepository   repositories.OrderRepository
+	ProductRepository repositories.ProductRepository
+	UserRepository    repositories.UserRepository
+	RefundRepository  repositories.RefundRepository
+	OutboxRepository  repositories.OutboxRepository
+	PaymentGateway    payments.Gateway
+	DB                *gorm.DB
+	Validate          *validator.Validate
+}
+
+func NewReturnService(returnRepo repositories.ReturnRepository, orderRepo repositories.OrderRepository, productRepo repositories.ProductRepository, userRepo repositories.UserRepository, refundRepo repositories.RefundRepository, outboxRepo repositories.OutboxRepository, gateway payments.Gateway, db *gorm.DB, validate *validator.Validate) ReturnService {
+	return &ReturnServiceImpl{
+		ReturnRepository:  returnRepo,
+		OrderRepository:   orderRepo,
+		ProductRepository: productRepo,
+		UserRepository:    userRepo,
+		RefundRepository:  refundRepo,
+		OutboxRepository:  outboxRepo,
+		PaymentGateway:    gateway,
+		DB:                db,
+		Validate:          validate,
+	}
+}
+
+func (s *ReturnServiceImpl) Create(ctx context.Context, request models.ReturnRequestCreate, userId string) models.ReturnRequestResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	// Locking the order keeps concurrent requests from together returning
+	// more than was bought.
+	order, err := s.OrderRepository.LockOrder(ctx, tx, request.OrderID)
+	if err != nil || order.UserID != userId {
+		panic(exceptions.NewNotFoundError("order not found"))
+	}
+
+	if order.Status != consts.OrderPaymentStatusPaid && order.Status != consts.OrderPaymentStatusShipped && order.Status != consts.OrderPaymentStatusPartiallyRefunded {
+		panic(exceptions.NewBadRequestError("only paid orders can be returned"))
+	}
+
+	orderItems := make(map[string]models.OrderItem)
+	for _, orderItem := range order.OrderItems {
+		orderItems[orderItem.ID] = orderItem
+	}
+
+	returnRequest := models.ReturnRequest{
+		ID:      uuid.New().String(),
+		OrderID: order.ID,
+		UserID:  userId,
+		Reason:  request.Reason,
+		Status:  consts.ReturnStatusRequested,
+	}
+
+	requested := make(map[string]uint32)
+	for _, item := range request.Items {
+		orderItem, ok := orderItems[item.OrderItemID]
+		if !ok {
+			panic(exceptions.NewBadRequestError("order item " + item.OrderItemID + " does not belong to this order"))
+		}
+
+		returned, err := s.ReturnRepository.CountReturnedQuantity(ctx, tx, orderItem.ID)
+		helpers.PanicIfError(err)
+
+		requested[orderItem.ID] += item.Quantity
+		if returned+requested[orderItem.ID] > orderItem.Quantity {
+			panic(exceptions.NewBadRequestError("return quantity exceeds purchased quantity for order item " + orderItem.ID))
+		}
+
+		returnRequest.Items = append(returnRequest.Items, models.ReturnItem{
+			ID:              uuid.New().String(),
+			ReturnRequestID: returnRequest.ID,
+			OrderItemID:     orderItem.ID,
+			OrderItem:       orderItem,
+			Quantity:        item.Quantity,
+		})
+	}
+
+	data, err := s.ReturnRepository.CreateReturnRequest(ctx, tx, returnRequest)
+	helpers.PanicIfError(err)
+
+	return models.ToReturnRequestResponse(data)
+}
+
+func (s *ReturnServiceImpl) FindAll(ctx context.Context, userId string) []models.ReturnRequestResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ownerId := userId
+	if auth.HasScope(ctx, consts.ScopeReturnsReadAll) {
+		ownerId = ""
+	}
+
+	returnRequests, err := s.ReturnRepository.FindReturnRequests(ctx, tx, ownerId)
+	helpers.PanicIfError(err)
+
+	return models.ToReturnRequestResponses(returnRequests)
+}
+
+func (s *ReturnServiceImpl) FindById(ctx context.Context, returnId string, userId string) models.ReturnRequestResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	returnRequest, err := s.ReturnRepository.FindReturnRequest(ctx, tx, returnId)
+	if err != nil || (returnRequest.UserID != userId && !auth.HasScope(ctx, consts.ScopeReturnsReadAll)) {
+		panic(exceptions.NewNotFoundError("return request not found"))
+	}
+
+	return models.ToReturnRequestResponse(returnRequest)
+}
+
+func (s *ReturnServiceImpl) Approve(ctx context.Context, returnId string, request models.ReturnRequestReview, staffId string) models.ReturnRequestResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeReturnsWriteAll)
+	returnRequest := s.findPending(ctx, tx, returnId, consts.ReturnStatusRequested, consts.ReturnStatusApproved)
+
+	// The order is locked so approvals of its other returns wait, and the
+	// amount left to refund is the one they leave.
+	order, err := s.OrderRepository.LockOrder(ctx, tx, returnRequest.OrderID)
+	if err != nil {
+		panic(exceptions.NewNotFoundError(err.Error()))
+	}
+
+	var refundable float64
+	for _, item := range returnRequest.Items {
+		refundable += CountLineTotal(item.OrderItem.Price, item.Quantity, consts.TaxRate)
+	}
+	refundable = math.Min(refundable, order.TotalPrice-order.RefundedAmount)
+
+	amount := refundable
+	if request.RefundAmount > 0 {
+		if request.RefundAmount > refundable {
+			panic(exceptions.NewBadRequestError("refund amount exceeds the value of the returned items"))
+		}
+		amount = request.RefundAmount
+	}
+
+	if amount > 0 {
+		refundOrder(ctx, tx, s.PaymentGateway, s.RefundRepository, s.OrderRepository, order, amount, returnRequest.ID, returnRequest.Reason)
+	}
+
+	returnRequest.Status = consts.ReturnStatusApproved
+	returnRequest.ReviewNote = request.Note
+	returnRequest.ReviewedBy = staffId
+	returnRequest.RefundAmount = amount
+
+	_, err = s.ReturnRepository.UpdateReturnRequest(ctx, tx, returnRequest)
+	helpers.PanicIfError(err)
+
+	return models.ToReturnRequestResponse(returnRequest)
+}
+
+func (s *ReturnServiceImpl) Reject(ctx context.Context, returnId string, request models.ReturnRequestReview, staffId string) models.ReturnRequestResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeReturnsWriteAll)
+	returnRequest := s.findPending(ctx, tx, returnId, consts.ReturnStatusRequested, consts.ReturnStatusRejected)
+
+	returnRequest.Status = consts.ReturnStatusRejected
+	returnRequest.ReviewNote = request.Note
+	returnRequest.ReviewedBy = staffId
+
+	_, err = s.ReturnRepository.UpdateReturnRequest(ctx, tx, returnRequest)
+	helpers.PanicIfError(err)
+
+	return models.ToReturnRequestResponse(returnRequest)
+}
+
+func (s *ReturnServiceImpl) Receive(ctx context.Context, returnId string, staffId string) models.ReturnRequestResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeReturnsWriteAll)
+	returnRequest := s.findPending(ctx, tx, returnId, consts.ReturnStatusApproved, consts.ReturnStatusReceived)
+
+	for _, item := range returnRequest.Items {
+		err := s.ProductRepository.IncreaseStock(ctx, tx, item.OrderItem.ProductID, item.Quantity)
+		helpers.PanicIfError(err)
+
+		recordStockChanged(ctx, tx, s.ProductRepository, s.OutboxRepository, item.OrderItem.ProductID, int64(item.Quantity))
+	}
+
+	returnRequest.Status = consts.ReturnStatusReceived
+
+	_, err := s.ReturnRepository.UpdateReturnRequest(ctx, tx, returnRequest)
+	helpers.PanicIfError(err)
+
+	return models.ToReturnRequestResponse(returnRequest)
+}
+
+// findPending loads a return request in status and moves it on to next. The
+// move is conditional, so of two reviews racing on the same request only one
+// goes on; the other is told the request has moved.
+func (s *ReturnServiceImpl) findPending(ctx context.Context, tx *gorm.DB, returnId string, status string, next string) models.ReturnRequest {
+	returnRequest, err := s.ReturnRepository.FindReturnRequest(ctx, tx, returnId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError("return request not found"))
+	}
+
+	if returnRequest.Status != status {
+		panic(exceptions.NewBadRequestError("return request is " + returnRequest.Status))
+	}
+
+	moved, err := s.ReturnRepository.MoveReturnRequest(ctx, tx, returnRequest.ID, status, next)
+	helpers.PanicIfError(err)
+	if !moved {
+		panic(exceptions.NewBadRequestError("return request is no longer " + status))
+	}
+	return returnRequest
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+
+	"zen-test/app/auth"
+	"zen-test/app/exceptions"
+)
+
+// ensureScope refuses the request unless it was granted scope. Routes check
+// their scope before the service is reached; this keeps the service safe when
+// it is called some other way.
+func ensureScope(ctx context.Context, scope string) {
+	if !auth.HasScope(ctx, scope) {
+		panic(exceptions.NewForbiddenError("missing scope " + scope))
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"gorm.io/gorm"
+)
+
+type SessionService interface {
+	FindAll(ctx context.Context, userId string, currentSessionId string) []models.SessionResponse
+	Revoke(ctx context.Context, sessionId string, userId string)
+	RevokeAll(ctx context.Context, tx *gorm.DB, userId string)
+	SessionActive(ctx context.Context, sessionId string, userId string) bool
+	Forget(sessionId string)
+	Account(ctx context.Context, userId string) AccountStatus
+	ForgetAccount(userId string)
+}
+
+// AccountStatus is what authenticating a request needs to know of its user.
+// Users that do not exist have no role and are not disabled.
+type AccountStatus struct {
+	Role     string
+	Disabled bool
+}
+
+type SessionServiceImpl struct {
+	SessionRepository      repositories.SessionRepository
+	RefreshTokenRepository repositories.RefreshTokenRepository
+	UserRepository         repositories.UserRepository
+	DB                     *gorm.DB
+	// CacheTTL is how long a session or account check is trusted. A session
+	// revoked or an account disabled on another replica keeps working here
+	// for at most this long.
+	CacheTTL time.Duration
+
+	mutex    sync.Mutex
+	cache    map[string]sessionCacheEntry
+	accounts map[string]accountCacheEntry
+	sweptAt  time.Time
+}
+
+type sessionCacheEntry struct {
+	userId    string
+	active    bool
+	checkedAt time.Time
+}
+
+type accountCacheEntry struct {
+	status    AccountStatus
+	checkedAt time.Time
+}
+
+func NewSessionService(sessionRepo repositories.SessionRepository, refreshTokenRepo repositories.RefreshTokenRepository, userRepo repositories.UserRepository, cacheTTL time.Duration, db *gorm.DB) SessionService {
+	return &SessionServiceImpl{
+		SessionRepository:      sessionRepo,
+		RefreshTokenRepository: refreshTokenRepo,
+		UserRepository:         userRepo,
+		DB:                     db,
+		CacheTTL:               cacheTTL,
+		cache:                  make(map[string]sessionCacheEntry),
+		accounts:               make(map[string]accountCacheEntry),
+	}
+}
+
+func (s *SessionServiceImpl) FindAll(ctx context.Context, userId string, currentSessionId string) []models.SessionResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	sessions, err := s.SessionRepository.FindActiveSessions(ctx, tx, userId)
+	helpers.PanicIfError(err)
+
+	return models.ToSessionResponses(sessions, currentSessionId)
+}
+
+// Revoke ends a session of the user: its refresh tokens stop working at once
+// and its access tokens as soon as every replica's cache has expired.
+func (s *SessionServiceImpl) Revoke(ctx context.Context, sessionId string, userId string) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	session, err := s.SessionRepository.FindSession(ctx, tx, sessionId)
+	if err != nil || session.UserID != userId {
+		panic(exceptions.NewNotFoundError("session not found"))
+	}
+
+	err = s.SessionRepository.Revoke(ctx, tx, session.ID)
+	helpers.PanicIfError(err)
+
+	err = s.RefreshTokenRepository.RevokeFamily(ctx, tx, session.ID)
+	helpers.PanicIfError(err)
+
+	s.Forget(session.ID)
+}
+
+// RevokeAll ends every session of the user within tx, along with all of
+// their refresh tokens.
+func (s *SessionServiceImpl) RevokeAll(ctx context.Context, tx *gorm.DB, userId string) {
+	sessions, err := s.SessionRepository.FindActiveSessions(ctx, tx, userId)
+	helpers.PanicIfError(err)
+
+	err = s.SessionRepository.RevokeUser(ctx, tx, userId)
+	helpers.PanicIfError(err)
+
+	err = s.RefreshTokenRepository.RevokeUser(ctx, tx, userId)
+	helpers.PanicIfError(err)
+
+	for _, session := range sessions {
+		s.Forget(session.ID)
+	}
+}
+
+// SessionActive is checked on every authenticated request, so answers are
+// cached for CacheTTL. Refreshing an entry also records the session as seen.
+func (s *SessionServiceImpl) SessionActive(ctx context.Context, sessionId string, userId string) bool {
+	s.mutex.Lock()
+	entry, ok := s.cache[sessionId]
+	s.mutex.Unlock()
+
+	if !ok || time.Since(entry.checkedAt) > s.CacheTTL {
+		entry = s.checkSession(ctx, sessionId)
+
+		s.mutex.Lock()
+		s.cache[sessionId] = entry
+		s.sweep(entry.checkedAt)
+		s.mutex.Unlock()
+	}
+
+	return entry.active && entry.userId == userId
+}
+
+// sweep drops expired entries, at most once per CacheTTL, so sessions that
+// are never checked again do not stay in the cache. The mutex must be held.
+func (s *SessionServiceImpl) sweep(now time.Time) {
+	if now.Sub(s.sweptAt) < s.CacheTTL {
+		return
+	}
+
+	for sessionId, entry := range s.cache {
+		if now.Sub(entry.checkedAt) > s.CacheTTL {
+			delete(s.cache, sessionId)
+		}
+	}
+	for userId, entry := range s.accounts {
+		if now.Sub(entry.checkedAt) > s.CacheTTL {
+			delete(s.accounts, userId)
+		}
+	}
+	s.sweptAt = now
+}
+
+func (s *SessionServiceImpl) Forget(sessionId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.cache, sessionId)
+}
+
+// Account tells the role of the user and whether they are disabled. Like
+// sessions it is looked up on every authenticated request, so answers are
+// cached for CacheTTL.
+func (s *SessionServiceImpl) Account(ctx context.Context, userId string) AccountStatus {
+	s.mutex.Lock()
+	entry, ok := s.accounts[userId]
+	s.mutex.Unlock()
+
+	if !ok || time.Since(entry.checkedAt) > s.CacheTTL {
+		entry = accountCacheEntry{checkedAt: time.Now()}
+		if user, err := s.UserRepository.FindUser(ctx, s.DB, userId); err == nil {
+			entry.status = AccountStatus{Role: user.Role, Disabled: user.DisabledAt != nil}
+		}
+
+		s.mutex.Lock()
+		s.accounts[userId] = entry
+		s.sweep(entry.checkedAt)
+		s.mutex.Unlock()
+	}
+
+	return entry.status
+}
+
+// ForgetAccount drops the cached account of the user after it changed, so
+// this replica sees the change on the next request.
+func (s *SessionServiceImpl) ForgetAccount(userId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.accounts, userId)
+}
+
+func (s *SessionServiceImpl) checkSession(ctx context.Context, sessionId string) sessionCacheEntry {
+	entry := sessionCacheEntry{checkedAt: time.Now()}
+
+	session, err := s.SessionRepository.FindSession(ctx, s.DB, sessionId)
+	if err != nil {
+		return entry
+	}
+
+	entry.userId = session.UserID
+	entry.active = session.RevokedAt == nil && session.ExpiresAt.After(time.Now())
+	if entry.active {
+		err = s.SessionRepository.Touch(ctx, s.DB, session.ID, time.Time{})
+		helpers.PanicIfError(err)
+	}
+	return entry
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"zen-test/app/auth"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TwoFactorConfig struct {
+	// Issuer names the account in authenticator apps.
+	Issuer string
+	// Secret seals the TOTP secrets stored in the database.
+	Secret string
+	// ChallengeTTL is how long the second login step may take, and
+	// MaxAttempts how many codes it may try.
+	ChallengeTTL time.Duration
+	MaxAttempts  int
+	// RecoveryCodes is how many recovery codes confirming hands out.
+	RecoveryCodes int
+}
+
+type TwoFactorService interface {
+	Enroll(ctx context.Context, request models.TwoFactorEnroll, userId string) models.TwoFactorEnrollResponse
+	Confirm(ctx context.Context, request models.TwoFactorConfirm, userId string) models.TwoFactorConfirmResponse
+	Disable(ctx context.Context, request models.TwoFactorVerify, userId string)
+	RegenerateRecoveryCodes(ctx context.Context, request models.TwoFactorVerify, userId string) models.TwoFactorConfirmResponse
+	Enabled(ctx context.Context, tx *gorm.DB, userId string) bool
+	Challenge(ctx context.Context, tx *gorm.DB, userId string) string
+	Verify(ctx context.Context, tx *gorm.DB, challengeToken string, code string) (string, bool)
+}
+
+type TwoFactorServiceImpl struct {
+	UserRepository           repositories.UserRepository
+	TwoFactorRepository      repositories.TwoFactorRepository
+	LoginChallengeRepository repositories.LoginChallengeRepository
+	Config                   TwoFactorConfig
+	DB                       *gorm.DB
+	Validate                 *validator.Validate
+}
+
+func NewTwoFactorService(userRepo repositories.UserRepository, twoFactorRepo repositories.TwoFactorRepository, loginChallengeRepo repositories.LoginChallengeRepository, config TwoFactorConfig, db *gorm.DB, validate *validator.Validate) TwoFactorService {
+	return &TwoFactorServiceImpl{
+		UserRepository:           userRepo,
+		TwoFactorRepository:      twoFactorRepo,
+		LoginChallengeRepository: loginChallengeRepo,
+		Config:                   config,
+		DB:                       db,
+		Validate:                 validate,
+	}
+}
+
+// Enroll starts setting up an authenticator with a new secret. It replaces
+// an enrollment that was never confirmed.
+func (s *TwoFactorServiceImpl) Enroll(ctx context.Context, request models.TwoFactorEnroll, userId string) models.TwoFactorEnrollResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user := s.checkPassword(ctx, tx, userId, request.Password)
+
+	if s.Enabled(ctx, tx, user.ID) {
+		panic(exceptions.NewBadRequestError("two-factor authentication is already enabled"))
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	helpers.PanicIfError(err)
+	sealed, err := auth.Seal([]byte(secret), s.Config.Secret)
+	helpers.PanicIfError(err)
+
+	_, err = s.TwoFactorRepository.Save(ctx, tx, models.TwoFactor{
+		UserID: user.ID,
+		Secret: sealed,
+	})
+	helpers.PanicIfError(err)
+
+	return models.TwoFactorEnrollResponse{
+		Secret: secret,
+		URI:    auth.TOTPURI(s.Config.Issuer, user.Email, secret),
+	}
+}
+
+// Confirm turns two-factor authentication on once the user proves their
+// authenticator works, and hands out the recovery codes. They are shown
+// only this once.
+func (s *TwoFactorServiceImpl) Confirm(ctx context.Context, request models.TwoFactorConfirm, userId string) models.TwoFactorConfirmResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	twoFactor, err := s.TwoFactorRepository.FindByUser(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewBadRequestError("start two-factor enrollment first"))
+	}
+	if twoFactor.ConfirmedAt != nil {
+		panic(exceptions.NewBadRequestError("two-factor authentication is already enabled"))
+	}
+
+	step, ok := auth.ValidateTOTP(s.secret(twoFactor), request.Code, time.Now(), 1, twoFactor.LastUsedStep)
+	if !ok {
+		panic(exceptions.NewBadRequestError("invalid code"))
+	}
+
+	now := time.Now()
+	twoFactor.ConfirmedAt = &now
+	twoFactor.LastUsedStep = step
+	_, err = s.TwoFactorRepository.Save(ctx, tx, twoFactor)
+	helpers.PanicIfError(err)
+
+	return models.TwoFactorConfirmResponse{RecoveryCodes: s.replaceRecoveryCodes(ctx, tx, userId)}
+}
+
+// Disable turns two-factor authentication off. It takes the password and a
+// code, so neither a stolen session nor a stolen password is enough.
+func (s *TwoFactorServiceImpl) Disable(ctx context.Context, request models.TwoFactorVerify, userId string) {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	s.checkFactors(ctx, tx, userId, request)
+
+	err = s.TwoFactorRepository.Delete(ctx, tx, userId)
+	helpers.PanicIfError(err)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user, used or
+// not, with new ones. They are shown only this once.
+func (s *TwoFactorServiceImpl) RegenerateRecoveryCodes(ctx context.Context, request models.TwoFactorVerify, userId string) models.TwoFactorConfirmResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	s.checkFactors(ctx, tx, userId, request)
+
+	return models.TwoFactorConfirmResponse{RecoveryCodes: s.replaceRecoveryCodes(ctx, tx, userId)}
+}
+
+func (s *TwoFactorServiceImpl) checkPassword(ctx context.Context, tx *gorm.DB, userId string, password string) models.User {
+	user, err := s.UserRepository.GetUserById(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError(err.Error()))
+	}
+
+	if !helpers.ComparePassword(password, user.Password) {
+		panic(exceptions.NewBadRequestError("password is incorrect"))
+	}
+	return user
+}
+
+func (s *TwoFactorServiceImpl) checkFactors(ctx context.Context, tx *gorm.DB, userId string, request models.TwoFactorVerify) {
+	s.checkPassword(ctx, tx, userId, request.Password)
+
+	if !s.Enabled(ctx, tx, userId) {
+		panic(exceptions.NewBadRequestError("two-factor authentication is not enabled"))
+	}
+	if !s.checkCode(ctx, tx, userId, request.Code) {
+		panic(exceptions.NewBadRequestError("invalid code"))
+	}
+}
+
+// replaceRecoveryCodes stores new recovery codes for the user in place of
+// the old ones and returns them in the clear.
+func (s *TwoFactorServiceImpl) replaceRecoveryCodes(ctx context.Context, tx *gorm.DB, userId string) []string {
+	codes := make([]string, s.Config.RecoveryCodes)
+	recoveryCodes := make([]models.RecoveryCode, s.Config.RecoveryCodes)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		recoveryCodes[i] = models.RecoveryCode{
+			ID:       uuid.New().String(),
+			UserID:   userId,
+			CodeHash: helpers.HashToken(normalizeRecoveryCode(codes[i])),
+		}
+	}
+
+	err := s.TwoFactorRepository.ReplaceRecoveryCodes(ctx, tx, userId, recoveryCodes)
+	helpers.PanicIfError(err)
+
+	return codes
+}
+
+func (s *TwoFactorServiceImpl) Enabled(ctx context.Context, tx *gorm.DB, userId string) bool {
+	twoFactor, err := s.TwoFactorRepository.FindByUser(ctx, tx, userId)
+	return err == nil && twoFactor.ConfirmedAt != nil
+}
+
+// Challenge returns the token the second login step of the user is made
+// with.
+func (s *TwoFactorServiceImpl) Challenge(ctx context.Context, tx *gorm.DB, userId string) string {
+	token := helpers.RandomToken(32)
+
+	_, err := s.LoginChallengeRepository.Create(ctx, tx, models.LoginChallenge{
+		ID:        uuid.New().String(),
+		UserID:    userId,
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: time.Now().Add(s.Config.ChallengeTTL),
+	})
+	helpers.PanicIfError(err)
+
+	return token
+}
+
+// Verify checks a TOTP code or a recovery code against a login challenge.
+// It returns the user of the challenge, empty when the challenge is unknown,
+// and whether the login may go ahead. A challenge takes MaxAttempts codes at
+// most and completes one login.
+func (s *TwoFactorServiceImpl) Verify(ctx context.Context, tx *gorm.DB, challengeToken string, code string) (string, bool) {
+	challenge, err := s.LoginChallengeRepository.FindByHash(ctx, tx, helpers.HashToken(challengeToken))
+	if err != nil {
+		return "", false
+	}
+	if challenge.UsedAt != nil || challenge.ExpiresAt.Before(time.Now()) {
+		return challenge.UserID, false
+	}
+
+	counted, err := s.LoginChallengeRepository.AddAttempt(ctx, tx, challenge.ID, s.Config.MaxAttempts)
+	helpers.PanicIfError(err)
+	if !counted || !s.checkCode(ctx, tx, challenge.UserID, code) {
+		return challenge.UserID, false
+	}
+
+	used, err := s.LoginChallengeRepository.Use(ctx, tx, challenge.ID)
+	helpers.PanicIfError(err)
+	return challenge.UserID, used
+}
+
+func (s *TwoFactorServiceImpl) checkCode(ctx context.Context, tx *gorm.DB, userId string, code string) bool {
+	twoFactor, err := s.TwoFactorRepository.FindByUser(ctx, tx, userId)
+	if err != nil || twoFactor.ConfirmedAt == nil {
+		return false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == auth.TOTPDigits {
+		step, ok := auth.ValidateTOTP(s.secret(twoFactor), code, time.Now(), 1, twoFactor.LastUsedStep)
+		if !ok {
+			return false
+		}
+
+		used, err := s.TwoFactorRepository.UseStep(ctx, tx, userId, step)
+		helpers.PanicIfError(err)
+		return used
+	}
+
+	used, err := s.TwoFactorRepository.UseRecoveryCode(ctx, tx, userId, helpers.HashToken(normalizeRecoveryCode(code)))
+	helpers.PanicIfError(err)
+	return used
+}
+
+func (s *TwoFactorServiceImpl) secret(twoFactor models.TwoFactor) string {
+	secret, err := auth.Open(twoFactor.Secret, s.Config.Secret)
+	helpers.PanicIfError(err)
+	return string(secret)
+}
+
+// newRecoveryCode returns 50 random bits as two groups of five base32
+// characters.
+func newRecoveryCode() string {
+	random := make([]byte, 7)
+	_, err := rand.Read(random)
+	helpers.PanicIfError(err)
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(random))
+	return code[:5] + "-" + code[5:10]
+}
+
+// normalizeRecoveryCode lets a recovery code be typed in any case, with or
+// without its dash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"zen-test/app/auth"
+	"zen-test/app/consts"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUserPageSize = 20
+)
+
+// UserAdminService lets staff look up and manage the accounts of others.
+type UserAdminService interface {
+	Search(ctx context.Context, search models.UserSearch) models.UserPage
+	FindById(ctx context.Context, userId string) models.UserDetailResponse
+	Disable(ctx context.Context, userId string, callerId string) models.UserResponse
+	Enable(ctx context.Context, userId string) models.UserResponse
+	ChangeRole(ctx context.Context, request models.UserRoleChange, userId string, callerId string) models.UserResponse
+	Logout(ctx context.Context, userId string)
+}
+
+type UserAdminServiceImpl struct {
+	UserRepository  repositories.UserRepository
+	OrderRepository repositories.OrderRepository
+	Sessions        SessionService
+	DB              *gorm.DB
+	Validate        *validator.Validate
+}
+
+func NewUserAdminService(userRepo repositories.UserRepository, orderRepo repositories.OrderRepository, sessions SessionService, db *gorm.DB, validate *validator.Validate) UserAdminService {
+	return &UserAdminServiceImpl{
+		UserRepository:  userRepo,
+		OrderRepository: orderRepo,
+		Sessions:        sessions,
+		DB:              db,
+		Validate:        validate,
+	}
+}
+
+// Search pages through the users, twenty at a time unless asked otherwise.
+func (s *UserAdminServiceImpl) Search(ctx context.Context, search models.UserSearch) models.UserPage {
+	ensureScope(ctx, consts.ScopeUsersRead)
+
+	if search.Page == 0 {
+		search.Page = 1
+	}
+	if search.Size == 0 {
+		search.Size = defaultUserPageSize
+	}
+
+	err := s.Validate.Struct(search)
+	helpers.PanicIfError(err)
+
+	users, total, err := s.UserRepository.SearchUsers(ctx, s.DB, search)
+	helpers.PanicIfError(err)
+
+	return models.UserPage{
+		Users: models.ToUserResponses(users),
+		Page:  search.Page,
+		Size:  search.Size,
+		Total: total,
+	}
+}
+
+func (s *UserAdminServiceImpl) FindById(ctx context.Context, userId string) models.UserDetailResponse {
+	ensureScope(ctx, consts.ScopeUsersRead)
+
+	user := s.findUser(ctx, s.DB, userId)
+
+	orders, err := s.OrderRepository.FindAllOrder(ctx, s.DB, user.ID)
+	helpers.PanicIfError(err)
+
+	return models.UserDetailResponse{
+		User:   models.ToUserReponse(user),
+		Orders: models.ToOrderResponses(orders),
+	}
+}
+
+// Disable stops the user from logging in and signs them out everywhere. Their
+// tokens and API keys are refused while the account stays disabled.
+func (s *UserAdminServiceImpl) Disable(ctx context.Context, userId string, callerId string) models.UserResponse {
+	ensureScope(ctx, consts.ScopeUsersWrite)
+
+	if userId == callerId {
+		panic(exceptions.NewBadRequestError("you cannot disable your own account"))
+	}
+
+	tx := s.DB.Begin()
+	// Forgotten once the change is committed, so a request reading the
+	// account meanwhile cannot cache the old status again.
+	defer s.Sessions.ForgetAccount(userId)
+	defer helpers.CommitOrRollback(tx)
+
+	user := s.findUser(ctx, tx, userId)
+	ensureManageable(ctx, user)
+
+	if user.DisabledAt == nil {
+		now := time.Now()
+		user.DisabledAt = &now
+
+		err := s.UserRepository.SetDisabled(ctx, tx, user.ID, user.DisabledAt)
+		helpers.PanicIfError(err)
+	}
+
+	s.Sessions.RevokeAll(ctx, tx, user.ID)
+	return models.ToUserReponse(user)
+}
+
+func (s *UserAdminServiceImpl) Enable(ctx context.Context, userId string) models.UserResponse {
+	ensureScope(ctx, consts.ScopeUsersWrite)
+
+	tx := s.DB.Begin()
+	defer s.Sessions.ForgetAccount(userId)
+	defer helpers.CommitOrRollback(tx)
+
+	user := s.findUser(ctx, tx, userId)
+	ensureManageable(ctx, user)
+
+	if user.AnonymizedAt != nil {
+		panic(exceptions.NewBadRequestError("the account has been deleted"))
+	}
+
+	user.DisabledAt = nil
+	err := s.UserRepository.SetDisabled(ctx, tx, user.ID, nil)
+	helpers.PanicIfError(err)
+
+	return models.ToUserReponse(user)
+}
+
+// ChangeRole gives the user another role. Callers cannot change their own, so
+// the last admin cannot lock everyone out by accident.
+func (s *UserAdminServiceImpl) ChangeRole(ctx context.Context, request models.UserRoleChange, userId string, callerId string) models.UserResponse {
+	ensureScope(ctx, consts.ScopeUsersRoles)
+
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	if userId == callerId {
+		panic(exceptions.NewBadRequestError("you cannot change your own role"))
+	}
+
+	tx := s.DB.Begin()
+	defer s.Sessions.ForgetAccount(userId)
+	defer helpers.CommitOrRollback(tx)
+
+	user := s.findUser(ctx, tx, userId)
+	user.Role = request.Role
+
+	user, err = s.UserRepository.UpdateUser(ctx, tx, user)
+	helpers.PanicIfError(err)
+
+	return models.ToUserReponse(user)
+}
+
+// Logout signs the user out of every session without disabling them.
+func (s *UserAdminServiceImpl) Logout(ctx context.Context, userId string) {
+	ensureScope(ctx, consts.ScopeUsersWrite)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user := s.findUser(ctx, tx, userId)
+	ensureManageable(ctx, user)
+
+	s.Sessions.RevokeAll(ctx, tx, user.ID)
+}
+
+func (s *UserAdminServiceImpl) findUser(ctx context.Context, tx *gorm.DB, userId string) models.User {
+	user, err := s.UserRepository.FindUser(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError("user not found"))
+	}
+	return user
+}
+
+// ensureManageable keeps staff from disabling, enabling or signing out
+// accounts with more than customer rights; that takes the scope to change
+// roles.
+func ensureManageable(ctx context.Context, user models.User) {
+	if user.Role != consts.UserRoleCustomer && !auth.HasScope(ctx, consts.ScopeUsersRoles) {
+		panic(exceptions.NewForbiddenError("only admins can manage staff accounts"))
+	}
+}
@@ -2,10 +2,15 @@ package services
 
 import (
 	"context"
+	"log"
+	"strings"
+	"time"
 
 	"zen-test/app/auth"
+	"zen-test/app/consts"
 	"zen-test/app/exceptions"
 	"zen-test/app/helpers"
+	"zen-test/app/notifications"
 	"zen-test/app/web/models"
 	"zen-test/app/web/repositories"
 
@@ -15,22 +20,59 @@ import (
 )
 
 type UserServiceimpl struct {
-	UserRepo repositories.UserRepository
-	DB       *gorm.DB
-	Validate *validator.Validate
+	UserRepo         repositories.UserRepository
+	OutboxRepo       repositories.OutboxRepository
+	RefreshTokenRepo repositories.RefreshTokenRepository
+	SessionRepo      repositories.SessionRepository
+	LoginAttemptRepo repositories.LoginAttemptRepository
+	Sessions         SessionService
+	Verifications    EmailVerificationService
+	TwoFactor        TwoFactorService
+	Tokens           auth.TokenIssuer
+	AuthConfig       AuthConfig
+	DB               *gorm.DB
+	Validate         *validator.Validate
 }
 
 type UserService interface {
 	Register(ctx context.Context, request models.UserCreate) models.UserResponse
-	Update(ctx context.Context, request models.UserUpdate, userId string) models.UserResponse
-	Login(ctx context.Context, requestLogin models.UserLogin) (models.UserLoginResponse, bool)
+	Update(ctx context.Context, request models.UserUpdate, userId string, callerId string) models.UserResponse
+	FindMe(ctx context.Context, userId string) models.UserResponse
+	Patch(ctx context.Context, request models.UserPatch, userId string) models.UserResponse
+	ChangePassword(ctx context.Context, request models.PasswordChange, userId string, sessionId string)
+	ChangeEmail(ctx context.Context, request models.EmailChange, userId string)
+	Login(ctx context.Context, requestLogin models.UserLogin, client models.ClientInfo) models.UserLoginResponse
+	LoginTwoFactor(ctx context.Context, request models.TwoFactorLogin, client models.ClientInfo) models.UserLoginResponse
+	CompleteLogin(ctx context.Context, tx *gorm.DB, user models.User, client models.ClientInfo, method string) models.UserLoginResponse
+	Refresh(ctx context.Context, refreshToken string) models.TokenResponse
+	Logout(ctx context.Context, sessionId string, refreshToken string, userId string)
+	UserScopes(ctx context.Context, userId string) []string
+	AccountActive(ctx context.Context, userId string) bool
 }
 
-func NewUserService(userRepo repositories.UserRepository, db *gorm.DB, validate *validator.Validate) UserService {
+// AuthConfig controls logging in and the tokens handed out at login.
+type AuthConfig struct {
+	RefreshTokenTTL time.Duration
+	Throttle        LoginThrottleConfig
+}
+
+// unknownUserPassword is compared against when the email has no account.
+const unknownUserPassword = "$2a$10$uK3msRfaHC.MobOM2QEZtuNcNBRE6lBxbQutnQThmoRfpjHO5gY6G"
+
+func NewUserService(userRepo repositories.UserRepository, outboxRepo repositories.OutboxRepository, refreshTokenRepo repositories.RefreshTokenRepository, sessionRepo repositories.SessionRepository, loginAttemptRepo repositories.LoginAttemptRepository, sessions SessionService, verifications EmailVerificationService, twoFactor TwoFactorService, tokens auth.TokenIssuer, authConfig AuthConfig, db *gorm.DB, validate *validator.Validate) UserService {
 	return &UserServiceimpl{
-		UserRepo: userRepo,
-		DB:       db,
-		Validate: validate,
+		UserRepo:         userRepo,
+		OutboxRepo:       outboxRepo,
+		RefreshTokenRepo: refreshTokenRepo,
+		SessionRepo:      sessionRepo,
+		LoginAttemptRepo: loginAttemptRepo,
+		Sessions:         sessions,
+		Verifications:    verifications,
+		TwoFactor:        twoFactor,
+		Tokens:           tokens,
+		AuthConfig:       authConfig,
+		DB:               db,
+		Validate:         validate,
 	}
 }
 
@@ -43,6 +85,11 @@ func (s *UserServiceimpl) Register(ctx context.Context, request models.UserCreat
 
 	hashPassword, _ := helpers.MakePassword(request.Password)
 
+	locale := request.Locale
+	if locale == "" {
+		locale = notifications.DefaultLocale
+	}
+
 	user := models.User{
 		ID:       uuid.New().String(),
 		Name:     request.Name,
@@ -50,18 +97,30 @@ func (s *UserServiceimpl) Register(ctx context.Context, request models.UserCreat
 		Password: hashPassword,
 		Phone:    request.Phone,
 		Address:  request.Address,
+		Locale:   locale,
 	}
 
 	data, err := s.UserRepo.RegisterUser(ctx, tx, user)
 	helpers.PanicIfError(err)
 
-	return models.ToUserReponse(data)
+	s.Verifications.Issue(ctx, tx, data)
+
+	userResponse := models.ToUserReponse(data)
+	recordEvent(ctx, tx, s.OutboxRepo, consts.EventUserRegistered, aggregateUser, data.ID, data.ID, userResponse)
+
+	return userResponse
 }
 
-func (s *UserServiceimpl) Update(ctx context.Context, request models.UserUpdate, userId string) models.UserResponse {
+// Update replaces the contact details of the user of the path, which has to
+// be the caller.
+func (s *UserServiceimpl) Update(ctx context.Context, request models.UserUpdate, userId string, callerId string) models.UserResponse {
 	err := s.Validate.Struct(request)
 	helpers.PanicIfError(err)
 
+	if userId != callerId {
+		panic(exceptions.NewForbiddenError("you can only update your own account"))
+	}
+
 	tx := s.DB.Begin()
 	defer helpers.CommitOrRollback(tx)
 
@@ -70,10 +129,7 @@ func (s *UserServiceimpl) Update(ctx context.Context, request models.UserUpdate,
 		panic(exceptions.NewNotFoundError(err.Error()))
 	}
 
-	hashPassword, _ := helpers.MakePassword(request.Password)
-
-	userExist.Password = hashPassword
-	userExist.Phone = request.Password
+	userExist.Phone = request.Phone
 	userExist.Address = request.Address
 
 	data, err := s.UserRepo.UpdateUser(ctx, tx, userExist)
@@ -82,32 +138,427 @@ func (s *UserServiceimpl) Update(ctx context.Context, request models.UserUpdate,
 	return models.ToUserReponse(data)
 }
 
-func (s *UserServiceimpl) Login(ctx context.Context, requestLogin models.UserLogin) (models.UserLoginResponse, bool) {
-	err := s.Validate.Struct(requestLogin)
+func (s *UserServiceimpl) FindMe(ctx context.Context, userId string) models.UserResponse {
+	user, err := s.UserRepo.GetUserById(ctx, s.DB, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError(err.Error()))
+	}
+
+	return models.ToUserReponse(user)
+}
+
+func (s *UserServiceimpl) Patch(ctx context.Context, request models.UserPatch, userId string) models.UserResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user, err := s.UserRepo.GetUserById(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError(err.Error()))
+	}
+
+	if request.Name != nil {
+		user.Name = *request.Name
+	}
+	if request.Phone != nil {
+		user.Phone = *request.Phone
+	}
+	if request.Address != nil {
+		user.Address = *request.Address
+	}
+	if request.Locale != nil {
+		user.Locale = *request.Locale
+	}
+
+	user, err = s.UserRepo.UpdateUser(ctx, tx, user)
+	helpers.PanicIfError(err)
+
+	return models.ToUserReponse(user)
+}
+
+// ChangePassword sets a new password once the current one is confirmed, and
+// signs the user out of every other session.
+func (s *UserServiceimpl) ChangePassword(ctx context.Context, request models.PasswordChange, userId string, sessionId string) {
+	err := s.Validate.Struct(request)
 	helpers.PanicIfError(err)
 
 	tx := s.DB.Begin()
 	defer helpers.CommitOrRollback(tx)
 
-	user, err := s.UserRepo.GetUserByEmail(ctx, tx, requestLogin.Email)
+	user, err := s.UserRepo.GetUserById(ctx, tx, userId)
 	if err != nil {
 		panic(exceptions.NewNotFoundError(err.Error()))
 	}
-	passwordSync := helpers.ComparePassword(requestLogin.Password, user.Password)
-	accessToken, _ := auth.CreateToken(user.ID)
-	refreshToken, _ := auth.CreateRefreshToken(user.ID)
 
-	if !passwordSync {
+	if !helpers.ComparePassword(request.CurrentPassword, user.Password) {
+		panic(exceptions.NewBadRequestError("current password is incorrect"))
+	}
+
+	user.Password, err = helpers.MakePassword(request.Password)
+	helpers.PanicIfError(err)
+
+	_, err = s.UserRepo.UpdateUser(ctx, tx, user)
+	helpers.PanicIfError(err)
+
+	sessions, err := s.SessionRepo.FindActiveSessions(ctx, tx, user.ID)
+	helpers.PanicIfError(err)
+
+	for _, session := range sessions {
+		if session.ID == sessionId {
+			continue
+		}
+
+		err = s.SessionRepo.Revoke(ctx, tx, session.ID)
+		helpers.PanicIfError(err)
+
+		err = s.RefreshTokenRepo.RevokeFamily(ctx, tx, session.ID)
+		helpers.PanicIfError(err)
+
+		s.Sessions.Forget(session.ID)
+	}
+}
+
+// ChangeEmail mails a verification link to the new address once the password
+// is confirmed. The account keeps its current address until the link is used.
+func (s *UserServiceimpl) ChangeEmail(ctx context.Context, request models.EmailChange, userId string) {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	user, err := s.UserRepo.GetUserById(ctx, tx, userId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError(err.Error()))
+	}
+
+	if !helpers.ComparePassword(request.Password, user.Password) {
+		panic(exceptions.NewBadRequestError("password is incorrect"))
+	}
+
+	if strings.EqualFold(request.Email, user.Email) {
+		panic(exceptions.NewBadRequestError("this is already your email address"))
+	}
+
+	if _, err := s.UserRepo.FindUserByEmail(ctx, tx, request.Email); err == nil {
+		panic(exceptions.NewBadRequestError("email is already in use"))
+	}
+
+	s.Verifications.IssueEmailChange(ctx, tx, user, request.Email)
+}
+
+// Login answers an unknown email and a wrong password alike, and refuses to
+// check passwords for an email or IP with too many recent failures.
+func (s *UserServiceimpl) Login(ctx context.Context, requestLogin models.UserLogin, client models.ClientInfo) models.UserLoginResponse {
+	err := s.Validate.Struct(requestLogin)
+	helpers.PanicIfError(err)
+
+	userLoginResponse, wait, loggedIn := s.attemptLogin(ctx, requestLogin, client)
+	if wait > 0 {
+		panic(exceptions.NewTooManyRequestsError("too many failed login attempts, try again later", wait))
+	}
+	if !loggedIn {
+		panic(exceptions.NewUnauthorizedError("invalid email or password"))
+	}
+
+	return userLoginResponse
+}
+
+// attemptLogin reports a refused login instead of panicking so the attempt
+// is recorded.
+func (s *UserServiceimpl) attemptLogin(ctx context.Context, requestLogin models.UserLogin, client models.ClientInfo) (models.UserLoginResponse, time.Duration, bool) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	now := time.Now()
+	attempt := models.LoginAttempt{
+		ID:     uuid.New().String(),
+		Email:  loginEmail(requestLogin.Email),
+		IP:     client.IP,
+		Device: truncate(client.Device, 255),
+	}
+
+	since := now.Add(-s.AuthConfig.Throttle.Window)
+	lastSuccess, err := s.LoginAttemptRepo.LastSuccess(ctx, tx, attempt.Email)
+	helpers.PanicIfError(err)
+	if lastSuccess.After(since) {
+		since = lastSuccess
+	}
+
+	emailFailures, err := s.LoginAttemptRepo.EmailFailures(ctx, tx, attempt.Email, since)
+	helpers.PanicIfError(err)
+	ipFailures, err := s.LoginAttemptRepo.IPFailures(ctx, tx, attempt.IP, now.Add(-s.AuthConfig.Throttle.Window))
+	helpers.PanicIfError(err)
+
+	wait, reason := s.AuthConfig.Throttle.wait(emailFailures, ipFailures, now)
+	if wait > 0 {
+		attempt.Result = models.LoginBlocked
+		attempt.Reason = reason
+		s.recordLoginAttempt(ctx, tx, attempt)
+
+		// The email and address stay in the attempt row, out of the logs.
+		log.Printf("Login attempt %s refused: %s for %s", attempt.ID, reason, wait.Round(time.Second))
+		return models.UserLoginResponse{}, wait, false
+	}
+
+	user, err := s.UserRepo.FindUserByEmail(ctx, tx, requestLogin.Email)
+	if err != nil {
+		// Hash anyway so an unknown email takes as long as a wrong password.
+		helpers.ComparePassword(requestLogin.Password, unknownUserPassword)
+
+		attempt.Result = models.LoginFailed
+		attempt.Reason = "unknown_email"
+		s.recordLoginAttempt(ctx, tx, attempt)
+		return models.UserLoginResponse{}, 0, false
+	}
+
+	attempt.UserID = user.ID
+	if !helpers.ComparePassword(requestLogin.Password, user.Password) {
+		attempt.Result = models.LoginFailed
+		attempt.Reason = "wrong_password"
+		s.recordLoginAttempt(ctx, tx, attempt)
+		return models.UserLoginResponse{}, 0, false
+	}
+
+	return s.CompleteLogin(ctx, tx, user, client, "password"), 0, true
+}
+
+// CompleteLogin logs in a user who proved who they are with method, within
+// tx. Users with two-factor authentication get a challenge token instead of
+// the tokens, and disabled users are refused.
+func (s *UserServiceimpl) CompleteLogin(ctx context.Context, tx *gorm.DB, user models.User, client models.ClientInfo, method string) models.UserLoginResponse {
+	if user.DisabledAt != nil {
+		panic(exceptions.NewForbiddenError("account is disabled"))
+	}
+
+	attempt := models.LoginAttempt{
+		ID:     uuid.New().String(),
+		UserID: user.ID,
+		Email:  loginEmail(user.Email),
+		IP:     client.IP,
+		Device: truncate(client.Device, 255),
+		Reason: method,
+	}
+
+	if s.TwoFactor.Enabled(ctx, tx, user.ID) {
+		attempt.Result = models.LoginChallenged
+		s.recordLoginAttempt(ctx, tx, attempt)
+
+		return models.UserLoginResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    s.TwoFactor.Challenge(ctx, tx, user.ID),
+		}
+	}
+
+	attempt.Result = models.LoginSucceeded
+	s.recordLoginAttempt(ctx, tx, attempt)
+
+	return s.startSession(ctx, tx, user, client)
+}
+
+// LoginTwoFactor completes the login of a user with two-factor
+// authentication, exchanging the challenge token of Login and a TOTP or
+// recovery code for the tokens.
+func (s *UserServiceimpl) LoginTwoFactor(ctx context.Context, request models.TwoFactorLogin, client models.ClientInfo) models.UserLoginResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	userLoginResponse, loggedIn := s.attemptTwoFactorLogin(ctx, request, client)
+	if !loggedIn {
+		panic(exceptions.NewUnauthorizedError("invalid or expired code"))
+	}
+
+	return userLoginResponse
+}
+
+// attemptTwoFactorLogin reports a wrong code instead of panicking so the
+// attempt is recorded. Wrong codes count as failed logins of the email.
+func (s *UserServiceimpl) attemptTwoFactorLogin(ctx context.Context, request models.TwoFactorLogin, client models.ClientInfo) (models.UserLoginResponse, bool) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	userId, verified := s.TwoFactor.Verify(ctx, tx, request.ChallengeToken, request.Code)
+	if userId == "" {
+		return models.UserLoginResponse{}, false
+	}
+
+	user, err := s.UserRepo.GetUserById(ctx, tx, userId)
+	helpers.PanicIfError(err)
+
+	attempt := models.LoginAttempt{
+		ID:     uuid.New().String(),
+		UserID: user.ID,
+		Email:  loginEmail(user.Email),
+		IP:     client.IP,
+		Device: truncate(client.Device, 255),
+	}
+	if !verified {
+		attempt.Result = models.LoginFailed
+		attempt.Reason = "wrong_code"
+		s.recordLoginAttempt(ctx, tx, attempt)
 		return models.UserLoginResponse{}, false
-	} else {
-
-		userLoginResponse := models.UserLoginResponse{
-			ID:           user.ID,
-			Name:         user.Name,
-			Email:        user.Email,
-			Token:        accessToken,
-			RefreshToken: refreshToken,
+	}
+
+	attempt.Result = models.LoginSucceeded
+	attempt.Reason = "two_factor"
+	s.recordLoginAttempt(ctx, tx, attempt)
+
+	return s.startSession(ctx, tx, user, client), true
+}
+
+// startSession opens a session for a user who has logged in and issues its
+// tokens.
+func (s *UserServiceimpl) startSession(ctx context.Context, tx *gorm.DB, user models.User, client models.ClientInfo) models.UserLoginResponse {
+	now := time.Now()
+	session, err := s.SessionRepo.Create(ctx, tx, models.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		Device:     truncate(client.Device, 255),
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.AuthConfig.RefreshTokenTTL),
+	})
+	helpers.PanicIfError(err)
+
+	accessToken, err := s.Tokens.Issue(user.ID, session.ID)
+	helpers.PanicIfError(err)
+	refreshToken := s.issueRefreshToken(ctx, tx, uuid.New().String(), user.ID, session.ID)
+
+	return models.UserLoginResponse{
+		ID:           user.ID,
+		Name:         user.Name,
+		Email:        user.Email,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	}
+}
+
+func (s *UserServiceimpl) recordLoginAttempt(ctx context.Context, tx *gorm.DB, attempt models.LoginAttempt) {
+	_, err := s.LoginAttemptRepo.Create(ctx, tx, attempt)
+	helpers.PanicIfError(err)
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token. A token is good for one refresh only; presenting it again means it
+// was copied, so every token descended from the same login is revoked.
+func (s *UserServiceimpl) Refresh(ctx context.Context, refreshToken string) models.TokenResponse {
+	tokenResponse, reused := s.rotateRefreshToken(ctx, refreshToken)
+	if reused {
+		panic(exceptions.NewUnauthorizedError("invalid refresh token"))
+	}
+
+	return tokenResponse
+}
+
+// rotateRefreshToken reports reuse instead of panicking so the revocation of
+// the family is committed.
+func (s *UserServiceimpl) rotateRefreshToken(ctx context.Context, refreshToken string) (models.TokenResponse, bool) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	token, err := s.RefreshTokenRepo.FindByHash(ctx, tx, helpers.HashToken(refreshToken))
+	if err != nil || token.ExpiresAt.Before(time.Now()) {
+		panic(exceptions.NewUnauthorizedError("invalid refresh token"))
+	}
+
+	newTokenId := uuid.New().String()
+	rotated := false
+	if token.RevokedAt == nil {
+		rotated, err = s.RefreshTokenRepo.Rotate(ctx, tx, token.ID, newTokenId)
+		helpers.PanicIfError(err)
+	}
+
+	if !rotated {
+		err = s.RefreshTokenRepo.RevokeFamily(ctx, tx, token.FamilyID)
+		helpers.PanicIfError(err)
+
+		err = s.SessionRepo.Revoke(ctx, tx, token.FamilyID)
+		helpers.PanicIfError(err)
+		s.Sessions.Forget(token.FamilyID)
+
+		log.Printf("Refresh token reuse detected for user %s, revoked token family %s", token.UserID, token.FamilyID)
+		return models.TokenResponse{}, true
+	}
+
+	err = s.SessionRepo.Touch(ctx, tx, token.FamilyID, time.Now().Add(s.AuthConfig.RefreshTokenTTL))
+	helpers.PanicIfError(err)
+
+	accessToken, err := s.Tokens.Issue(token.UserID, token.FamilyID)
+	helpers.PanicIfError(err)
+
+	return models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: s.issueRefreshToken(ctx, tx, newTokenId, token.UserID, token.FamilyID),
+	}, false
+}
+
+// Logout ends the session of the access token, or of the refresh token when
+// the access token has none: every refresh token rotated from the same login
+// and every access token of the session stop working.
+func (s *UserServiceimpl) Logout(ctx context.Context, sessionId string, refreshToken string, userId string) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	if sessionId == "" && refreshToken != "" {
+		token, err := s.RefreshTokenRepo.FindByHash(ctx, tx, helpers.HashToken(refreshToken))
+		if err == nil && token.UserID == userId {
+			sessionId = token.FamilyID
 		}
-		return userLoginResponse, true
 	}
+	if sessionId == "" {
+		return
+	}
+
+	err := s.RefreshTokenRepo.RevokeFamily(ctx, tx, sessionId)
+	helpers.PanicIfError(err)
+
+	err = s.SessionRepo.Revoke(ctx, tx, sessionId)
+	helpers.PanicIfError(err)
+
+	s.Sessions.Forget(sessionId)
+}
+
+func (s *UserServiceimpl) issueRefreshToken(ctx context.Context, tx *gorm.DB, tokenId string, userId string, familyId string) string {
+	refreshToken := helpers.RandomToken(32)
+
+	_, err := s.RefreshTokenRepo.Create(ctx, tx, models.RefreshToken{
+		ID:        tokenId,
+		UserID:    userId,
+		FamilyID:  familyId,
+		TokenHash: helpers.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.AuthConfig.RefreshTokenTTL),
+	})
+	helpers.PanicIfError(err)
+
+	return refreshToken
+}
+
+// loginEmail is how login attempts of an email are recorded and counted.
+func loginEmail(email string) string {
+	return truncate(strings.ToLower(strings.TrimSpace(email)), 255)
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+// UserScopes returns the scopes granted by the role of the user, none while
+// the account is disabled.
+func (s *UserServiceimpl) UserScopes(ctx context.Context, userId string) []string {
+	account := s.Sessions.Account(ctx, userId)
+	if account.Disabled {
+		return nil
+	}
+	return auth.RoleScopes[account.Role]
+}
+
+// AccountActive tells whether the user has not been disabled. Tokens of users
+// that are gone are left to the session check.
+func (s *UserServiceimpl) AccountActive(ctx context.Context, userId string) bool {
+	return !s.Sessions.Account(ctx, userId).Disabled
 }
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+type WebhookConfig struct {
+	// Timeout bounds a whole delivery, connecting included.
+	Timeout time.Duration
+	// AllowPrivateTargets lets deliveries go over plain http and to loopback
+	// and private addresses. It is for local receivers in development and
+	// tests only.
+	AllowPrivateTargets bool
+}
+
+var errWebhookTarget = errors.New("webhook url must point at a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which net.IP does not
+// count as private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// newWebhookClient returns the client deliveries are posted with. Unless
+// private targets are allowed it refuses to connect to anything but public
+// addresses. The check runs on the address being dialled, after DNS, so a
+// name cannot be pointed at an internal host between validation and delivery.
+// Redirects are never followed: a receiver answering with one has failed.
+func newWebhookClient(config WebhookConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateTargets {
+		dialer.Control = func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", errWebhookTarget, host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: config.Timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkWebhookURL rejects subscription URLs that are not https, or that name
+// a non-public address outright. Host names are checked again when dialled.
+func checkWebhookURL(rawURL string, config WebhookConfig) error {
+	if config.AllowPrivateTargets {
+		return nil
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if target.Scheme != "https" {
+		return errors.New("webhook url must use https")
+	}
+	if ip := net.ParseIP(target.Hostname()); ip != nil && !isPublicIP(ip) {
+		return errWebhookTarget
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/exceptions"
+	"zen-test/app/helpers"
+	"zen-test/app/jobs"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookSignatureHeader = "X-Zenstore-Signature"
+	WebhookEventHeader     = "X-Zenstore-Event"
+	WebhookDeliveryHeader  = "X-Zenstore-Delivery"
+
+	// webhookResponseLimit caps how much of a receiver's response is kept
+	// in the delivery log.
+	webhookResponseLimit = 1024
+)
+
+type WebhookService interface {
+	Create(ctx context.Context, request models.WebhookSubscriptionCreate, userId string) models.WebhookSubscriptionResponse
+	Update(ctx context.Context, subscriptionId string, request models.WebhookSubscriptionUpdate, userId string) models.WebhookSubscriptionResponse
+	Delete(ctx context.Context, subscriptionId string, userId string)
+	FindAll(ctx context.Context, userId string) []models.WebhookSubscriptionResponse
+	FindById(ctx context.Context, subscriptionId string, userId string) models.WebhookSubscriptionResponse
+	FindDeliveries(ctx context.Context, subscriptionId string, userId string) []models.WebhookDelivery
+	Redeliver(ctx context.Context, deliveryId string, userId string) models.WebhookDelivery
+	Dispatch(ctx context.Context, event events.Event) error
+	Deliver(ctx context.Context, deliveryId string) error
+}
+
+type WebhookServiceImpl struct {
+	WebhookRepository repositories.WebhookRepository
+	UserRepository    repositories.UserRepository
+	JobQueue          *jobs.Queue
+	Config            WebhookConfig
+	Client            *http.Client
+	DB                *gorm.DB
+	Validate          *validator.Validate
+}
+
+func NewWebhookService(webhookRepo repositories.WebhookRepository, userRepo repositories.UserRepository, jobQueue *jobs.Queue, config WebhookConfig, db *gorm.DB, validate *validator.Validate) WebhookService {
+	return &WebhookServiceImpl{
+		WebhookRepository: webhookRepo,
+		UserRepository:    userRepo,
+		JobQueue:          jobQueue,
+		Config:            config,
+		Client:            newWebhookClient(config),
+		DB:                db,
+		Validate:          validate,
+	}
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers recompute it with their secret and compare it to the v1 value of
+// the signature header.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookServiceImpl) Create(ctx context.Context, request models.WebhookSubscriptionCreate, userId string) models.WebhookSubscriptionResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeWebhooksWrite)
+	s.ensureTarget(request.URL)
+
+	secret := request.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+
+	subscription, err := s.WebhookRepository.CreateSubscription(ctx, tx, models.WebhookSubscription{
+		ID:         uuid.New().String(),
+		UserID:     userId,
+		URL:        request.URL,
+		Secret:     secret,
+		EventTypes: strings.Join(request.EventTypes, ","),
+		Active:     true,
+	})
+	helpers.PanicIfError(err)
+
+	// The secret is only ever returned once, when the subscription is made.
+	response := models.ToWebhookSubscriptionResponse(subscription)
+	response.Secret = subscription.Secret
+	return response
+}
+
+func (s *WebhookServiceImpl) Update(ctx context.Context, subscriptionId string, request models.WebhookSubscriptionUpdate, userId string) models.WebhookSubscriptionResponse {
+	err := s.Validate.Struct(request)
+	helpers.PanicIfError(err)
+
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeWebhooksWrite)
+	s.ensureTarget(request.URL)
+	subscription := s.findSubscription(ctx, tx, subscriptionId)
+
+	subscription.URL = request.URL
+	subscription.EventTypes = strings.Join(request.EventTypes, ",")
+	subscription.Active = request.Active
+
+	_, err = s.WebhookRepository.UpdateSubscription(ctx, tx, subscription)
+	helpers.PanicIfError(err)
+
+	return models.ToWebhookSubscriptionResponse(subscription)
+}
+
+func (s *WebhookServiceImpl) Delete(ctx context.Context, subscriptionId string, userId string) {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeWebhooksWrite)
+	subscription := s.findSubscription(ctx, tx, subscriptionId)
+
+	err := s.WebhookRepository.DeleteSubscription(ctx, tx, subscription.ID)
+	helpers.PanicIfError(err)
+}
+
+func (s *WebhookServiceImpl) FindAll(ctx context.Context, userId string) []models.WebhookSubscriptionResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeWebhooksRead)
+
+	subscriptions, err := s.WebhookRepository.FindSubscriptions(ctx, tx)
+	helpers.PanicIfError(err)
+
+	return models.ToWebhookSubscriptionResponses(subscriptions)
+}
+
+func (s *WebhookServiceImpl) FindById(ctx context.Context, subscriptionId string, userId string) models.WebhookSubscriptionResponse {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeWebhooksRead)
+
+	return models.ToWebhookSubscriptionResponse(s.findSubscription(ctx, tx, subscriptionId))
+}
+
+func (s *WebhookServiceImpl) FindDeliveries(ctx context.Context, subscriptionId string, userId string) []models.WebhookDelivery {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeWebhooksRead)
+	subscription := s.findSubscription(ctx, tx, subscriptionId)
+
+	deliveries, err := s.WebhookRepository.FindDeliveries(ctx, tx, subscription.ID)
+	helpers.PanicIfError(err)
+
+	return deliveries
+}
+
+func (s *WebhookServiceImpl) Redeliver(ctx context.Context, deliveryId string, userId string) models.WebhookDelivery {
+	tx := s.DB.Begin()
+	defer helpers.CommitOrRollback(tx)
+
+	ensureScope(ctx, consts.ScopeWebhooksWrite)
+
+	delivery, err := s.WebhookRepository.FindDelivery(ctx, tx, deliveryId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError("webhook delivery not found"))
+	}
+
+	_, err = s.JobQueue.Enqueue(ctx, tx, consts.JobTypeWebhookDelivery, models.WebhookDeliveryJob{DeliveryID: delivery.ID})
+	helpers.PanicIfError(err)
+
+	return delivery
+}
+
+// Dispatch records a delivery for every active subscription to the event and
+// queues it. A delivery is unique per subscription and event, so an event the
+// bus hands over again does not reach the receiver twice.
+func (s *WebhookServiceImpl) Dispatch(ctx context.Context, event events.Event) error {
+	if !isWebhookEvent(event.Type) {
+		return nil
+	}
+
+	subscriptions, err := s.WebhookRepository.FindActiveSubscriptions(ctx, s.DB)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, subscription := range subscriptions {
+			if !subscription.Subscribes(event.Type) {
+				continue
+			}
+
+			delivery, created, err := s.WebhookRepository.CreateDelivery(ctx, tx, models.WebhookDelivery{
+				ID:             uuid.New().String(),
+				SubscriptionID: subscription.ID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				Payload:        string(payload),
+			})
+			if err != nil {
+				return err
+			}
+			if !created {
+				continue
+			}
+
+			_, err = s.JobQueue.Enqueue(ctx, tx, consts.JobTypeWebhookDelivery, models.WebhookDeliveryJob{DeliveryID: delivery.ID})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Deliver makes one attempt to post a delivery and logs the outcome. It returns
+// an error for anything but a 2xx response so the job queue retries it.
+func (s *WebhookServiceImpl) Deliver(ctx context.Context, deliveryId string) error {
+	delivery, err := s.WebhookRepository.FindDelivery(ctx, s.DB, deliveryId)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.WebhookRepository.FindSubscription(ctx, s.DB, delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	delivery.StatusCode = 0
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	deliverErr := s.post(ctx, subscription, &delivery)
+	if deliverErr != nil {
+		delivery.Error = deliverErr.Error()
+	} else {
+		now := time.Now()
+		delivery.Succeeded = true
+		delivery.DeliveredAt = &now
+	}
+
+	err = s.WebhookRepository.UpdateDelivery(ctx, s.DB, delivery)
+	if err != nil {
+		return err
+	}
+	return deliverErr
+}
+
+func (s *WebhookServiceImpl) post(ctx context.Context, subscription models.WebhookSubscription, delivery *models.WebhookDelivery) error {
+	// Subscriptions saved before targets were checked still get checked here.
+	err := checkWebhookURL(subscription.URL, s.Config)
+	if err != nil {
+		return err
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, delivery.EventType)
+	request.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	request.Header.Set(WebhookSignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, SignWebhookPayload(subscription.Secret, timestamp, body)))
+
+	response, err := s.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, webhookResponseLimit))
+	delivery.StatusCode = response.StatusCode
+	delivery.ResponseBody = string(responseBody)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("receiver responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+func (s *WebhookServiceImpl) findSubscription(ctx context.Context, tx *gorm.DB, subscriptionId string) models.WebhookSubscription {
+	subscription, err := s.WebhookRepository.FindSubscription(ctx, tx, subscriptionId)
+	if err != nil {
+		panic(exceptions.NewNotFoundError("webhook subscription not found"))
+	}
+	return subscription
+}
+
+func (s *WebhookServiceImpl) ensureTarget(url string) {
+	err := checkWebhookURL(url, s.Config)
+	if err != nil {
+		panic(exceptions.NewBadRequestError(err.Error()))
+	}
+}
+
+func isWebhookEvent(eventType string) bool {
+	switch eventType {
+	case consts.EventOrderCreated, consts.EventOrderPaid, consts.EventOrderCancelled, consts.EventOrderShipped, consts.EventProductUpdated, consts.EventStockChanged:
+		return true
+	}
+	return false
+}
+
+func newWebhookSecret() string {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	helpers.PanicIfError(err)
+	return "whsec_" + hex.EncodeToString(secret)
+}
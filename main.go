@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"zen-test/app"
 	"zen-test/app/helpers"
-	"zen-test/app/middleware"
 
 	docs "zen-test/docs"
 
@@ -24,7 +28,10 @@ import (
 // @name Authorization
 // @description Bearer token authentication
 func main() {
-	router, appConfig := app.Init()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	router, handler, appConfig := app.Init(ctx)
 
 	docs.SwaggerInfo.Title = appConfig.AppName + " API"
 	docs.SwaggerInfo.Description = appConfig.AppDescription
@@ -34,15 +41,26 @@ func main() {
 	docs.SwaggerInfo.Schemes = appConfig.AppSchema
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
-	authRouter := middleware.AuthMiddleware(router)
 
 	fmt.Println("Welcome to " + appConfig.AppName)
 	fmt.Println("Starting server on " + appConfig.AppHost)
 
 	server := http.Server{
 		Addr:    "localhost:" + appConfig.AppPort,
-		Handler: authRouter,
+		Handler: handler,
 	}
-	err := server.ListenAndServe()
+	go func() {
+		err := server.ListenAndServe()
+		if err != http.ErrServerClosed {
+			helpers.PanicIfError(err)
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("Shutting down " + appConfig.AppName)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
 	helpers.PanicIfError(err)
 }
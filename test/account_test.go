@@ -0,0 +1,183 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/jobs"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestExportMyData(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateAddress(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	createOrder(mockOrder(success, product.ID), user, product, db)
+	createAddress(t, router, token, mockAddress("Home", "Jl. Braga 1"))
+
+	var export models.UserExport
+	response := getAuthorized(router, "/users/me/export", token, &export)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, user.Email, export.Profile.Email)
+	assert.Equal(t, 1, len(export.Addresses))
+	assert.Equal(t, 1, len(export.Orders))
+
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/users/me/export?format=zip", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "application/zip", recorder.Header().Get("Content-Type"))
+
+	body := recorder.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(archive.File))
+
+	file, err := archive.Open("profile.json")
+	assert.Equal(t, nil, err)
+	raw, _ := io.ReadAll(file)
+	var profile models.UserResponse
+	json.Unmarshal(raw, &profile)
+	assert.Equal(t, user.ID, profile.ID)
+
+	response = getAuthorized(router, "/users/me/export?format=xml", token, nil)
+	assert.Equal(t, 400, response.StatusCode)
+}
+
+func TestDeleteAccountAnonymizes(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateAddress(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateSession(db)
+	truncateRefreshToken(db)
+	truncateLoginAttempt(db)
+	truncateEmailVerification(db)
+	truncateJob(db)
+	truncateOutbox(db)
+	truncateWebhook(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order := createOrder(mockOrder(success, product.ID), user, product, db)
+	createAddress(t, router, token, mockAddress("Home", "Jl. Braga 1"))
+	accessToken, refreshToken := loginTokens(router)
+	copyPersonalData(t, db, user, order)
+
+	request := httptest.NewRequest(http.MethodDelete, baseURL+"/users/me", toRequestBody(models.AccountDelete{Password: "wrong-one"}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	response := serveDecoded(router, request, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	request = httptest.NewRequest(http.MethodDelete, baseURL+"/users/me", toRequestBody(models.AccountDelete{Password: mockUser(success).Password}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var stored models.User
+	db.Where("id = ?", user.ID).Take(&stored)
+	assert.Equal(t, "Deleted user", stored.Name)
+	assert.NotEqual(t, user.Email, stored.Email)
+	assert.Equal(t, "", stored.Phone)
+	assert.NotEqual(t, nil, stored.AnonymizedAt)
+
+	var addresses int64
+	db.Model(&models.Address{}).Where("user_id = ?", user.ID).Count(&addresses)
+	assert.Equal(t, int64(0), addresses)
+
+	// The order keeps what it cost but not who it went to.
+	var storedOrder models.Order
+	db.Where("id = ?", order.ID).Take(&storedOrder)
+	assert.Equal(t, "Deleted user", storedOrder.CustomerName)
+	assert.Equal(t, "", storedOrder.Phone)
+	assert.Equal(t, "", storedOrder.Address)
+	assert.Equal(t, order.TotalPrice, storedOrder.TotalPrice)
+
+	// Nothing else still holds the email address.
+	for _, model := range []interface{}{&models.OutboxEvent{}, &models.WebhookDelivery{}, &models.Job{}, &models.DeadJob{}} {
+		var copies int64
+		db.Model(model).Where("payload LIKE ?", "%"+user.Email+"%").Count(&copies)
+		assert.Equal(t, int64(0), copies)
+	}
+	// The email a worker was sending is left to it, with nothing personal
+	// left in the row and no retries.
+	var running models.Job
+	db.Where("status = ?", consts.JobStatusRunning).Take(&running)
+	assert.Equal(t, "{}", running.Payload)
+	assert.Equal(t, "", running.UserID)
+	assert.Equal(t, running.Attempts, running.MaxAttempts)
+	var jobCount int64
+	db.Model(&models.Job{}).Count(&jobCount)
+	assert.Equal(t, int64(1), jobCount)
+
+	var delivery models.WebhookDelivery
+	db.Take(&delivery)
+	assert.Equal(t, true, strings.Contains(delivery.Payload, consts.EventUserRegistered))
+
+	response, _ = refreshTokens(router, refreshToken)
+	assert.Equal(t, 401, response.StatusCode)
+	response = getAuthorized(router, "/users/me", token, nil)
+	assert.Equal(t, 403, response.StatusCode)
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: mockUser(success).Password})
+	assert.Equal(t, 401, response.StatusCode)
+
+	// The email is free to sign up with again.
+	response = postJSON(router, "/users/signup", mockUser(success))
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+// copyPersonalData leaves the email address of the user where events, webhook
+// deliveries and emails keep it: the signup and order events, a delivery of
+// the signup event, and a queued, a running and a dead email job.
+func copyPersonalData(t *testing.T, db *gorm.DB, user models.User, order models.Order) {
+	outboxRepo := repositories.NewOutboxRepository()
+	profile, _ := json.Marshal(models.ToUserReponse(user))
+	registered, err := outboxRepo.SaveEvent(context.Background(), db, models.OutboxEvent{
+		ID: uuid.New().String(), Type: consts.EventUserRegistered, AggregateType: "user", AggregateID: user.ID, UserID: user.ID, Payload: string(profile),
+	})
+	assert.Equal(t, nil, err)
+	_, err = outboxRepo.SaveEvent(context.Background(), db, models.OutboxEvent{
+		ID: uuid.New().String(), Type: consts.EventOrderCreated, AggregateType: "order", AggregateID: order.ID, Payload: `{"customer_name":"` + user.Email + `"}`,
+	})
+	assert.Equal(t, nil, err)
+
+	envelope, _ := json.Marshal(events.FromOutbox(registered))
+	_, _, err = repositories.NewWebhookRepository().CreateDelivery(context.Background(), db, models.WebhookDelivery{
+		ID: uuid.New().String(), SubscriptionID: uuid.New().String(), EventID: registered.ID, EventType: registered.Type, Payload: string(envelope),
+	})
+	assert.Equal(t, nil, err)
+
+	email := models.LinkEmail{UserID: user.ID, To: user.Email, Template: consts.EventUserRegistered, Link: "https://zenstore.test"}
+	job, err := queueTest(db).Enqueue(context.Background(), db, consts.JobTypeLinkEmail, email, jobs.ForUser(user.ID))
+	assert.Equal(t, nil, err)
+	db.Create(&models.DeadJob{ID: uuid.New().String(), Type: job.Type, Payload: job.Payload, UserID: user.ID, FailedAt: time.Now()})
+
+	running, err := queueTest(db).Enqueue(context.Background(), db, consts.JobTypeLinkEmail, email, jobs.ForUser(user.ID))
+	assert.Equal(t, nil, err)
+	db.Model(&models.Job{}).Where("id = ?", running.ID).Updates(map[string]interface{}{"status": consts.JobStatusRunning, "attempts": 1})
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+func truncateAddress(db *gorm.DB) {
+	db.Exec("TRUNCATE addresses")
+}
+
+func mockAddress(label string, street string) models.AddressCreateUpdate {
+	return models.AddressCreateUpdate{
+		Label:      label,
+		Recipient:  "Budiman",
+		Street:     street,
+		City:       "Bandung",
+		Province:   "Jawa Barat",
+		PostalCode: "40115",
+		Country:    "ID",
+		Phone:      "08811212112",
+	}
+}
+
+func createAddress(t *testing.T, router http.Handler, accessToken string, request models.AddressCreateUpdate) models.AddressResponse {
+	var address models.AddressResponse
+	response := postAuthorized(router, "/users/me/addresses", accessToken, request, &address)
+	assert.Equal(t, 200, response.StatusCode)
+	return address
+}
+
+func TestAddressBookDefault(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateAddress(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	home := createAddress(t, router, token, mockAddress("Home", "Jl. Braga 1"))
+	assert.Equal(t, true, home.IsDefault)
+
+	office := createAddress(t, router, token, mockAddress("Office", "Jl. Asia Afrika 8"))
+	assert.Equal(t, false, office.IsDefault)
+
+	response := postAuthorized(router, "/users/me/addresses", token, mockAddress("Bad", ""), nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response = postAuthorized(router, "/users/me/addresses/"+office.ID+"/default", token, nil, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/users/me/addresses", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+	var addresses []models.AddressResponse
+	response = serveDecoded(router, request, &addresses)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 2, len(addresses))
+	assert.Equal(t, office.ID, addresses[0].ID)
+	assert.Equal(t, false, addresses[1].IsDefault)
+
+	// Deleting the default hands it to the remaining address.
+	request = httptest.NewRequest(http.MethodDelete, baseURL+"/users/me/addresses/"+office.ID, nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	request = httptest.NewRequest(http.MethodGet, baseURL+"/users/me/addresses/"+home.ID, nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+	response = serveDecoded(router, request, &home)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, true, home.IsDefault)
+
+	// Other users cannot see the address.
+	staff := createStaff(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	request = httptest.NewRequest(http.MethodGet, baseURL+"/users/me/addresses/"+home.ID, nil)
+	request.Header.Add("Authorization", "Bearer "+staffToken)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestOrderSnapshotsShippingAddress(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateAddress(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+
+	createAddress(t, router, token, mockAddress("Home", "Jl. Braga 1"))
+	office := createAddress(t, router, token, mockAddress("Office", "Jl. Asia Afrika 8"))
+
+	order := models.OrderItemCreateUpdate{ProductID: product.ID, Quantity: 1, AddressID: office.ID}
+	var created models.OrderResponse
+	response := postAuthorized(router, "/orders", token, order, &created)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "Jl. Asia Afrika 8", created.ShippingAddress.Street)
+	assert.Equal(t, "Jl. Asia Afrika 8, Bandung, Jawa Barat, 40115, ID", created.Address)
+
+	// Editing the address later leaves the order as it was placed.
+	edited := mockAddress("Office", "Jl. Dago 99")
+	request := httptest.NewRequest(http.MethodPut, baseURL+"/users/me/addresses/"+office.ID, toRequestBody(edited))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var stored models.Order
+	db.Where("id = ?", created.ID).Take(&stored)
+	assert.Equal(t, "Jl. Asia Afrika 8", stored.ShippingAddress.Street)
+
+	// Without a choice the order ships to the default address.
+	response = postAuthorized(router, "/orders", token, models.OrderItemCreateUpdate{ProductID: product.ID, Quantity: 1}, &created)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "Jl. Braga 1", created.ShippingAddress.Street)
+
+	// Nor can an order ship to someone else's address.
+	staff := createStaff(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	response = postAuthorized(router, "/orders", staffToken, order, nil)
+	assert.Equal(t, 404, response.StatusCode)
+}
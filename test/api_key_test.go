@@ -0,0 +1,113 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+func apiKeyServiceTest(db *gorm.DB) services.APIKeyService {
+	return services.NewAPIKeyService(repositories.NewAPIKeyRepository(), services.APIKeyConfig{TouchInterval: time.Minute}, db, validator.New())
+}
+
+func truncateAPIKey(db *gorm.DB) {
+	db.Exec("TRUNCATE api_keys")
+}
+
+func createAPIKey(t *testing.T, router http.Handler, accessToken string, request models.APIKeyCreate) models.APIKeyResponse {
+	var apiKey models.APIKeyResponse
+	response := postAuthorized(router, "/users/me/api-keys", accessToken, request, &apiKey)
+	assert.Equal(t, 200, response.StatusCode)
+	return apiKey
+}
+
+func withAPIKey(router http.Handler, method string, path string, key string) *http.Response {
+	request := httptest.NewRequest(method, baseURL+path, nil)
+	request.Header.Add("X-API-Key", key)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateAPIKey(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	accessToken, _ := loginTokens(router)
+
+	apiKey := createAPIKey(t, router, accessToken, models.APIKeyCreate{Name: "ERP", Scopes: []string{"orders:read:own"}})
+	assert.Equal(t, true, strings.HasPrefix(apiKey.Key, "zk_"+apiKey.Prefix+"_"))
+
+	var stored models.APIKey
+	db.Where("id = ?", apiKey.ID).Take(&stored)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.NotEqual(t, apiKey.Key, stored.KeyHash)
+
+	response := withAPIKey(router, http.MethodGet, "/orders", apiKey.Key)
+	assert.Equal(t, 200, response.StatusCode)
+
+	db.Where("id = ?", apiKey.ID).Take(&stored)
+	assert.NotEqual(t, nil, stored.LastUsedAt)
+
+	// The key is never shown again.
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/users/me/api-keys", nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	var listed []models.APIKeyResponse
+	response = serveDecoded(router, request, &listed)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 1, len(listed))
+	assert.Equal(t, "", listed[0].Key)
+
+	// A key cannot mint more keys.
+	response = withAPIKey(router, http.MethodGet, "/users/me/api-keys", apiKey.Key)
+	assert.Equal(t, 403, response.StatusCode)
+
+	request = httptest.NewRequest(http.MethodDelete, baseURL+"/users/me/api-keys/"+apiKey.ID, nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	response = withAPIKey(router, http.MethodGet, "/orders", apiKey.Key)
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestAPIKeyRejectsExpiredAndTamperedKeys(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateAPIKey(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+	accessToken, _ := loginTokens(router)
+
+	past := time.Now().Add(-time.Hour)
+	response := postAuthorized(router, "/users/me/api-keys", accessToken, models.APIKeyCreate{Name: "ERP", Scopes: []string{"orders:read:own"}, ExpiresAt: &past}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response = postAuthorized(router, "/users/me/api-keys", accessToken, models.APIKeyCreate{Name: "ERP", Scopes: []string{"everything"}}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	apiKey := createAPIKey(t, router, accessToken, models.APIKeyCreate{Name: "ERP", Scopes: []string{"orders:read:own"}})
+
+	tampered := apiKey.Key[:len(apiKey.Key)-1] + "x"
+	if tampered == apiKey.Key {
+		tampered = apiKey.Key[:len(apiKey.Key)-1] + "y"
+	}
+	response = withAPIKey(router, http.MethodGet, "/orders", tampered)
+	assert.Equal(t, 401, response.StatusCode)
+
+	db.Model(&models.APIKey{}).Where("id = ?", apiKey.ID).Update("expires_at", past)
+	response = withAPIKey(router, http.MethodGet, "/orders", apiKey.Key)
+	assert.Equal(t, 401, response.StatusCode)
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/middleware"
+	"zen-test/app/realtime"
+	"zen-test/app/web/controllers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+func dashboardConfigTest() realtime.DashboardConfig {
+	return realtime.DashboardConfig{LowStockThreshold: 5, PingInterval: time.Minute, WriteTimeout: time.Second}
+}
+
+func dashboardServerTest(db *gorm.DB, hub *realtime.Hub) *httptest.Server {
+	dashboardController := controllers.NewDashboardController(services.NewDashboardService(), hub, dashboardConfigTest())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/dashboard/ws", dashboardController.Feed).Methods("GET")
+	router.Use(middleware.RecoverMiddleware)
+	router.Use(middleware.RequireScopes(map[string]string{"GET /admin/dashboard/ws": consts.ScopeDashboardRead}, roleScopesTest{db}))
+	return httptest.NewServer(authMiddlewareTest(db)(router))
+}
+
+func dialDashboard(server *httptest.Server, token string) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{}
+	header.Add("Authorization", "Bearer "+token)
+	return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/admin/dashboard/ws", header)
+}
+
+func readDashboardMessage(t *testing.T, conn *websocket.Conn) realtime.DashboardMessage {
+	var message realtime.DashboardMessage
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	err := conn.ReadJSON(&message)
+	assert.Equal(t, nil, err)
+	return message
+}
+
+func stockChangedEvent(delta int64, stock uint32) events.Event {
+	payload, _ := json.Marshal(models.StockChangedPayload{ProductID: uuid.New().String(), Delta: delta, Stock: stock})
+	return events.Event{ID: uuid.New().String(), Type: consts.EventStockChanged, AggregateType: "product", Payload: payload}
+}
+
+func TestDashboardFeedSendsSubscribedTopics(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+
+	hub := hubTest(db)
+	server := dashboardServerTest(db, hub)
+	defer server.Close()
+
+	conn, _, err := dialDashboard(server, token)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	conn.WriteJSON(realtime.DashboardCommand{Action: "subscribe", Topics: []string{realtime.TopicOrders}})
+	ack := readDashboardMessage(t, conn)
+	assert.Equal(t, realtime.MessageTopics, ack.Type)
+	assert.Equal(t, []string{realtime.TopicOrders}, ack.Topics)
+
+	// Inventory is not subscribed, so only the order event comes through.
+	hub.Broadcast(stockChangedEvent(-1, 10))
+	hub.Broadcast(events.Event{ID: uuid.New().String(), Type: consts.EventOrderPaid, AggregateType: "order"})
+
+	message := readDashboardMessage(t, conn)
+	assert.Equal(t, realtime.MessageEvent, message.Type)
+	assert.Equal(t, realtime.TopicOrders, message.Topic)
+	assert.Equal(t, consts.EventOrderPaid, message.Event.Type)
+}
+
+func TestDashboardFeedSendsLowStockAlert(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+
+	hub := hubTest(db)
+	server := dashboardServerTest(db, hub)
+	defer server.Close()
+
+	conn, _, err := dialDashboard(server, token)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	conn.WriteJSON(realtime.DashboardCommand{Action: "subscribe", Topics: []string{realtime.TopicInventory}})
+	readDashboardMessage(t, conn)
+
+	hub.Broadcast(stockChangedEvent(-3, 2))
+
+	assert.Equal(t, realtime.MessageEvent, readDashboardMessage(t, conn).Type)
+	assert.Equal(t, realtime.MessageLowStock, readDashboardMessage(t, conn).Type)
+}
+
+func TestDashboardFeedForbiddenForCustomer(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	server := dashboardServerTest(db, hubTest(db))
+	defer server.Close()
+
+	_, response, err := dialDashboard(server, token)
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 403, response.StatusCode)
+}
+
+func TestDashboardFeedAcceptsTokenAsSubprotocol(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+
+	server := dashboardServerTest(db, hubTest(db))
+	defer server.Close()
+
+	// What a browser sends with new WebSocket(url, ["bearer", token]).
+	dialer := websocket.Dialer{Subprotocols: []string{middleware.WebSocketBearerProtocol, token}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/admin/dashboard/ws", nil)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+	assert.Equal(t, middleware.WebSocketBearerProtocol, conn.Subprotocol())
+
+	_, response, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/admin/dashboard/ws", nil)
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	db := dbTest()
+	hub := realtime.NewHub(db, repositories.NewOutboxRepository(), realtime.HubConfig{PollInterval: time.Second, BatchSize: 100, BufferSize: 1})
+
+	slow := hub.Subscribe(func(events.Event) bool { return true })
+	hub.Broadcast(stockChangedEvent(-1, 10))
+	hub.Broadcast(stockChangedEvent(-1, 9))
+
+	_, open := <-slow.Events()
+	assert.Equal(t, true, open)
+	_, open = <-slow.Events()
+	assert.Equal(t, false, open)
+}
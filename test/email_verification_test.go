@@ -0,0 +1,142 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+func emailVerificationConfigTest() services.EmailVerificationConfig {
+	return services.EmailVerificationConfig{
+		TokenTTL:       time.Hour,
+		ResendInterval: time.Minute,
+		MaxPerDay:      3,
+		URL:            "http://localhost:3000/verify-email",
+	}
+}
+
+func truncateEmailVerification(db *gorm.DB) {
+	db.Exec("TRUNCATE email_verification_tokens")
+}
+
+// signUpTest signs the mock user up and returns the token of the emailed
+// verification link.
+func signUpTest(t *testing.T, db *gorm.DB, router http.Handler) string {
+	_, queue, notifier := notificationTest(db)
+
+	response := postJSON(router, "/users/signup", mockUser(success))
+	assert.Equal(t, 200, response.StatusCode)
+	drainQueue(t, queue)
+
+	messages := notifier.Messages()
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "Verify your Zenstore email address", messages[0].Subject)
+
+	match := linkTokenPattern.FindStringSubmatch(messages[0].Text)
+	assert.Equal(t, 2, len(match))
+	return match[1]
+}
+
+func resendVerification(router http.Handler, accessToken string) *http.Response {
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/email/verify/resend", nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+func TestVerifyEmailOnSignup(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateEmailVerification(db)
+	truncateJob(db)
+	router := routerTest(db)
+
+	token := signUpTest(t, db, router)
+
+	var user models.User
+	db.Where("email = ?", mockUser(success).Email).Take(&user)
+	assert.Equal(t, true, user.EmailVerifiedAt == nil)
+
+	response := postJSON(router, "/users/email/verify", models.EmailVerify{Token: token})
+	assert.Equal(t, 200, response.StatusCode)
+
+	db.Where("id = ?", user.ID).Take(&user)
+	assert.Equal(t, false, user.EmailVerifiedAt == nil)
+
+	response = postJSON(router, "/users/email/verify", models.EmailVerify{Token: token})
+	assert.Equal(t, 400, response.StatusCode)
+}
+
+func TestResendVerificationIsRateLimited(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateEmailVerification(db)
+	truncateJob(db)
+	router := routerTest(db)
+
+	signUpTest(t, db, router)
+	var user models.User
+	db.Where("email = ?", mockUser(success).Email).Take(&user)
+	accessToken, _ := tokensTest.Issue(user.ID, "")
+
+	response := resendVerification(router, accessToken)
+	assert.Equal(t, 429, response.StatusCode)
+	assert.NotEqual(t, "", response.Header.Get("Retry-After"))
+
+	db.Model(&models.EmailVerificationToken{}).Where("user_id = ?", user.ID).Update("created_at", time.Now().Add(-2*time.Minute))
+	response = resendVerification(router, accessToken)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// The daily cap counts every email sent, however far apart.
+	db.Model(&models.EmailVerificationToken{}).Where("user_id = ?", user.ID).Update("created_at", time.Now().Add(-2*time.Minute))
+	response = resendVerification(router, accessToken)
+	assert.Equal(t, 200, response.StatusCode)
+	db.Model(&models.EmailVerificationToken{}).Where("user_id = ?", user.ID).Update("created_at", time.Now().Add(-2*time.Minute))
+	response = resendVerification(router, accessToken)
+	assert.Equal(t, 429, response.StatusCode)
+
+	now := time.Now()
+	db.Model(&models.User{}).Where("id = ?", user.ID).Update("email_verified_at", &now)
+	response = resendVerification(router, accessToken)
+	assert.Equal(t, 400, response.StatusCode)
+}
+
+func TestPolicyRequiresVerifiedEmail(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	router := routerWithPolicyTest(db, []string{"POST /orders"})
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+
+	createOrder := func() *http.Response {
+		request := httptest.NewRequest(http.MethodPost, baseURL+"/orders", toRequestBody(mockOrder(success, product.ID)))
+		request.Header.Add("Content-Type", "application/json")
+		request.Header.Add("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Result()
+	}
+
+	assert.Equal(t, 403, createOrder().StatusCode)
+
+	// Routes outside the policy are unaffected.
+	response, _ := findSessions(router, token)
+	assert.Equal(t, 200, response.StatusCode)
+
+	now := time.Now()
+	db.Model(&models.User{}).Where("id = ?", user.ID).Update("email_verified_at", &now)
+	assert.Equal(t, 200, createOrder().StatusCode)
+}
@@ -0,0 +1,183 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"zen-test/app/consts"
+	"zen-test/app/jobs"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+type greetingPayload struct {
+	Name string `json:"name"`
+}
+
+func queueTest(db *gorm.DB) *jobs.Queue {
+	return jobs.NewQueue(db, jobs.Config{
+		Workers:      1,
+		PollInterval: time.Second,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Minute,
+		MaxBackoff:   time.Hour,
+		LockTimeout:  time.Minute,
+		LeaseTTL:     time.Minute,
+	})
+}
+
+func truncateJob(db *gorm.DB) {
+	db.Exec("TRUNCATE jobs")
+	db.Exec("TRUNCATE dead_jobs")
+	db.Exec("TRUNCATE job_leases")
+}
+
+func TestJobQueueRunsTypedHandler(t *testing.T) {
+	db := dbTest()
+	truncateJob(db)
+	queue := queueTest(db)
+
+	var received string
+	jobs.Handle(queue, "test.greet", func(ctx context.Context, payload greetingPayload) error {
+		received = payload.Name
+		return nil
+	})
+
+	job, err := queue.Enqueue(context.Background(), db, "test.greet", greetingPayload{Name: "Budiman"})
+	assert.Equal(t, nil, err)
+
+	processed, err := queue.RunNext(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, processed)
+	assert.Equal(t, "Budiman", received)
+
+	var done models.Job
+	db.Take(&done, "id = ?", job.ID)
+	assert.Equal(t, consts.JobStatusDone, done.Status)
+	assert.Equal(t, 1, done.Attempts)
+}
+
+func TestJobQueueRetriesWithBackoff(t *testing.T) {
+	db := dbTest()
+	truncateJob(db)
+	queue := queueTest(db)
+
+	jobs.Handle(queue, "test.fail", func(ctx context.Context, payload greetingPayload) error {
+		return errors.New("smtp is down")
+	})
+
+	job, _ := queue.Enqueue(context.Background(), db, "test.fail", greetingPayload{Name: "Budiman"})
+	queue.RunNext(context.Background())
+
+	var retried models.Job
+	db.Take(&retried, "id = ?", job.ID)
+	assert.Equal(t, consts.JobStatusPending, retried.Status)
+	assert.Equal(t, "smtp is down", retried.LastError)
+	assert.Equal(t, true, retried.RunAt.After(time.Now().Add(50*time.Second)))
+
+	processed, _ := queue.RunNext(context.Background())
+	assert.Equal(t, false, processed)
+}
+
+func TestJobQueueDeadLetter(t *testing.T) {
+	db := dbTest()
+	truncateJob(db)
+	queue := queueTest(db)
+
+	jobs.Handle(queue, "test.fail", func(ctx context.Context, payload greetingPayload) error {
+		return errors.New("smtp is down")
+	})
+
+	job, _ := queue.Enqueue(context.Background(), db, "test.fail", greetingPayload{Name: "Budiman"}, jobs.MaxAttempts(1))
+	queue.RunNext(context.Background())
+
+	var count int64
+	db.Model(&models.Job{}).Where("id = ?", job.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	var deadJob models.DeadJob
+	db.Take(&deadJob, "id = ?", job.ID)
+	assert.Equal(t, "test.fail", deadJob.Type)
+	assert.Equal(t, "smtp is down", deadJob.LastError)
+}
+
+func TestParseSchedule(t *testing.T) {
+	after := time.Date(2024, time.July, 10, 10, 7, 30, 0, time.UTC)
+
+	every, err := jobs.ParseSchedule("@every 90m")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, after.Add(90*time.Minute), every.Next(after))
+
+	quarterly, _ := jobs.ParseSchedule("*/15 * * * *")
+	assert.Equal(t, time.Date(2024, time.July, 10, 10, 15, 0, 0, time.UTC), quarterly.Next(after))
+
+	daily, _ := jobs.ParseSchedule("@daily")
+	assert.Equal(t, time.Date(2024, time.July, 11, 0, 0, 0, 0, time.UTC), daily.Next(after))
+
+	weekdays, _ := jobs.ParseSchedule("30 8 * * 1-5")
+	assert.Equal(t, time.Date(2024, time.July, 11, 8, 30, 0, 0, time.UTC), weekdays.Next(after))
+
+	_, err = jobs.ParseSchedule("61 * * * *")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestLeaseLockerTakeover(t *testing.T) {
+	db := dbTest()
+	truncateJob(db)
+
+	leader := jobs.NewLeaseLocker(db, "replica-a", time.Second)
+	follower := jobs.NewLeaseLocker(db, "replica-b", time.Second)
+
+	// Cancelling the holder context stops lease renewal, like a crashed replica.
+	leaderCtx, crash := context.WithCancel(context.Background())
+	_, acquired, err := leader.TryLock(leaderCtx, "order-expiry")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, acquired)
+
+	_, acquired, _ = follower.TryLock(context.Background(), "order-expiry")
+	assert.Equal(t, false, acquired)
+
+	crash()
+	time.Sleep(1100 * time.Millisecond)
+
+	lock, acquired, err := follower.TryLock(context.Background(), "order-expiry")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, acquired)
+
+	lock.Unlock()
+	_, acquired, _ = leader.TryLock(context.Background(), "order-expiry")
+	assert.Equal(t, true, acquired)
+}
+
+func TestScheduledJobRunsOnce(t *testing.T) {
+	db := dbTest()
+	truncateJob(db)
+	queue := queueTest(db)
+
+	runs := 0
+	jobs.Handle(queue, consts.JobTypeOrderExpiry, func(ctx context.Context, _ struct{}) error {
+		runs++
+		return nil
+	})
+	queue.Schedule("order-expiry", "@every 1h", consts.JobTypeOrderExpiry, nil)
+
+	// Another replica is already running the job.
+	other := jobs.NewLeaseLocker(db, "replica-b", time.Minute)
+	lock, _, _ := other.TryLock(context.Background(), "zenstore:jobs:"+consts.JobTypeOrderExpiry)
+
+	job, _ := queue.Enqueue(context.Background(), db, consts.JobTypeOrderExpiry, nil)
+	queue.RunNext(context.Background())
+	assert.Equal(t, 0, runs)
+
+	var skipped models.Job
+	db.Take(&skipped, "id = ?", job.ID)
+	assert.Equal(t, consts.JobStatusDone, skipped.Status)
+
+	lock.Unlock()
+	queue.Enqueue(context.Background(), db, consts.JobTypeOrderExpiry, nil)
+	queue.RunNext(context.Background())
+	assert.Equal(t, 1, runs)
+}
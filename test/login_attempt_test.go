@@ -0,0 +1,187 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func loginThrottleConfigTest() services.LoginThrottleConfig {
+	return services.LoginThrottleConfig{
+		Window:         15 * time.Minute,
+		DelayAfter:     3,
+		BaseDelay:      time.Minute,
+		MaxDelay:       10 * time.Minute,
+		LockoutAfter:   5,
+		IPLockoutAfter: 8,
+	}
+}
+
+// trustedProxiesTest is the load balancer network the test router believes
+// X-Forwarded-For from.
+var trustedProxiesTest, _ = helpers.ParseTrustedProxies([]string{"10.0.0.0/8"})
+
+func truncateLoginAttempt(db *gorm.DB) {
+	db.Exec("TRUNCATE login_attempts")
+}
+
+// failedAttempts records earlier failed logins of the email from the IP.
+func failedAttempts(db *gorm.DB, email string, ip string, count int, at time.Time) {
+	for i := 0; i < count; i++ {
+		db.Create(&models.LoginAttempt{
+			ID:        uuid.New().String(),
+			Email:     email,
+			IP:        ip,
+			Result:    models.LoginFailed,
+			Reason:    "wrong_password",
+			CreatedAt: at.Add(time.Duration(i) * time.Second),
+		})
+	}
+}
+
+func countAttempts(db *gorm.DB, result string) int64 {
+	var count int64
+	db.Model(&models.LoginAttempt{}).Where("result = ?", result).Count(&count)
+	return count
+}
+
+func TestLoginDoesNotRevealAccounts(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+
+	wrongPassword := postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: "not-the-password"})
+	unknownEmail := postJSON(router, "/users/login", models.UserLogin{Email: "nobody@gmail.com", Password: "not-the-password"})
+
+	assert.Equal(t, 401, wrongPassword.StatusCode)
+	assert.Equal(t, 401, unknownEmail.StatusCode)
+
+	wrongPasswordBody, _ := io.ReadAll(wrongPassword.Body)
+	unknownEmailBody, _ := io.ReadAll(unknownEmail.Body)
+	assert.Equal(t, string(wrongPasswordBody), string(unknownEmailBody))
+
+	var attempts []models.LoginAttempt
+	db.Order("created_at").Find(&attempts)
+	assert.Equal(t, 2, len(attempts))
+	assert.Equal(t, user.ID, attempts[0].UserID)
+	assert.Equal(t, "wrong_password", attempts[0].Reason)
+	assert.Equal(t, "", attempts[1].UserID)
+	assert.Equal(t, "unknown_email", attempts[1].Reason)
+}
+
+func TestLoginThrottlesRepeatedFailures(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	password := mockUser(success).Password
+
+	for i := 0; i < 3; i++ {
+		response := postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: "not-the-password"})
+		assert.Equal(t, 401, response.StatusCode)
+	}
+
+	// Even the right password has to wait now.
+	response := postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: password})
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+	assert.NotEqual(t, "", response.Header.Get("Retry-After"))
+	assert.Equal(t, int64(3), countAttempts(db, models.LoginFailed))
+	assert.Equal(t, int64(1), countAttempts(db, models.LoginBlocked))
+
+	// Once the delay has passed the right password works and clears the
+	// failures.
+	truncateLoginAttempt(db)
+	failedAttempts(db, user.Email, "192.0.2.1", 3, time.Now().Add(-10*time.Minute))
+
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: password})
+	assert.Equal(t, 200, response.StatusCode)
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: "not-the-password"})
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestLoginLocksOut(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	password := mockUser(success).Password
+
+	// Too many failures of the email lock it until they leave the window.
+	failedAttempts(db, user.Email, "198.51.100.7", 5, time.Now().Add(-10*time.Minute))
+
+	response := postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: password})
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+
+	// Unknown emails are locked the same way.
+	failedAttempts(db, "nobody@gmail.com", "198.51.100.7", 5, time.Now().Add(-10*time.Minute))
+
+	response = postJSON(router, "/users/login", models.UserLogin{Email: "nobody@gmail.com", Password: password})
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+
+	// Failures older than the window no longer count.
+	truncateLoginAttempt(db)
+	failedAttempts(db, user.Email, "198.51.100.7", 5, time.Now().Add(-20*time.Minute))
+
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: password})
+	assert.Equal(t, 200, response.StatusCode)
+
+	// Too many failures from one IP lock out every email.
+	truncateLoginAttempt(db)
+	failedAttempts(db, "someone@gmail.com", "192.0.2.1", 8, time.Now().Add(-10*time.Minute))
+
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: password})
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+}
+
+// loginFrom logs in as the request would arrive from remoteAddr, forwarded
+// for the X-Forwarded-For value when it is not empty.
+func loginFrom(router http.Handler, remoteAddr string, forwardedFor string, login models.UserLogin) *http.Response {
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/login", toRequestBody(login))
+	request.Header.Add("Content-Type", "application/json")
+	request.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		request.Header.Add("X-Forwarded-For", forwardedFor)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+func TestLoginLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateLoginAttempt(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	login := models.UserLogin{Email: user.Email, Password: mockUser(success).Password}
+
+	failedAttempts(db, "someone@gmail.com", "192.0.2.1", 8, time.Now().Add(-10*time.Minute))
+
+	// A client talking to the app directly cannot pick another address.
+	response := loginFrom(router, "192.0.2.1:1234", "198.51.100.20", login)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+
+	// Behind a trusted proxy, what the client put first does not count either:
+	// the proxy appended the address it was connected from.
+	response = loginFrom(router, "10.0.0.2:1234", "198.51.100.20, 192.0.2.1, 10.0.0.3", login)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+
+	// Another client behind the same proxy is not locked out.
+	response = loginFrom(router, "10.0.0.2:1234", "198.51.100.20", login)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var attempt models.LoginAttempt
+	db.Where("result = ?", models.LoginSucceeded).Take(&attempt)
+	assert.Equal(t, "198.51.100.20", attempt.IP)
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/jobs"
+	"zen-test/app/notifications"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+// notificationTest wires the notification subscriber and email job the way
+// the app does, sending into memory.
+func notificationTest(db *gorm.DB) (*events.Bus, *jobs.Queue, *notifications.MemoryNotifier) {
+	renderer, err := notifications.NewRenderer()
+	if err != nil {
+		panic(err)
+	}
+
+	queue := queueTest(db)
+	notifier := notifications.NewMemoryNotifier()
+	notificationService := services.NewNotificationService(repositories.NewUserRepository(), queue, renderer, notifier, db)
+	jobs.Handle(queue, consts.JobTypeEmail, notificationService.SendEmail)
+	jobs.Handle(queue, consts.JobTypeLinkEmail, notificationService.SendLinkEmail)
+
+	bus := events.NewBus()
+	bus.Subscribe(events.AllEvents, "notifications", notificationService.Notify)
+	return bus, queue, notifier
+}
+
+func drainQueue(t *testing.T, queue *jobs.Queue) {
+	for {
+		ran, err := queue.RunNext(context.Background())
+		assert.Equal(t, nil, err)
+		if !ran {
+			return
+		}
+	}
+}
+
+func TestSignupSendsLocalizedWelcomeEmail(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateOutbox(db)
+	truncateJob(db)
+
+	user := mockUser(success)
+	user.Locale = "id"
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/signup", toRequestBody(user))
+	request.Header.Add("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	bus, queue, notifier := notificationTest(db)
+
+	_, err := relayTest(db, bus).RelayOnce(context.Background())
+	assert.Equal(t, nil, err)
+	// Nothing is sent until the email job runs.
+	assert.Equal(t, 0, len(notifier.Messages()))
+
+	drainQueue(t, queue)
+
+	// The welcome email comes with the email verification link.
+	messages := notifier.Messages()
+	assert.Equal(t, 2, len(messages))
+
+	subjects := make(map[string]notifications.Message)
+	for _, message := range messages {
+		assert.Equal(t, user.Email, message.To)
+		subjects[message.Subject] = message
+	}
+
+	welcome, ok := subjects["Selamat datang di Zenstore, Budiman"]
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, strings.Contains(welcome.HTML, "<strong>"+user.Email+"</strong>"))
+
+	_, ok = subjects["Verifikasi alamat email Zenstore Anda"]
+	assert.Equal(t, true, ok)
+}
+
+func TestCancelOrderSendsEmail(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateOutbox(db)
+	truncateJob(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order := createOrder(mockOrder(success, product.ID), user, product, db)
+	createOrderItem(order, product, 8, db)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+order.ID+"/cancel", toRequestBody(models.OrderCancel{Reason: "ordered by mistake"}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	bus, queue, notifier := notificationTest(db)
+
+	_, err := relayTest(db, bus).RelayOnce(context.Background())
+	assert.Equal(t, nil, err)
+	drainQueue(t, queue)
+
+	messages := notifier.Messages()
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "Order "+order.ID+" was cancelled", messages[0].Subject)
+	assert.Equal(t, true, strings.Contains(messages[0].Text, "Reason: ordered by mistake"))
+}
+
+func TestNotifyQueuesOneEmailPerEvent(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateJob(db)
+
+	user := createUser(mockUser(success), db)
+	bus, _, _ := notificationTest(db)
+
+	event := events.Event{ID: "event-1", Type: consts.EventUserRegistered, AggregateType: "user", AggregateID: user.ID, UserID: user.ID}
+	for i := 0; i < 2; i++ {
+		_, err := bus.Publish(context.Background(), event, nil)
+		assert.Equal(t, nil, err)
+	}
+
+	var emails int64
+	db.Model(&models.Job{}).Where("type = ?", consts.JobTypeEmail).Count(&emails)
+	assert.Equal(t, int64(1), emails)
+}
+
+func TestFileNotifierEncodesSubject(t *testing.T) {
+	dir := t.TempDir()
+	notifier := notifications.NewFileNotifier(dir, "shop@zenstore.test")
+
+	err := notifier.Send(context.Background(), notifications.Message{
+		To:      "budi@example.com",
+		Subject: "Selamat datang – Budi\r\nBcc: everyone@example.com",
+		Text:    "Hi",
+		HTML:    "<p>Hi</p>",
+	})
+	assert.Equal(t, nil, err)
+
+	files, _ := os.ReadDir(dir)
+	assert.Equal(t, 1, len(files))
+	raw, _ := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.Equal(t, false, strings.Contains(string(raw), "\r\nBcc:"))
+
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	assert.Equal(t, nil, err)
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	assert.Equal(t, "Selamat datang – Budi  Bcc: everyone@example.com", subject)
+	assert.Equal(t, "", message.Header.Get("Bcc"))
+}
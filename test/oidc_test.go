@@ -0,0 +1,225 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"zen-test/app/helpers"
+	"zen-test/app/oidc"
+	"zen-test/app/oidc/oidctest"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+var identityProviderTest = oidctest.NewProvider("zenstore", "zenstore-secret")
+
+func oidcProvidersTest() map[string]*oidc.Provider {
+	config := identityProviderTest.Config(baseURL + "/auth/oidc/fake/callback")
+	return map[string]*oidc.Provider{
+		"fake": oidc.NewProvider(config, identityProviderTest.Client()),
+	}
+}
+
+func truncateExternalIdentity(db *gorm.DB) {
+	db.Exec("TRUNCATE external_identities")
+	db.Exec("TRUNCATE oidc_login_states")
+}
+
+// oidcLogin is a login started with the fake provider: the URL the provider
+// sends the user back to and the state cookie their browser holds.
+type oidcLogin struct {
+	callback *url.URL
+	cookie   *http.Cookie
+}
+
+func oidcCallback(t *testing.T, router http.Handler) oidcLogin {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, baseURL+"/auth/oidc/fake/login", nil))
+	assert.Equal(t, http.StatusFound, recorder.Code)
+
+	var cookie *http.Cookie
+	for _, set := range recorder.Result().Cookies() {
+		if set.Name == helpers.OIDCState {
+			cookie = set
+		}
+	}
+	assert.NotEqual(t, nil, cookie)
+	assert.Equal(t, true, cookie.HttpOnly)
+
+	authURL := recorder.Header().Get("Location")
+	assert.Equal(t, true, strings.HasPrefix(authURL, identityProviderTest.URL+"/authorize?"))
+	assert.Equal(t, true, strings.Contains(authURL, "code_challenge_method=S256"))
+
+	client := *identityProviderTest.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, err := client.Get(authURL)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusFound, response.StatusCode)
+
+	callback, err := url.Parse(response.Header.Get("Location"))
+	assert.Equal(t, nil, err)
+	return oidcLogin{callback: callback, cookie: cookie}
+}
+
+func getCallback(router http.Handler, login oidcLogin, data interface{}) *http.Response {
+	request := httptest.NewRequest(http.MethodGet, login.callback.String(), nil)
+	if login.cookie != nil {
+		request.AddCookie(login.cookie)
+	}
+	return serveDecoded(router, request, data)
+}
+
+func TestOIDCLoginSignsUpNewUser(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateExternalIdentity(db)
+	router := routerTest(db)
+
+	identityProviderTest.SignInAs(oidc.Identity{Subject: "fake-1", Email: "new@gmail.com", EmailVerified: true, Name: "New User"})
+
+	var loginResponse models.UserLoginResponse
+	response := getCallback(router, oidcCallback(t, router), &loginResponse)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.NotEqual(t, "", loginResponse.Token)
+	assert.Equal(t, "New User", loginResponse.Name)
+
+	var user models.User
+	db.Where("email = ?", "new@gmail.com").Take(&user)
+	assert.Equal(t, loginResponse.ID, user.ID)
+	assert.NotEqual(t, nil, user.EmailVerifiedAt)
+
+	// The next login finds the linked user.
+	response = getCallback(router, oidcCallback(t, router), &loginResponse)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, user.ID, loginResponse.ID)
+
+	var users int64
+	db.Model(&models.User{}).Count(&users)
+	assert.Equal(t, int64(1), users)
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateExternalIdentity(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+
+	// An address the provider did not verify cannot take over the account.
+	identityProviderTest.SignInAs(oidc.Identity{Subject: "fake-2", Email: user.Email})
+	response := getCallback(router, oidcCallback(t, router), nil)
+	assert.Equal(t, 403, response.StatusCode)
+
+	identityProviderTest.SignInAs(oidc.Identity{Subject: "fake-3", Email: user.Email, EmailVerified: true})
+
+	var loginResponse models.UserLoginResponse
+	response = getCallback(router, oidcCallback(t, router), &loginResponse)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, user.ID, loginResponse.ID)
+
+	var identity models.ExternalIdentity
+	db.Where("provider = ? AND subject = ?", "fake", "fake-3").Take(&identity)
+	assert.Equal(t, user.ID, identity.UserID)
+}
+
+func TestOIDCCallbackRejectsReplayedState(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateExternalIdentity(db)
+	router := routerTest(db)
+
+	identityProviderTest.SignInAs(oidc.Identity{Subject: "fake-4", Email: "replay@gmail.com", EmailVerified: true})
+	login := oidcCallback(t, router)
+
+	response := getCallback(router, login, nil)
+	assert.Equal(t, 200, response.StatusCode)
+	response = getCallback(router, login, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, baseURL+"/auth/oidc/unknown/login", nil))
+	assert.Equal(t, 404, recorder.Code)
+}
+
+func TestOIDCCallbackRequiresTheBrowserThatStartedIt(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateExternalIdentity(db)
+	router := routerTest(db)
+
+	// An attacker finishes a login of their own at the provider and sends
+	// the callback to someone else's browser, with no state cookie or with
+	// the cookie of the victim's own login.
+	identityProviderTest.SignInAs(oidc.Identity{Subject: "fake-5", Email: "attacker@gmail.com", EmailVerified: true})
+	attacker := oidcCallback(t, router)
+	victim := oidcCallback(t, router)
+
+	response := getCallback(router, oidcLogin{callback: attacker.callback}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+	response = getCallback(router, oidcLogin{callback: attacker.callback, cookie: victim.cookie}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	var users int64
+	db.Model(&models.User{}).Count(&users)
+	assert.Equal(t, int64(0), users)
+
+	// The state was not spent, and its own browser still completes it.
+	response = getCallback(router, attacker, nil)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+// authenticateTest logs in at the fake provider with provider and returns
+// what Authenticate makes of the code it sends back.
+func authenticateTest(t *testing.T, fake *oidctest.Provider, provider *oidc.Provider) (oidc.Identity, error) {
+	ctx := context.Background()
+	codeVerifier, err := oidc.NewCodeVerifier()
+	assert.Equal(t, nil, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", oidc.CodeChallenge(codeVerifier))
+	assert.Equal(t, nil, err)
+
+	client := *fake.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, err := client.Get(authURL)
+	assert.Equal(t, nil, err)
+
+	callback, err := url.Parse(response.Header.Get("Location"))
+	assert.Equal(t, nil, err)
+	return provider.Authenticate(ctx, callback.Query().Get("code"), codeVerifier, "nonce")
+}
+
+func TestOIDCUnknownKeysAreFetchedAtMostOncePerInterval(t *testing.T) {
+	fake := oidctest.NewProvider("zenstore", "zenstore-secret")
+	defer fake.Close()
+	provider := oidc.NewProvider(fake.Config(baseURL+"/auth/oidc/fake/callback"), fake.Client())
+
+	fake.SignInAs(oidc.Identity{Subject: "fake-6", Email: "keys@gmail.com", EmailVerified: true})
+	_, err := authenticateTest(t, fake, provider)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, fake.JWKSRequests())
+
+	// Tokens naming keys the provider never published fail without sending
+	// a fetch each.
+	fake.SignWithUnknownKey(true)
+	for i := 0; i < 3; i++ {
+		_, err = authenticateTest(t, fake, provider)
+		assert.NotEqual(t, nil, err)
+	}
+	assert.Equal(t, 1, fake.JWKSRequests())
+
+	// The keys already known still verify.
+	fake.SignWithUnknownKey(false)
+	identity, err := authenticateTest(t, fake, provider)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "fake-6", identity.Subject)
+	assert.Equal(t, 1, fake.JWKSRequests())
+}
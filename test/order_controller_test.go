@@ -5,8 +5,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
-	"zen-test/app/auth"
+	"time"
 	"zen-test/app/consts"
 	"zen-test/app/helpers"
 	"zen-test/app/web/models"
@@ -39,6 +40,21 @@ func createOrder(order models.OrderItemCreateUpdate, user models.User, product m
 	return orderCreated
 }
 
+func createOrderItem(order models.Order, product models.Product, quantity uint32, db *gorm.DB) models.OrderItem {
+	orderItem := models.OrderItem{
+		ID:        uuid.New().String(),
+		OrderID:   order.ID,
+		ProductID: product.ID,
+		Quantity:  quantity,
+		Price:     product.Price,
+	}
+
+	err := db.Omit("Order", "Product").Create(&orderItem).Error
+	helpers.PanicIfError(err)
+
+	return orderItem
+}
+
 func mockOrder(conditional string, productId string) models.OrderItemCreateUpdate {
 	var orderItem models.OrderItemCreateUpdate
 
@@ -68,6 +84,7 @@ func mockOrder(conditional string, productId string) models.OrderItemCreateUpdat
 
 func truncateOrder(db *gorm.DB) {
 	db.Exec("TRUNCATE orders")
+	db.Exec("TRUNCATE order_expiry_totals")
 }
 
 func TestCreateOrderSuccess(t *testing.T) {
@@ -80,7 +97,7 @@ func TestCreateOrderSuccess(t *testing.T) {
 	data := mockUser(success) // make sure add success as parameter
 	user := createUser(data, db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 	product := createProduct(mockProduct(success), db)
 
 	requestBody := toRequestBody(mockOrder(success, product.ID))
@@ -115,7 +132,7 @@ func TestFindAllOrdersSuccess(t *testing.T) {
 	data := mockUser(success) // make sure add success as parameter
 	user := createUser(data, db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 
 	product := createProduct(mockProduct(success), db)
 	createOrder(mockOrder(success, product.ID), user, product, db)
@@ -139,3 +156,285 @@ func TestFindAllOrdersSuccess(t *testing.T) {
 	assert.Equal(t, 200, int(responseBody["code"].(float64)))
 	assert.Equal(t, statusOk, responseBody["status"])
 }
+
+func TestCancelOrderSuccess(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order := createOrder(mockOrder(success, product.ID), user, product, db)
+	createOrderItem(order, product, 8, db)
+
+	requestBody := toRequestBody(models.OrderCancel{Reason: "Changed my mind"})
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+order.ID+"/cancel", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 200, response.StatusCode)
+
+	body, _ := io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	assert.Equal(t, 200, int(responseBody["code"].(float64)))
+	assert.Equal(t, consts.OrderPaymentStatusCancel, responseBody["data"].(map[string]interface{})["status"])
+	assert.Equal(t, "Changed my mind", responseBody["data"].(map[string]interface{})["cancel_reason"])
+
+	var restocked models.Product
+	db.Take(&restocked, "id = ?", product.ID)
+	assert.Equal(t, product.Stock+8, restocked.Stock)
+}
+
+func TestCancelPaidOrderRefunds(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, _ := createPaidOrder(user, product, 8, db)
+
+	requestBody := toRequestBody(models.OrderCancel{Reason: "Found it cheaper"})
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+order.ID+"/cancel", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 200, response.StatusCode)
+
+	var refunds []models.Refund
+	db.Where("order_id = ?", order.ID).Find(&refunds)
+	assert.Equal(t, 1, len(refunds))
+	assert.Equal(t, order.TotalPrice, refunds[0].Amount)
+}
+
+func TestCancelOrderConcurrently(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, _ := createPaidOrder(user, product, 8, db)
+
+	var before models.Product
+	db.Where("id = ?", product.ID).Take(&before)
+
+	var wait sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+order.ID+"/cancel", toRequestBody(models.OrderCancel{Reason: "Found it cheaper"}))
+			request.Header.Add("Content-Type", "application/json")
+			request.Header.Add("Authorization", "Bearer "+token)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			codes[i] = recorder.Code
+		}(i)
+	}
+	wait.Wait()
+
+	cancelled := 0
+	for _, code := range codes {
+		if code == 200 {
+			cancelled++
+		}
+	}
+	assert.Equal(t, 1, cancelled)
+
+	// Refunded once and restocked once.
+	var refunds int64
+	db.Model(&models.Refund{}).Where("order_id = ?", order.ID).Count(&refunds)
+	assert.Equal(t, int64(1), refunds)
+
+	var after models.Product
+	db.Where("id = ?", product.ID).Take(&after)
+	assert.Equal(t, before.Stock+8, after.Stock)
+}
+
+func TestCancelOrderNotOwner(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+
+	user := createUser(mockUser(success), db)
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order := createOrder(mockOrder(success, product.ID), user, product, db)
+
+	requestBody := toRequestBody(models.OrderCancel{Reason: "Not my order"})
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+order.ID+"/cancel", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestCancelOrderUnknown(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateOrder(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	requestBody := toRequestBody(models.OrderCancel{Reason: "Changed my mind"})
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+uuid.New().String()+"/cancel", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestConfirmPaymentSuccess(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+	truncateOutbox(db)
+
+	user := createUser(mockUser(success), db)
+	userToken, _ := tokensTest.Issue(user.ID, "")
+	staff := createStaff(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order := createOrder(mockOrder(success, product.ID), user, product, db)
+	createOrderItem(order, product, 8, db)
+
+	payment := models.OrderPayment{Reference: "TRX-0001"}
+
+	// Customers cannot mark their own orders paid.
+	response := postAuthorized(router, "/orders/"+order.ID+"/pay", userToken, payment, nil)
+	assert.Equal(t, 403, response.StatusCode)
+
+	var paid models.OrderResponse
+	response = postAuthorized(router, "/orders/"+order.ID+"/pay", staffToken, payment, &paid)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, consts.OrderPaymentStatusPaid, paid.Status)
+	assert.Equal(t, true, paid.IsPaid)
+	assert.Equal(t, "TRX-0001", paid.PaymentRef)
+	assert.NotEqual(t, nil, paid.PaidAt)
+
+	var events int64
+	db.Model(&models.OutboxEvent{}).Where("type = ? AND aggregate_id = ?", consts.EventOrderPaid, order.ID).Count(&events)
+	assert.Equal(t, int64(1), events)
+
+	response = postAuthorized(router, "/orders/"+order.ID+"/pay", staffToken, payment, nil)
+	assert.Equal(t, 400, response.StatusCode)
+}
+
+func TestRunOrderExpirySuccess(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order := createOrder(mockOrder(success, product.ID), user, product, db)
+	createOrderItem(order, product, 8, db)
+	db.Model(&models.Order{}).Where("id = ?", order.ID).UpdateColumn("created_at", time.Now().Add(-2*time.Hour))
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/admin/jobs/order-expiry/run", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 200, response.StatusCode)
+
+	body, _ := io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	assert.Equal(t, 1, int(responseBody["data"].(map[string]interface{})["scanned"].(float64)))
+	assert.Equal(t, 1, int(responseBody["data"].(map[string]interface{})["cancelled"].(float64)))
+
+	var expired models.Order
+	db.Take(&expired, "id = ?", order.ID)
+	assert.Equal(t, consts.OrderPaymentStatusCancel, expired.Status)
+
+	// Another replica reports the run it did not do itself.
+	var stats models.OrderExpiryStats
+	response = getAuthorized(routerTest(db), "/admin/jobs/order-expiry", token, &stats)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 1, stats.Runs)
+	assert.Equal(t, 1, stats.TotalCancelled)
+	assert.NotEqual(t, nil, stats.LastRun)
+	assert.Equal(t, 1, stats.LastRun.Scanned)
+
+	response = postAuthorized(router, "/admin/jobs/order-expiry/run", token, nil, nil)
+	assert.Equal(t, 200, response.StatusCode)
+	response = getAuthorized(router, "/admin/jobs/order-expiry", token, &stats)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 2, stats.Runs)
+	assert.Equal(t, 1, stats.TotalCancelled)
+	assert.Equal(t, 0, stats.LastRun.Scanned)
+}
+
+func TestRunOrderExpiryForbidden(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/admin/jobs/order-expiry/run", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 403, response.StatusCode)
+}
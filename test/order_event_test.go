@@ -0,0 +1,241 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/realtime"
+	"zen-test/app/web/controllers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func hubTest(db *gorm.DB) *realtime.Hub {
+	return realtime.NewHub(db, repositories.NewOutboxRepository(), realtime.HubConfig{PollInterval: time.Second, BatchSize: 100, BufferSize: 16, Overlap: 5 * time.Second})
+}
+
+func createPublishedEvent(db *gorm.DB, eventType string, order models.Order, publishedAt time.Time) models.OutboxEvent {
+	event := models.OutboxEvent{
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		AggregateType: "order",
+		AggregateID:   order.ID,
+		UserID:        order.UserID,
+		Payload:       "{}",
+		PublishedAt:   &publishedAt,
+	}
+
+	err := db.Create(&event).Error
+	if err != nil {
+		panic(err)
+	}
+	return event
+}
+
+// readEventType reads the stream until the next event and returns its type.
+func readEventType(reader *bufio.Reader) string {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return ""
+		}
+		if strings.HasPrefix(line, "event: ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		}
+	}
+}
+
+func TestOrderEventStreamPushesShipment(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateOutbox(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	staff := createStaff(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, _ := createPaidOrder(user, product, 2, db)
+
+	hub := hubTest(db)
+	orderEventService := services.NewOrderEventService(repositories.NewOrderRepository(), repositories.NewUserRepository(), repositories.NewOutboxRepository(), db)
+	streamRouter := mux.NewRouter()
+	streamRouter.HandleFunc("/orders/{orderId}/events", controllers.NewOrderEventController(orderEventService, hub, time.Second).StreamOrder)
+	server := httptest.NewServer(authMiddlewareTest(db)(streamRouter))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	streamRequest, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/orders/"+order.ID+"/events", nil)
+	streamRequest.Header.Add("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(streamRequest)
+	assert.Equal(t, nil, err)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(response.Body)
+	line, _ := reader.ReadString('\n')
+	assert.Equal(t, true, strings.HasPrefix(line, "retry: "))
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+order.ID+"/ship", toRequestBody(models.OrderShip{Carrier: "JNE", TrackingNumber: "JNE123"}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+staffToken)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	_, err = relayTest(db, events.NewBus()).RelayOnce(context.Background())
+	assert.Equal(t, nil, err)
+	broadcast, err := hub.PollOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, broadcast)
+
+	assert.Equal(t, consts.EventOrderShipped, readEventType(reader))
+}
+
+func TestOrderEventStreamResumesAfterLastEventID(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateOutbox(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, _ := createPaidOrder(user, product, 2, db)
+
+	publishedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	received := createPublishedEvent(db, consts.EventOrderPaid, order, publishedAt)
+	missed := createPublishedEvent(db, consts.EventOrderShipped, order, publishedAt.Add(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/orders/events", nil).WithContext(ctx)
+	request.Header.Add("Authorization", "Bearer "+token)
+	request.Header.Add("Last-Event-ID", received.ID)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	body := recorder.Body.String()
+	assert.Equal(t, true, strings.Contains(body, "id: "+missed.ID+"\nevent: "+consts.EventOrderShipped))
+	assert.Equal(t, false, strings.Contains(body, "id: "+received.ID))
+}
+
+func TestHubSendsEventsPublishedOutOfOrder(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateOutbox(db)
+
+	user := createUser(mockUser(success), db)
+	product := createProduct(mockProduct(success), db)
+	order, _ := createPaidOrder(user, product, 2, db)
+
+	hub := hubTest(db)
+	subscription := hub.Subscribe(func(events.Event) bool { return true })
+	defer subscription.Close()
+
+	now := time.Now()
+	shipped := createPublishedEvent(db, consts.EventOrderShipped, order, now)
+	broadcast, err := hub.PollOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, broadcast)
+	assert.Equal(t, shipped.ID, (<-subscription.Events()).ID)
+
+	// A replica whose clock runs behind publishes an event stamped before
+	// the one already sent.
+	paid := createPublishedEvent(db, consts.EventOrderPaid, order, now.Add(-2*time.Second))
+	broadcast, err = hub.PollOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, broadcast)
+	assert.Equal(t, paid.ID, (<-subscription.Events()).ID)
+
+	broadcast, err = hub.PollOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, broadcast)
+}
+
+func TestOrderEventStreamOtherUsersOrder(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+
+	owner := createUser(mockUser(success), db)
+	product := createProduct(mockProduct(success), db)
+	order, _ := createPaidOrder(owner, product, 2, db)
+
+	other := mockUser(success)
+	other.Email = "other@gmail.com"
+	otherUser := createUser(other, db)
+	token, _ := tokensTest.Issue(otherUser.ID, "")
+
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/orders/"+order.ID+"/events", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 404, recorder.Result().StatusCode)
+}
+
+func TestOrderEventStreamUnknownOrder(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateOrder(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/orders/"+uuid.New().String()+"/events", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 404, recorder.Result().StatusCode)
+}
+
+func TestShipUnknownOrder(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateOrder(db)
+
+	staff := createStaff(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders/"+uuid.New().String()+"/ship", toRequestBody(models.OrderShip{Carrier: "JNE", TrackingNumber: "JNE123"}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+staffToken)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 404, recorder.Result().StatusCode)
+}
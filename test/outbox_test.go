@@ -0,0 +1,184 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/jobs"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func relayTest(db *gorm.DB, bus *events.Bus) *events.Relay {
+	locker := jobs.NewLeaseLocker(db, "relay-test", time.Minute)
+	return events.NewRelay(db, bus, repositories.NewOutboxRepository(), locker, events.RelayConfig{
+		Interval:    time.Second,
+		BatchSize:   100,
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  time.Hour,
+	})
+}
+
+func truncateOutbox(db *gorm.DB) {
+	db.Exec("TRUNCATE outbox_events")
+}
+
+func TestCreateOrderWritesOutboxEvents(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateOutbox(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/orders", toRequestBody(mockOrder(success, product.ID)))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	var outboxEvents []models.OutboxEvent
+	db.Order("created_at").Find(&outboxEvents)
+	assert.Equal(t, 2, len(outboxEvents))
+
+	types := map[string]models.OutboxEvent{}
+	for _, outboxEvent := range outboxEvents {
+		types[outboxEvent.Type] = outboxEvent
+	}
+	assert.Equal(t, user.ID, types[consts.EventOrderCreated].UserID)
+	assert.Equal(t, product.ID, types[consts.EventStockChanged].AggregateID)
+}
+
+func TestRelayPublishesOutboxEvents(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateOutbox(db)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/signup", toRequestBody(mockUser(success)))
+	request.Header.Add("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	bus := events.NewBus()
+	var received []events.Event
+	bus.Subscribe(consts.EventUserRegistered, "received", func(ctx context.Context, event events.Event) error {
+		received = append(received, event)
+		return nil
+	})
+
+	relay := relayTest(db, bus)
+	published, err := relay.RelayOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, consts.EventUserRegistered, received[0].Type)
+
+	published, _ = relay.RelayOnce(context.Background())
+	assert.Equal(t, 0, published)
+}
+
+func TestRelayRetriesFailedEvents(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateOutbox(db)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/signup", toRequestBody(mockUser(success)))
+	request.Header.Add("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	bus := events.NewBus()
+	bus.Subscribe(events.AllEvents, "warehouse", func(ctx context.Context, event events.Event) error {
+		return errors.New("warehouse system unavailable")
+	})
+
+	relay := relayTest(db, bus)
+	published, err := relay.RelayOnce(context.Background())
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 0, published)
+
+	var pending models.OutboxEvent
+	db.Take(&pending)
+	assert.Equal(t, 1, pending.Attempts)
+	assert.Equal(t, true, pending.PublishedAt == nil)
+}
+
+func TestRelayParksEventsThatKeepFailing(t *testing.T) {
+	db := dbTest()
+	truncateOutbox(db)
+
+	outboxRepo := repositories.NewOutboxRepository()
+	now := time.Now()
+	failing, _ := outboxRepo.SaveEvent(context.Background(), db, models.OutboxEvent{
+		ID: uuid.New().String(), Type: consts.EventOrderCreated, AggregateType: "order", AggregateID: "order-1", CreatedAt: now,
+	})
+	later, _ := outboxRepo.SaveEvent(context.Background(), db, models.OutboxEvent{
+		ID: uuid.New().String(), Type: consts.EventOrderCancelled, AggregateType: "order", AggregateID: "order-1", CreatedAt: now.Add(time.Millisecond),
+	})
+	other, _ := outboxRepo.SaveEvent(context.Background(), db, models.OutboxEvent{
+		ID: uuid.New().String(), Type: consts.EventOrderCreated, AggregateType: "order", AggregateID: "order-2", CreatedAt: now.Add(2 * time.Millisecond),
+	})
+
+	bus := events.NewBus()
+	audited := map[string]int{}
+	bus.Subscribe(events.AllEvents, "audit", func(ctx context.Context, event events.Event) error {
+		audited[event.ID]++
+		return nil
+	})
+	bus.Subscribe(events.AllEvents, "warehouse", func(ctx context.Context, event events.Event) error {
+		if event.ID == failing.ID {
+			return errors.New("warehouse system unavailable")
+		}
+		return nil
+	})
+
+	// The other order goes out; the later event of the failing order waits
+	// behind it.
+	relay := relayTest(db, bus)
+	published, err := relay.RelayOnce(context.Background())
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 1, audited[other.ID])
+	assert.Equal(t, 0, audited[later.ID])
+
+	// Backing off, nothing is due.
+	published, err = relay.RelayOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, published)
+
+	for i := 0; i < 2; i++ {
+		db.Model(&models.OutboxEvent{}).Where("id = ?", failing.ID).Update("next_attempt_at", time.Now())
+		relay.RelayOnce(context.Background())
+	}
+
+	var parked models.OutboxEvent
+	db.Where("id = ?", failing.ID).Take(&parked)
+	assert.Equal(t, 3, parked.Attempts)
+	assert.NotEqual(t, nil, parked.DeadAt)
+	assert.Equal(t, true, parked.PublishedAt == nil)
+
+	// The subscriber that took it the first time never saw it again.
+	assert.Equal(t, 1, audited[failing.ID])
+
+	published, err = relay.RelayOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 1, audited[later.ID])
+}
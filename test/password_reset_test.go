@@ -0,0 +1,129 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var linkTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+func passwordResetConfigTest() services.PasswordResetConfig {
+	return services.PasswordResetConfig{
+		TokenTTL: 30 * time.Minute,
+		Throttle: time.Minute,
+		URL:      "http://localhost:3000/reset-password",
+	}
+}
+
+func truncatePasswordReset(db *gorm.DB) {
+	db.Exec("TRUNCATE password_reset_tokens")
+}
+
+func postJSON(router http.Handler, path string, body interface{}) *http.Response {
+	request := httptest.NewRequest(http.MethodPost, baseURL+path, toRequestBody(body))
+	request.Header.Add("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+// forgotPassword requests a reset link and returns the token it carries.
+func forgotPassword(t *testing.T, db *gorm.DB, router http.Handler, email string) string {
+	_, queue, notifier := notificationTest(db)
+
+	response := postJSON(router, "/users/password/forgot", models.PasswordForgot{Email: email})
+	assert.Equal(t, 200, response.StatusCode)
+	drainQueue(t, queue)
+
+	messages := notifier.Messages()
+	if len(messages) == 0 {
+		return ""
+	}
+	match := linkTokenPattern.FindStringSubmatch(messages[0].Text)
+	assert.Equal(t, 2, len(match))
+	return match[1]
+}
+
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncatePasswordReset(db)
+	truncateJob(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+
+	token := forgotPassword(t, db, router, user.Email)
+	assert.NotEqual(t, "", token)
+
+	var stored models.PasswordResetToken
+	db.Where("user_id = ?", user.ID).Take(&stored)
+	assert.Equal(t, helpers.HashToken(token), stored.TokenHash)
+
+	// An unknown email gets the same answer and no email.
+	assert.Equal(t, "", forgotPassword(t, db, router, "nobody@gmail.com"))
+
+	// A second request right away is throttled.
+	assert.Equal(t, "", forgotPassword(t, db, router, user.Email))
+}
+
+func TestResetPasswordRevokesSessions(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	truncateRefreshToken(db)
+	truncatePasswordReset(db)
+	truncateJob(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+
+	accessToken, refreshToken := loginTokens(router)
+	token := forgotPassword(t, db, router, user.Email)
+
+	response := postJSON(router, "/users/password/reset", models.PasswordReset{Token: token, Password: "brand-new"})
+	assert.Equal(t, 200, response.StatusCode)
+
+	// The token works once.
+	response = postJSON(router, "/users/password/reset", models.PasswordReset{Token: token, Password: "another-one"})
+	assert.Equal(t, 400, response.StatusCode)
+
+	response, _ = findSessions(router, accessToken)
+	assert.Equal(t, 401, response.StatusCode)
+	response, _ = refreshTokens(router, refreshToken)
+	assert.Equal(t, 401, response.StatusCode)
+
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: "brand-new"})
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestResetPasswordRejectsExpiredToken(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncatePasswordReset(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+
+	token := helpers.RandomToken(32)
+	db.Create(&models.PasswordResetToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+
+	response := postJSON(router, "/users/password/reset", models.PasswordReset{Token: token, Password: "brand-new"})
+	assert.Equal(t, 400, response.StatusCode)
+
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: mockUser(success).Password})
+	assert.Equal(t, 200, response.StatusCode)
+}
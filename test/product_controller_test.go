@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-	"zen-test/app/auth"
 	"zen-test/app/web/models"
 
 	"github.com/go-playground/assert/v2"
@@ -96,10 +95,9 @@ func TestCreateProductSuccess(t *testing.T) {
 	truncateUser(db)
 	truncateProduct(db)
 
-	data := mockUser(success) // make sure add success as parameter
-	user := createUser(data, db)
+	user := createStaff(db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 
 	requestBody := toRequestBody(mockProduct(success))
 	request := httptest.NewRequest(http.MethodPost, baseURL+"/products", requestBody)
@@ -128,10 +126,9 @@ func TestCreateProductFail(t *testing.T) {
 	truncateUser(db)
 	truncateProduct(db)
 
-	data := mockUser(success) // make sure add success as parameter
-	user := createUser(data, db)
+	user := createStaff(db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 
 	requestBody := toRequestBody(mockProduct(failed))
 	request := httptest.NewRequest(http.MethodPost, baseURL+"/products", requestBody)
@@ -159,10 +156,9 @@ func TestUpdateProductSuccess(t *testing.T) {
 	truncateUser(db)
 	truncateProduct(db)
 
-	data := mockUser(success) // make sure add success as parameter
-	user := createUser(data, db)
+	user := createStaff(db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 
 	product := createProduct(mockProduct(success), db) // make sure add success as parameter
 	productId := product.ID
@@ -195,10 +191,9 @@ func TestDeleteProductSuccess(t *testing.T) {
 	truncateUser(db)
 	truncateProduct(db)
 
-	data := mockUser(success) // make sure add success as parameter
-	user := createUser(data, db)
+	user := createStaff(db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 
 	product := createProduct(mockProduct(success), db) // make sure add success as parameter
 	productId := product.ID
@@ -232,7 +227,7 @@ func TestFindAllProductSuccess(t *testing.T) {
 	data := mockUser(success) // make sure add success as parameter
 	user := createUser(data, db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 
 	createProduct(mockProduct(success), db)
 
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestPatchMeChangesOnlySentFields(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	other := createStaff(db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	address := "Surabaya"
+	request := httptest.NewRequest(http.MethodPatch, baseURL+"/users/me", toRequestBody(models.UserPatch{Address: &address}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+	var patched models.UserResponse
+	response := serveDecoded(router, request, &patched)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "Surabaya", patched.Address)
+	assert.Equal(t, user.Phone, patched.Phone)
+	assert.Equal(t, user.Name, patched.Name)
+
+	request = httptest.NewRequest(http.MethodGet, baseURL+"/users/me", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+	var me models.UserResponse
+	response = serveDecoded(router, request, &me)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "Surabaya", me.Address)
+
+	// The path names whose account is updated, and it has to be the caller's.
+	request = httptest.NewRequest(http.MethodPut, baseURL+"/users/"+other.ID, toRequestBody(models.UserUpdate{Phone: "08123456789", Address: "Jakarta"}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 403, response.StatusCode)
+
+	request = httptest.NewRequest(http.MethodPut, baseURL+"/users/"+user.ID, toRequestBody(models.UserUpdate{Phone: "08123456789", Address: "Jakarta"}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+	var updated models.UserResponse
+	response = serveDecoded(router, request, &updated)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "08123456789", updated.Phone)
+}
+
+func TestChangePasswordSignsOutOtherSessions(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	truncateRefreshToken(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+
+	accessToken, _ := loginTokens(router)
+	otherAccessToken, otherRefreshToken := loginTokens(router)
+
+	response := postAuthorized(router, "/users/me/password", accessToken, models.PasswordChange{CurrentPassword: "wrong-one", Password: "brand-new"}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response = postAuthorized(router, "/users/me/password", accessToken, models.PasswordChange{CurrentPassword: mockUser(success).Password, Password: "brand-new"}, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	response, _ = findSessions(router, accessToken)
+	assert.Equal(t, 200, response.StatusCode)
+	response, _ = findSessions(router, otherAccessToken)
+	assert.Equal(t, 401, response.StatusCode)
+	response, _ = refreshTokens(router, otherRefreshToken)
+	assert.Equal(t, 401, response.StatusCode)
+
+	response = postJSON(router, "/users/login", models.UserLogin{Email: user.Email, Password: "brand-new"})
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestChangeEmailAfterVerification(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateEmailVerification(db)
+	truncateJob(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	createStaff(db)
+	accessToken, _ := loginTokens(router)
+
+	response := postAuthorized(router, "/users/me/email", accessToken, models.EmailChange{Email: "staff@gmail.com", Password: mockUser(success).Password}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response = postAuthorized(router, "/users/me/email", accessToken, models.EmailChange{Email: "budiman@gmail.com", Password: "wrong-one"}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	_, queue, notifier := notificationTest(db)
+	response = postAuthorized(router, "/users/me/email", accessToken, models.EmailChange{Email: "budiman@gmail.com", Password: mockUser(success).Password}, nil)
+	assert.Equal(t, 200, response.StatusCode)
+	drainQueue(t, queue)
+
+	messages := notifier.Messages()
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "budiman@gmail.com", messages[0].To)
+
+	// Nothing changes until the link is opened.
+	var stored models.User
+	db.Where("id = ?", user.ID).Take(&stored)
+	assert.Equal(t, user.Email, stored.Email)
+
+	match := linkTokenPattern.FindStringSubmatch(messages[0].Text)
+	assert.Equal(t, 2, len(match))
+
+	var verified models.UserResponse
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/email/verify", toRequestBody(models.EmailVerify{Token: match[1]}))
+	request.Header.Add("Content-Type", "application/json")
+	response = serveDecoded(router, request, &verified)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "budiman@gmail.com", verified.Email)
+	assert.Equal(t, true, verified.EmailVerified)
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+func truncateRefreshToken(db *gorm.DB) {
+	db.Exec("TRUNCATE refresh_tokens")
+}
+
+// loginTokens logs the mock user in and returns its access and refresh token.
+func loginTokens(router http.Handler) (string, string) {
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/login", toRequestBody(login(success)))
+	request.Header.Add("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var responseBody struct {
+		Data models.UserLoginResponse `json:"data"`
+	}
+	body, _ := io.ReadAll(recorder.Result().Body)
+	json.Unmarshal(body, &responseBody)
+
+	return responseBody.Data.Token, responseBody.Data.RefreshToken
+}
+
+func refreshTokens(router http.Handler, refreshToken string) (*http.Response, models.TokenResponse) {
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/refresh-token", toRequestBody(models.RefreshTokenRequest{RefreshToken: refreshToken}))
+	request.Header.Add("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var responseBody struct {
+		Data models.TokenResponse `json:"data"`
+	}
+	body, _ := io.ReadAll(recorder.Result().Body)
+	json.Unmarshal(body, &responseBody)
+
+	return recorder.Result(), responseBody.Data
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateRefreshToken(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+
+	_, refreshToken := loginTokens(router)
+
+	response, tokens := refreshTokens(router, refreshToken)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.NotEqual(t, "", tokens.AccessToken)
+	assert.NotEqual(t, refreshToken, tokens.RefreshToken)
+
+	response, _ = refreshTokens(router, tokens.RefreshToken)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateRefreshToken(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+
+	_, stolen := loginTokens(router)
+	_, rotated := refreshTokens(router, stolen)
+
+	// The stolen token is replayed after the legitimate client rotated it.
+	response, _ := refreshTokens(router, stolen)
+	assert.Equal(t, 401, response.StatusCode)
+
+	// Reuse revoked the whole family, including the legitimate token.
+	response, _ = refreshTokens(router, rotated.RefreshToken)
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateRefreshToken(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+
+	accessToken, refreshToken := loginTokens(router)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/logout", toRequestBody(models.RefreshTokenRequest{RefreshToken: refreshToken}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	response, _ := refreshTokens(router, refreshToken)
+	assert.Equal(t, 401, response.StatusCode)
+}
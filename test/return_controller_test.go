@@ -0,0 +1,291 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"zen-test/app/consts"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func createStaff(db *gorm.DB) models.User {
+	hashPassword, _ := helpers.MakePassword("example")
+	staff := models.User{
+		ID:       uuid.New().String(),
+		Name:     "Staff Gudang",
+		Email:    "staff@gmail.com",
+		Phone:    "08811212113",
+		Address:  "Bandung",
+		Password: hashPassword,
+		Role:     consts.UserRoleStaff,
+	}
+
+	err := db.Model(&models.User{}).Create(&staff).Error
+	helpers.PanicIfError(err)
+
+	return staff
+}
+
+func createPaidOrder(user models.User, product models.Product, quantity uint32, db *gorm.DB) (models.Order, models.OrderItem) {
+	order := createOrder(models.OrderItemCreateUpdate{ProductID: product.ID, Quantity: quantity}, user, product, db)
+	order.IsPaid = true
+	order.Status = consts.OrderPaymentStatusPaid
+	err := db.Save(&order).Error
+	helpers.PanicIfError(err)
+
+	return order, createOrderItem(order, product, quantity, db)
+}
+
+func mockReturn(conditional string, orderId string, orderItemId string) models.ReturnRequestCreate {
+	var returnRequest models.ReturnRequestCreate
+
+	switch conditional {
+	case "success":
+		returnRequest = models.ReturnRequestCreate{
+			OrderID: orderId,
+			Reason:  "Screen is broken",
+			Items:   []models.ReturnItemDto{{OrderItemID: orderItemId, Quantity: 2}},
+		}
+
+	case "failed": // trigger quantity validation, more than purchased
+		returnRequest = models.ReturnRequestCreate{
+			OrderID: orderId,
+			Reason:  "Screen is broken",
+			Items:   []models.ReturnItemDto{{OrderItemID: orderItemId, Quantity: 100}},
+		}
+	default:
+		return models.ReturnRequestCreate{}
+	}
+	return returnRequest
+}
+
+func truncateReturn(db *gorm.DB) {
+	db.Exec("TRUNCATE return_items")
+	db.Exec("TRUNCATE return_requests")
+	db.Exec("TRUNCATE refunds")
+	db.Exec("TRUNCATE order_items")
+}
+
+func TestCreateReturnSuccess(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, orderItem := createPaidOrder(user, product, 8, db)
+
+	requestBody := toRequestBody(mockReturn(success, order.ID, orderItem.ID))
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/returns", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 200, response.StatusCode)
+
+	body, _ := io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	assert.Equal(t, 200, int(responseBody["code"].(float64)))
+	assert.Equal(t, statusOk, responseBody["status"])
+	assert.Equal(t, consts.ReturnStatusRequested, responseBody["data"].(map[string]interface{})["status"])
+}
+
+func TestCreateReturnQuantityExceeded(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, orderItem := createPaidOrder(user, product, 8, db)
+
+	requestBody := toRequestBody(mockReturn(failed, order.ID, orderItem.ID))
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/returns", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 400, response.StatusCode)
+
+	body, _ := io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	assert.Equal(t, 400, int(responseBody["code"].(float64)))
+	assert.Equal(t, statusBadRequest, responseBody["status"])
+}
+
+func TestCreateReturnUnknownOrder(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	requestBody := toRequestBody(mockReturn(success, uuid.New().String(), uuid.New().String()))
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/returns", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestApproveReturnPartialRefund(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	userToken, _ := tokensTest.Issue(user.ID, "")
+	staff := createStaff(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, orderItem := createPaidOrder(user, product, 8, db)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/returns", toRequestBody(mockReturn(success, order.ID, orderItem.ID)))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+userToken)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	body, _ := io.ReadAll(recorder.Result().Body)
+	var createdBody map[string]interface{}
+	json.Unmarshal(body, &createdBody)
+	returnId := createdBody["data"].(map[string]interface{})["id"].(string)
+
+	requestBody := toRequestBody(models.ReturnRequestReview{Note: "Approved", RefundAmount: 1000})
+	request = httptest.NewRequest(http.MethodPost, baseURL+"/returns/"+returnId+"/approve", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+staffToken)
+
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 200, response.StatusCode)
+
+	body, _ = io.ReadAll(response.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	assert.Equal(t, consts.ReturnStatusApproved, responseBody["data"].(map[string]interface{})["status"])
+
+	var refundedOrder models.Order
+	db.Take(&refundedOrder, "id = ?", order.ID)
+	assert.Equal(t, consts.OrderPaymentStatusPartiallyRefunded, refundedOrder.Status)
+	assert.Equal(t, float64(1000), refundedOrder.RefundedAmount)
+}
+
+func TestApproveReturnForbidden(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	requestBody := toRequestBody(models.ReturnRequestReview{Note: "Approved"})
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/returns/"+uuid.New().String()+"/approve", requestBody)
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, 403, response.StatusCode)
+}
+
+func TestApproveReturnConcurrently(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	truncateReturn(db)
+
+	user := createUser(mockUser(success), db)
+	userToken, _ := tokensTest.Issue(user.ID, "")
+	staff := createStaff(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	product := createProduct(mockProduct(success), db)
+	order, orderItem := createPaidOrder(user, product, 8, db)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/returns", toRequestBody(mockReturn(success, order.ID, orderItem.ID)))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+userToken)
+	var created models.ReturnRequestResponse
+	response := serveDecoded(router, request, &created)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var wait sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			request := httptest.NewRequest(http.MethodPost, baseURL+"/returns/"+created.ID+"/approve", toRequestBody(models.ReturnRequestReview{Note: "Approved"}))
+			request.Header.Add("Content-Type", "application/json")
+			request.Header.Add("Authorization", "Bearer "+staffToken)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			codes[i] = recorder.Code
+		}(i)
+	}
+	wait.Wait()
+
+	approved := 0
+	for _, code := range codes {
+		if code == 200 {
+			approved++
+		}
+	}
+	assert.Equal(t, 1, approved)
+
+	var refunds int64
+	db.Model(&models.Refund{}).Where("order_id = ?", order.ID).Count(&refunds)
+	assert.Equal(t, int64(1), refunds)
+}
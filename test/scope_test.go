@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zen-test/app/auth"
+	"zen-test/app/consts"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+// roleScopesTest resolves the scopes of a user from their role and whether
+// their account is active, for routers built without the user service.
+type roleScopesTest struct {
+	db *gorm.DB
+}
+
+func (r roleScopesTest) UserScopes(ctx context.Context, userId string) []string {
+	var user models.User
+	if err := r.db.Where("id = ?", userId).Take(&user).Error; err != nil {
+		return nil
+	}
+	return auth.RoleScopes[user.Role]
+}
+
+func (r roleScopesTest) AccountActive(ctx context.Context, userId string) bool {
+	var user models.User
+	err := r.db.Where("id = ?", userId).Take(&user).Error
+	return err != nil || user.DisabledAt == nil
+}
+
+func TestCustomerMissingScope(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateProduct(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/products", toRequestBody(mockProduct(success)))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	var message string
+	response := serveDecoded(router, request, &message)
+	assert.Equal(t, 403, response.StatusCode)
+	assert.Equal(t, "missing scope "+consts.ScopeProductsWrite, message)
+
+	request = httptest.NewRequest(http.MethodGet, baseURL+"/products", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestAPIKeyLimitedToItsScopes(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateAPIKey(db)
+	truncateOrder(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+	accessToken, _ := loginTokens(router)
+
+	apiKey := createAPIKey(t, router, accessToken, models.APIKeyCreate{Name: "ERP", Scopes: []string{consts.ScopeOrdersReadAll}})
+
+	// A customer's key asking for every order only sees the customer's own.
+	response := withAPIKey(router, http.MethodGet, "/orders", apiKey.Key)
+	assert.Equal(t, 200, response.StatusCode)
+
+	response = withAPIKey(router, http.MethodGet, "/returns", apiKey.Key)
+	assert.Equal(t, 403, response.StatusCode)
+
+	// Routes without a scope, such as account settings, refuse keys.
+	response = withAPIKey(router, http.MethodGet, "/users/me/sessions", apiKey.Key)
+	assert.Equal(t, 403, response.StatusCode)
+}
+
+func TestScopesIntersect(t *testing.T) {
+	granted := auth.Intersect(auth.RoleScopes[consts.UserRoleCustomer], []string{consts.ScopeOrdersReadAll, consts.ScopeWebhooksRead})
+	assert.Equal(t, []string{consts.ScopeOrdersReadOwn}, granted)
+
+	assert.Equal(t, true, auth.Satisfies([]string{consts.ScopeReturnsWriteAll}, consts.ScopeReturnsWriteOwn))
+	assert.Equal(t, false, auth.Satisfies([]string{consts.ScopeReturnsWriteOwn}, consts.ScopeReturnsWriteAll))
+}
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"zen-test/app/middleware"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+func authMiddlewareTest(db *gorm.DB) func(http.Handler) http.Handler {
+	return middleware.AuthMiddleware(tokensTest, services.NewSessionService(repositories.NewSessionRepository(), repositories.NewRefreshTokenRepository(), repositories.NewUserRepository(), time.Minute, db), apiKeyServiceTest(db), roleScopesTest{db})
+}
+
+func truncateSession(db *gorm.DB) {
+	db.Exec("TRUNCATE sessions")
+}
+
+func findSessions(router http.Handler, accessToken string) (*http.Response, []models.SessionResponse) {
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/users/me/sessions", nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var responseBody struct {
+		Data []models.SessionResponse `json:"data"`
+	}
+	body, _ := io.ReadAll(recorder.Result().Body)
+	json.Unmarshal(body, &responseBody)
+
+	return recorder.Result(), responseBody.Data
+}
+
+func TestListSessions(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+
+	loginTokens(router)
+	accessToken, _ := loginTokens(router)
+
+	response, sessions := findSessions(router, accessToken)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 2, len(sessions))
+
+	current := 0
+	for _, session := range sessions {
+		if session.Current {
+			current++
+		}
+	}
+	assert.Equal(t, 1, current)
+}
+
+func TestRevokeSessionRejectsItsAccessToken(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	truncateRefreshToken(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+
+	lostAccessToken, lostRefreshToken := loginTokens(router)
+	accessToken, _ := loginTokens(router)
+
+	_, sessions := findSessions(router, lostAccessToken)
+	var lostSession models.SessionResponse
+	for _, session := range sessions {
+		if session.Current {
+			lostSession = session
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodDelete, baseURL+"/users/me/sessions/"+lostSession.ID, nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	response, _ := findSessions(router, lostAccessToken)
+	assert.Equal(t, 401, response.StatusCode)
+
+	response, _ = refreshTokens(router, lostRefreshToken)
+	assert.Equal(t, 401, response.StatusCode)
+
+	response, sessions = findSessions(router, accessToken)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 1, len(sessions))
+}
+
+func TestLogoutRejectsAccessToken(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+
+	accessToken, _ := loginTokens(router)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/logout", nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	response, _ := findSessions(router, accessToken)
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestSessionRecordsClientIP(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/users/login", toRequestBody(login(success)))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("X-Forwarded-For", strings.Repeat("not-an-ip", 10))
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	accessToken, _ := loginTokens(router)
+	response, sessions := findSessions(router, accessToken)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 2, len(sessions))
+	for _, session := range sessions {
+		assert.Equal(t, "192.0.2.1", session.IP)
+	}
+}
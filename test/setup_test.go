@@ -7,9 +7,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"time"
 	"zen-test/app/database"
 	"zen-test/app/helpers"
 	"zen-test/app/middleware"
+	"zen-test/app/payments"
 	"zen-test/app/web/controllers"
 	"zen-test/app/web/repositories"
 	"zen-test/app/web/router"
@@ -30,6 +32,7 @@ const (
 	update                    string = "update"
 	statusOk                  string = "Ok"
 	statusBadRequest          string = "Bad Request"
+	statusUnauthorized        string = "Unauthorized"
 	statusInternalServerError string = "Internal Server Error"
 )
 
@@ -75,24 +78,64 @@ func dbTest() *gorm.DB {
 }
 
 func routerTest(db *gorm.DB) http.Handler {
+	return routerWithPolicyTest(db, nil)
+}
+
+// routerWithPolicyTest is routerTest with routes that require a verified email.
+func routerWithPolicyTest(db *gorm.DB, verifiedEmailPolicy []string) http.Handler {
 	validate := validator.New()
 
 	userRepo := repositories.NewUserRepository()
 	productRepo := repositories.NewProductRepository()
 	orderRepo := repositories.NewOrderRepository()
 	imageRepo := repositories.NewImageRepository()
-
-	userService := services.NewUserService(userRepo, db, validate)
-	productservice := services.NewProductService(productRepo, imageRepo, db, validate)
-	orderService := services.NewOrderService(orderRepo, productRepo, userRepo, db, validate)
+	returnRepo := repositories.NewReturnRepository()
+	refundRepo := repositories.NewRefundRepository()
+	outboxRepo := repositories.NewOutboxRepository()
+	webhookRepo := repositories.NewWebhookRepository()
+	addressRepo := repositories.NewAddressRepository()
+
+	paymentGateway := payments.NewManualGateway()
+
+	refreshTokenRepo := repositories.NewRefreshTokenRepository()
+	sessionRepo := repositories.NewSessionRepository()
+
+	sessionService := services.NewSessionService(sessionRepo, refreshTokenRepo, userRepo, time.Minute, db)
+	emailVerificationService := services.NewEmailVerificationService(userRepo, repositories.NewEmailVerificationRepository(), queueTest(db), emailVerificationConfigTest(), db, validate)
+	twoFactorService := services.NewTwoFactorService(userRepo, repositories.NewTwoFactorRepository(), repositories.NewLoginChallengeRepository(), twoFactorConfigTest(), db, validate)
+	userService := services.NewUserService(userRepo, outboxRepo, refreshTokenRepo, sessionRepo, repositories.NewLoginAttemptRepository(), sessionService, emailVerificationService, twoFactorService, tokensTest, services.AuthConfig{RefreshTokenTTL: time.Hour, Throttle: loginThrottleConfigTest()}, db, validate)
+	oidcService := services.NewOIDCService(oidcProvidersTest(), userRepo, repositories.NewExternalIdentityRepository(), outboxRepo, userService, emailVerificationService, services.OIDCConfig{StateTTL: time.Minute}, db)
+	passwordResetService := services.NewPasswordResetService(userRepo, repositories.NewPasswordResetRepository(), refreshTokenRepo, sessionRepo, sessionService, queueTest(db), passwordResetConfigTest(), db, validate)
+	productservice := services.NewProductService(productRepo, imageRepo, outboxRepo, db, validate)
+	orderService := services.NewOrderService(orderRepo, productRepo, userRepo, addressRepo, refundRepo, outboxRepo, paymentGateway, services.OrderExpiryConfig{Window: time.Hour, Interval: time.Hour}, db, validate)
+	returnService := services.NewReturnService(returnRepo, orderRepo, productRepo, userRepo, refundRepo, outboxRepo, paymentGateway, db, validate)
+	orderEventService := services.NewOrderEventService(orderRepo, userRepo, outboxRepo, db)
+	dashboardService := services.NewDashboardService()
+	webhookService := services.NewWebhookService(webhookRepo, userRepo, queueTest(db), services.WebhookConfig{Timeout: 5 * time.Second}, db, validate)
 
 	userController := controllers.NewUserController(userService)
+	sessionController := controllers.NewSessionController(sessionService)
+	passwordResetController := controllers.NewPasswordResetController(passwordResetService)
+	emailVerificationController := controllers.NewEmailVerificationController(emailVerificationService)
+	twoFactorController := controllers.NewTwoFactorController(twoFactorService)
+	oidcController := controllers.NewOIDCController(oidcService)
+	apiKeyService := apiKeyServiceTest(db)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	addressController := controllers.NewAddressController(services.NewAddressService(addressRepo, db, validate))
+	accountController := controllers.NewAccountController(services.NewAccountService(userRepo, addressRepo, orderRepo, outboxRepo, webhookRepo, sessionService, db, validate))
 	productController := controllers.NewProductController(productservice)
 	orderController := controllers.NewOrderController(orderService)
+	orderEventController := controllers.NewOrderEventController(orderEventService, hubTest(db), time.Second)
+	returnController := controllers.NewReturnController(returnService)
+	webhookController := controllers.NewWebhookController(webhookService)
+	dashboardController := controllers.NewDashboardController(dashboardService, hubTest(db), dashboardConfigTest())
+	userAdminController := controllers.NewUserAdminController(services.NewUserAdminService(userRepo, orderRepo, sessionService, db, validate))
+	keyController := controllers.NewKeyController(services.NewKeyService(repositories.NewSigningKeyRepository(), keySetTest, keyConfigTest(), db))
 
-	go orderService.AutoCancelUnpaidOrders()
+	router := router.InitializeRouter(userController, sessionController, passwordResetController, emailVerificationController, twoFactorController, oidcController, apiKeyController, addressController, accountController, productController, orderController, orderEventController, returnController, webhookController, dashboardController, userAdminController, keyController, userService)
 
-	router := router.InitializeRouter(userController, productController, orderController)
+	router.Use(middleware.ResolveClientIP(trustedProxiesTest))
+	router.Use(middleware.RequireVerifiedEmail(verifiedEmailPolicy, emailVerificationService))
 
-	return middleware.AuthMiddleware(router)
+	return middleware.AuthMiddleware(tokensTest, sessionService, apiKeyService, userService)(router)
 }
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"zen-test/app/auth"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+func keyConfigTest() services.KeyConfig {
+	return services.KeyConfig{
+		Algorithm:        auth.AlgorithmEdDSA,
+		RotationInterval: time.Hour,
+		Overlap:          time.Hour,
+		CheckInterval:    time.Hour,
+		RefreshInterval:  time.Minute,
+		Secret:           "test-secret",
+	}
+}
+
+func truncateSigningKey(db *gorm.DB) {
+	db.Exec("TRUNCATE signing_keys")
+}
+
+func fetchJWKS(router http.Handler) (*http.Response, auth.JWKS) {
+	request := httptest.NewRequest(http.MethodGet, baseURL+"/.well-known/jwks.json", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var jwks auth.JWKS
+	body, _ := io.ReadAll(recorder.Result().Body)
+	json.Unmarshal(body, &jwks)
+
+	return recorder.Result(), jwks
+}
+
+func tokenKeyID(t *testing.T, tokenString string) string {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	assert.Equal(t, nil, err)
+
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestJWKSVerifiesIssuedTokens(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+
+	response, jwks := fetchJWKS(router)
+	assert.Equal(t, 200, response.StatusCode)
+
+	tokenString, err := tokensTest.Issue("user-1", "")
+	assert.Equal(t, nil, err)
+
+	kid := tokenKeyID(t, tokenString)
+	var published *auth.JWK
+	for i := range jwks.Keys {
+		if jwks.Keys[i].KeyID == kid {
+			published = &jwks.Keys[i]
+		}
+	}
+	assert.NotEqual(t, nil, published)
+	assert.Equal(t, "OKP", published.KeyType)
+
+	// A verifier that only knows the JWKS accepts our token.
+	x, err := base64.RawURLEncoding.DecodeString(published.X)
+	assert.Equal(t, nil, err)
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(x), nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, token.Valid)
+}
+
+func TestRejectUnexpectedSigningAlgorithm(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+
+	validToken, _ := tokensTest.Issue("user-1", "")
+	kid := tokenKeyID(t, validToken)
+	claims := registeredClaimsTest(time.Now())
+
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmacToken.Header["kid"] = kid
+	hmacString, _ := hmacToken.SignedString([]byte(""))
+
+	noneToken := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	noneToken.Header["kid"] = kid
+	noneString, _ := noneToken.SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	for _, tokenString := range []string{hmacString, noneString} {
+		response, _ := findSessions(router, tokenString)
+		assert.Equal(t, 401, response.StatusCode)
+	}
+
+	response, _ := findSessions(router, validToken)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestRotateSigningKeys(t *testing.T) {
+	ctx := context.Background()
+	db := dbTest()
+	truncateSigningKey(db)
+
+	keySet := auth.NewKeySet()
+	keyService := services.NewKeyService(repositories.NewSigningKeyRepository(), keySet, keyConfigTest(), db)
+	tokens := auth.NewJWT(keySet, tokenConfigTest())
+
+	assert.Equal(t, nil, keyService.Rotate(ctx))
+	assert.Equal(t, nil, keyService.Rotate(ctx))
+	assert.Equal(t, 1, len(keyService.JWKS().Keys))
+
+	oldToken, err := tokens.Issue("user-1", "")
+	assert.Equal(t, nil, err)
+	oldKid := tokenKeyID(t, oldToken)
+
+	// Age the key past its rotation interval.
+	db.Model(&models.SigningKey{}).Where("id = ?", oldKid).Update("activates_at", time.Now().Add(-2*time.Hour))
+	assert.Equal(t, nil, keyService.Rotate(ctx))
+	assert.Equal(t, 2, len(keyService.JWKS().Keys))
+
+	newToken, _ := tokens.Issue("user-1", "")
+	assert.NotEqual(t, oldKid, tokenKeyID(t, newToken))
+
+	// Tokens of the replaced key verify during the overlap window...
+	_, err = tokens.Verify(oldToken)
+	assert.Equal(t, nil, err)
+
+	var retired models.SigningKey
+	db.Where("id = ?", oldKid).Take(&retired)
+	assert.NotEqual(t, nil, retired.RetiresAt)
+
+	// ...and not once it has retired.
+	db.Model(&models.SigningKey{}).Where("id = ?", oldKid).Update("retires_at", time.Now().Add(-time.Minute))
+	assert.Equal(t, nil, keyService.Reload(ctx))
+
+	_, err = tokens.Verify(oldToken)
+	assert.NotEqual(t, nil, err)
+	_, err = tokens.Verify(newToken)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(keyService.JWKS().Keys))
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+	"testing"
+	"time"
+	"zen-test/app/auth"
+	"zen-test/app/helpers"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var keySetTest = newKeySetTest()
+
+var tokensTest = auth.NewJWT(keySetTest, tokenConfigTest())
+
+func newKeySetTest() *auth.KeySet {
+	key, err := auth.GenerateSigningKey(auth.AlgorithmEdDSA, time.Now())
+	helpers.PanicIfError(err)
+	return auth.NewKeySet(key)
+}
+
+func tokenConfigTest() auth.TokenConfig {
+	return auth.TokenConfig{
+		Issuer:   "zenstore-test",
+		Audience: "zenstore-api-test",
+		TTL:      time.Hour,
+		Leeway:   time.Minute,
+	}
+}
+
+// signClaims signs arbitrary claims with the test signing key.
+func signClaims(claims jwt.Claims) string {
+	key, _ := keySetTest.Signing(time.Now())
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
+	helpers.PanicIfError(err)
+	return tokenString
+}
+
+func registeredClaimsTest(now time.Time) jwt.RegisteredClaims {
+	config := tokenConfigTest()
+	return jwt.RegisteredClaims{
+		Issuer:    config.Issuer,
+		Subject:   "user-1",
+		Audience:  jwt.ClaimStrings{config.Audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        uuid.New().String(),
+	}
+}
+
+func TestIssueStandardClaims(t *testing.T) {
+	tokenString, err := tokensTest.Issue("user-1", "session-1")
+	assert.Equal(t, nil, err)
+
+	claims, err := tokensTest.Verify(tokenString)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "session-1", claims.SessionID)
+	assert.Equal(t, "zenstore-test", claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"zenstore-api-test"}, claims.Audience)
+	assert.NotEqual(t, "", claims.ID)
+	assert.NotEqual(t, nil, claims.IssuedAt)
+	assert.NotEqual(t, nil, claims.NotBefore)
+}
+
+func TestVerifyRejectsInvalidClaims(t *testing.T) {
+	now := time.Now()
+
+	wrongAudience := registeredClaimsTest(now)
+	wrongAudience.Audience = jwt.ClaimStrings{"another-service"}
+
+	wrongIssuer := registeredClaimsTest(now)
+	wrongIssuer.Issuer = "someone-else"
+
+	expired := registeredClaimsTest(now)
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute))
+
+	notYetValid := registeredClaimsTest(now)
+	notYetValid.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Minute))
+
+	missingSubject := registeredClaimsTest(now)
+	missingSubject.Subject = ""
+
+	missingID := registeredClaimsTest(now)
+	missingID.ID = ""
+
+	for _, claims := range []jwt.RegisteredClaims{wrongAudience, wrongIssuer, expired, notYetValid, missingSubject, missingID} {
+		_, err := tokensTest.Verify(signClaims(claims))
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+func TestVerifyToleratesClockSkew(t *testing.T) {
+	now := time.Now()
+
+	// Issued by a server whose clock runs 30 seconds ahead of ours.
+	ahead := registeredClaimsTest(now.Add(30 * time.Second))
+	_, err := tokensTest.Verify(signClaims(ahead))
+	assert.Equal(t, nil, err)
+
+	// Expired 30 seconds ago by our clock.
+	justExpired := registeredClaimsTest(now)
+	justExpired.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second))
+	_, err = tokensTest.Verify(signClaims(justExpired))
+	assert.Equal(t, nil, err)
+}
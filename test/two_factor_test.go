@@ -0,0 +1,235 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"zen-test/app/auth"
+	"zen-test/app/web/models"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+func twoFactorConfigTest() services.TwoFactorConfig {
+	return services.TwoFactorConfig{
+		Issuer:        "Zenstore",
+		Secret:        "test-secret",
+		ChallengeTTL:  5 * time.Minute,
+		MaxAttempts:   3,
+		RecoveryCodes: 4,
+	}
+}
+
+func truncateTwoFactor(db *gorm.DB) {
+	db.Exec("TRUNCATE two_factors")
+	db.Exec("TRUNCATE recovery_codes")
+	db.Exec("TRUNCATE login_challenges")
+}
+
+// postAuthorized posts body as the user of the access token and decodes the
+// data of the response into data.
+func postAuthorized(router http.Handler, path string, accessToken string, body interface{}, data interface{}) *http.Response {
+	request := httptest.NewRequest(http.MethodPost, baseURL+path, toRequestBody(body))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	return serveDecoded(router, request, data)
+}
+
+func serveDecoded(router http.Handler, request *http.Request, data interface{}) *http.Response {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	responseBody := struct {
+		Data interface{} `json:"data"`
+	}{Data: data}
+	raw, _ := io.ReadAll(recorder.Result().Body)
+	json.Unmarshal(raw, &responseBody)
+	return recorder.Result()
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(at))
+	assert.Equal(t, nil, err)
+	return code
+}
+
+// enableTwoFactor enrolls the logged in user and returns the TOTP secret and
+// the recovery codes.
+func enableTwoFactor(t *testing.T, router http.Handler) (string, []string) {
+	accessToken, _ := loginTokens(router)
+
+	var enrollment models.TwoFactorEnrollResponse
+	response := postAuthorized(router, "/users/2fa/enroll", accessToken, models.TwoFactorEnroll{Password: mockUser(success).Password}, &enrollment)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var confirmation models.TwoFactorConfirmResponse
+	response = postAuthorized(router, "/users/2fa/confirm", accessToken, models.TwoFactorConfirm{Code: totpCode(t, enrollment.Secret, time.Now())}, &confirmation)
+	assert.Equal(t, 200, response.StatusCode)
+
+	return enrollment.Secret, confirmation.RecoveryCodes
+}
+
+func loginChallenge(t *testing.T, router http.Handler) string {
+	var loginResponse models.UserLoginResponse
+	response := postAuthorized(router, "/users/login", "", login(success), &loginResponse)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, true, loginResponse.TwoFactorRequired)
+	assert.Equal(t, "", loginResponse.Token)
+	return loginResponse.ChallengeToken
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateTwoFactor(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	accessToken, _ := loginTokens(router)
+
+	// A session alone cannot set up an authenticator.
+	response := postAuthorized(router, "/users/2fa/enroll", accessToken, models.TwoFactorEnroll{Password: "wrong-password"}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	var enrollment models.TwoFactorEnrollResponse
+	response = postAuthorized(router, "/users/2fa/enroll", accessToken, models.TwoFactorEnroll{Password: mockUser(success).Password}, &enrollment)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, true, strings.HasPrefix(enrollment.URI, "otpauth://totp/Zenstore:"+user.Email+"?"))
+	assert.Equal(t, true, strings.Contains(enrollment.URI, "secret="+enrollment.Secret))
+
+	// The secret is not stored in the clear.
+	var stored models.TwoFactor
+	db.Where("user_id = ?", user.ID).Take(&stored)
+	assert.NotEqual(t, enrollment.Secret, stored.Secret)
+
+	// Until confirmed, login does not ask for a code.
+	accessToken, _ = loginTokens(router)
+	assert.NotEqual(t, "", accessToken)
+
+	response = postAuthorized(router, "/users/2fa/confirm", accessToken, models.TwoFactorConfirm{Code: "000000"}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	var confirmation models.TwoFactorConfirmResponse
+	response = postAuthorized(router, "/users/2fa/confirm", accessToken, models.TwoFactorConfirm{Code: totpCode(t, enrollment.Secret, time.Now())}, &confirmation)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 4, len(confirmation.RecoveryCodes))
+
+	response = postAuthorized(router, "/users/2fa/enroll", accessToken, models.TwoFactorEnroll{Password: mockUser(success).Password}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateTwoFactor(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+	secret, _ := enableTwoFactor(t, router)
+
+	challenge := loginChallenge(t, router)
+
+	response := postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: challenge, Code: "000000"})
+	assert.Equal(t, 401, response.StatusCode)
+
+	// The code used to confirm cannot be replayed, so take the next one.
+	code := totpCode(t, secret, time.Now().Add(auth.TOTPPeriod))
+
+	var loginResponse models.UserLoginResponse
+	response = postAuthorized(router, "/users/login/2fa", "", models.TwoFactorLogin{ChallengeToken: challenge, Code: code}, &loginResponse)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.NotEqual(t, "", loginResponse.Token)
+	assert.NotEqual(t, "", loginResponse.RefreshToken)
+
+	// A challenge completes one login.
+	response = postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: challenge, Code: code})
+	assert.Equal(t, 401, response.StatusCode)
+
+	// Wrong codes count as failed logins of the email.
+	assert.Equal(t, int64(2), countAttempts(db, models.LoginFailed))
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateTwoFactor(db)
+	router := routerTest(db)
+	createUser(mockUser(success), db)
+	_, recoveryCodes := enableTwoFactor(t, router)
+
+	// Recovery codes can be typed in any case and without the dash.
+	code := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+	response := postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: loginChallenge(t, router), Code: code})
+	assert.Equal(t, 200, response.StatusCode)
+
+	response = postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: loginChallenge(t, router), Code: recoveryCodes[0]})
+	assert.Equal(t, 401, response.StatusCode)
+
+	// A challenge stops taking codes after MaxAttempts.
+	challenge := loginChallenge(t, router)
+	for i := 0; i < 3; i++ {
+		response = postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: challenge, Code: "wrong-code"})
+		assert.Equal(t, 401, response.StatusCode)
+	}
+	response = postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: challenge, Code: recoveryCodes[1]})
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestTwoFactorDisable(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateTwoFactor(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	secret, _ := enableTwoFactor(t, router)
+	accessToken, _ := tokensTest.Issue(user.ID, "")
+	code := totpCode(t, secret, time.Now().Add(auth.TOTPPeriod))
+
+	// Both the password and a code are needed.
+	response := postAuthorized(router, "/users/2fa/disable", accessToken, models.TwoFactorVerify{Password: "wrong-password", Code: code}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+	response = postAuthorized(router, "/users/2fa/disable", accessToken, models.TwoFactorVerify{Password: mockUser(success).Password, Code: "000000"}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response = postAuthorized(router, "/users/2fa/disable", accessToken, models.TwoFactorVerify{Password: mockUser(success).Password, Code: code}, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var recoveryCodes int64
+	db.Model(&models.RecoveryCode{}).Where("user_id = ?", user.ID).Count(&recoveryCodes)
+	assert.Equal(t, int64(0), recoveryCodes)
+
+	// Login no longer asks for a code.
+	loginToken, _ := loginTokens(router)
+	assert.NotEqual(t, "", loginToken)
+
+	response = postAuthorized(router, "/users/2fa/disable", accessToken, models.TwoFactorVerify{Password: mockUser(success).Password, Code: code}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+}
+
+func TestTwoFactorRegenerateRecoveryCodes(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateTwoFactor(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	secret, oldCodes := enableTwoFactor(t, router)
+	accessToken, _ := tokensTest.Issue(user.ID, "")
+
+	response := postAuthorized(router, "/users/2fa/recovery-codes", accessToken, models.TwoFactorVerify{Password: "wrong-password", Code: totpCode(t, secret, time.Now().Add(auth.TOTPPeriod))}, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	var regenerated models.TwoFactorConfirmResponse
+	response = postAuthorized(router, "/users/2fa/recovery-codes", accessToken, models.TwoFactorVerify{Password: mockUser(success).Password, Code: totpCode(t, secret, time.Now().Add(auth.TOTPPeriod))}, &regenerated)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 4, len(regenerated.RecoveryCodes))
+
+	// The old codes stop working and the new ones log in.
+	response = postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: loginChallenge(t, router), Code: oldCodes[0]})
+	assert.Equal(t, 401, response.StatusCode)
+	response = postJSON(router, "/users/login/2fa", models.TwoFactorLogin{ChallengeToken: loginChallenge(t, router), Code: regenerated.RecoveryCodes[0]})
+	assert.Equal(t, 200, response.StatusCode)
+}
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zen-test/app/consts"
+	"zen-test/app/helpers"
+	"zen-test/app/web/models"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func createAdmin(db *gorm.DB) models.User {
+	hashPassword, _ := helpers.MakePassword("example")
+	admin := models.User{
+		ID:       uuid.New().String(),
+		Name:     "Admin Toko",
+		Email:    "admin@gmail.com",
+		Phone:    "08811212114",
+		Address:  "Bandung",
+		Password: hashPassword,
+		Role:     consts.UserRoleAdmin,
+	}
+
+	err := db.Model(&models.User{}).Create(&admin).Error
+	helpers.PanicIfError(err)
+
+	return admin
+}
+
+func getAuthorized(router http.Handler, path string, accessToken string, data interface{}) *http.Response {
+	request := httptest.NewRequest(http.MethodGet, baseURL+path, nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	return serveDecoded(router, request, data)
+}
+
+func TestAdminSearchUsers(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateProduct(db)
+	truncateOrder(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	staff := createStaff(db)
+	createAdmin(db)
+	product := createProduct(mockProduct(success), db)
+	createOrder(mockOrder(success, product.ID), user, product, db)
+	userToken, _ := tokensTest.Issue(user.ID, "")
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+
+	response := getAuthorized(router, "/admin/users", userToken, nil)
+	assert.Equal(t, 403, response.StatusCode)
+
+	var page models.UserPage
+	response = getAuthorized(router, "/admin/users?size=2", staffToken, &page)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Equal(t, 2, len(page.Users))
+
+	response = getAuthorized(router, "/admin/users?size=2&page=2", staffToken, &page)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, 1, len(page.Users))
+
+	response = getAuthorized(router, "/admin/users?q=staff", staffToken, &page)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, staff.ID, page.Users[0].ID)
+
+	response = getAuthorized(router, "/admin/users?role=CUSTOMER", staffToken, &page)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, user.ID, page.Users[0].ID)
+
+	response = getAuthorized(router, "/admin/users?page=first", staffToken, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	var detail models.UserDetailResponse
+	response = getAuthorized(router, "/admin/users/"+user.ID, staffToken, &detail)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, user.Email, detail.User.Email)
+	assert.Equal(t, 1, len(detail.Orders))
+}
+
+func TestDisabledUserIsRefused(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	truncateRefreshToken(db)
+	truncateLoginAttempt(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	staff := createStaff(db)
+	admin := createAdmin(db)
+	userToken, _ := tokensTest.Issue(user.ID, "")
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	accessToken, _ := loginTokens(router)
+
+	// Seen as active first, so the account is cached when it gets disabled.
+	response := getAuthorized(router, "/users/me", userToken, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var disabled models.UserResponse
+	response = postAuthorized(router, "/admin/users/"+user.ID+"/disable", staffToken, nil, &disabled)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, true, disabled.Disabled)
+
+	// The session is gone, and tokens without one are refused too.
+	response, _ = findSessions(router, accessToken)
+	assert.Equal(t, 401, response.StatusCode)
+	response = getAuthorized(router, "/users/me", userToken, nil)
+	assert.Equal(t, 403, response.StatusCode)
+
+	login := models.UserLogin{Email: user.Email, Password: mockUser(success).Password}
+	response = postJSON(router, "/users/login", login)
+	assert.Equal(t, 403, response.StatusCode)
+
+	// Staff manage customers only, and nobody disables themselves.
+	response = postAuthorized(router, "/admin/users/"+admin.ID+"/disable", staffToken, nil, nil)
+	assert.Equal(t, 403, response.StatusCode)
+	response = postAuthorized(router, "/admin/users/"+staff.ID+"/disable", staffToken, nil, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response = postAuthorized(router, "/admin/users/"+user.ID+"/enable", staffToken, nil, nil)
+	assert.Equal(t, 200, response.StatusCode)
+	response = postJSON(router, "/users/login", login)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestAdminChangesRoleAndForcesLogout(t *testing.T) {
+	db := dbTest()
+	truncateUser(db)
+	truncateSession(db)
+	truncateRefreshToken(db)
+	truncateLoginAttempt(db)
+	router := routerTest(db)
+	user := createUser(mockUser(success), db)
+	staff := createStaff(db)
+	admin := createAdmin(db)
+	staffToken, _ := tokensTest.Issue(staff.ID, "")
+	adminToken, _ := tokensTest.Issue(admin.ID, "")
+	accessToken, refreshToken := loginTokens(router)
+
+	request := httptest.NewRequest(http.MethodPut, baseURL+"/admin/users/"+user.ID+"/role", toRequestBody(models.UserRoleChange{Role: consts.UserRoleStaff}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+staffToken)
+	response := serveDecoded(router, request, nil)
+	assert.Equal(t, 403, response.StatusCode)
+
+	request = httptest.NewRequest(http.MethodPut, baseURL+"/admin/users/"+user.ID+"/role", toRequestBody(models.UserRoleChange{Role: consts.UserRoleStaff}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+adminToken)
+	var promoted models.UserResponse
+	response = serveDecoded(router, request, &promoted)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, consts.UserRoleStaff, promoted.Role)
+
+	request = httptest.NewRequest(http.MethodPut, baseURL+"/admin/users/"+admin.ID+"/role", toRequestBody(models.UserRoleChange{Role: consts.UserRoleCustomer}))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+adminToken)
+	response = serveDecoded(router, request, nil)
+	assert.Equal(t, 400, response.StatusCode)
+
+	// Staff cannot sign out accounts with more than customer rights.
+	response = postAuthorized(router, "/admin/users/"+admin.ID+"/logout", staffToken, nil, nil)
+	assert.Equal(t, 403, response.StatusCode)
+	response = postAuthorized(router, "/admin/users/"+user.ID+"/logout", staffToken, nil, nil)
+	assert.Equal(t, 403, response.StatusCode)
+
+	response = postAuthorized(router, "/admin/users/"+user.ID+"/logout", adminToken, nil, nil)
+	assert.Equal(t, 200, response.StatusCode)
+
+	response, _ = findSessions(router, accessToken)
+	assert.Equal(t, 401, response.StatusCode)
+	response, _ = refreshTokens(router, refreshToken)
+	assert.Equal(t, 401, response.StatusCode)
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-	"zen-test/app/auth"
 	"zen-test/app/helpers"
 	"zen-test/app/web/models"
 
@@ -89,6 +88,7 @@ func login(conditional string) models.UserLogin {
 
 func truncateUser(db *gorm.DB) {
 	db.Exec("TRUNCATE users")
+	truncateLoginAttempt(db)
 }
 
 func TestUserRegister(t *testing.T) {
@@ -183,14 +183,14 @@ func TestLoginFail(t *testing.T) {
 	router.ServeHTTP(recorder, request)
 
 	response := recorder.Result()
-	assert.Equal(t, 500, response.StatusCode)
+	assert.Equal(t, 401, response.StatusCode)
 
 	body, _ := io.ReadAll(response.Body)
 	var responseBody map[string]interface{}
 	json.Unmarshal(body, &responseBody)
 
-	assert.Equal(t, 500, int(responseBody["code"].(float64)))
-	assert.Equal(t, statusInternalServerError, responseBody["status"])
+	assert.Equal(t, 401, int(responseBody["code"].(float64)))
+	assert.Equal(t, statusUnauthorized, responseBody["status"])
 }
 
 func TestUpdateSuccess(t *testing.T) {
@@ -200,7 +200,7 @@ func TestUpdateSuccess(t *testing.T) {
 	data := mockUser(success) // make sure add success as parameter
 	user := createUser(data, db)
 	userId := user.ID
-	token, _ := auth.CreateToken(userId)
+	token, _ := tokensTest.Issue(userId, "")
 
 	requestBody := toRequestBody(mockUser(update))
 	request := httptest.NewRequest(http.MethodPut, baseURL+"/users/"+userId, requestBody)
@@ -0,0 +1,305 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"zen-test/app/consts"
+	"zen-test/app/events"
+	"zen-test/app/jobs"
+	"zen-test/app/web/models"
+	"zen-test/app/web/repositories"
+	"zen-test/app/web/services"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type webhookReceiver struct {
+	server   *httptest.Server
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+// newWebhookReceiver answers each request with the next status in statuses,
+// repeating the last one once they run out.
+func newWebhookReceiver(statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+
+		status := receiver.statuses[len(receiver.statuses)-1]
+		if len(receiver.requests) <= len(receiver.statuses) {
+			status = receiver.statuses[len(receiver.requests)-1]
+		}
+		w.WriteHeader(status)
+	}))
+	return receiver
+}
+
+// webhookServiceTest delivers to the local receivers of the tests, which the
+// service used by the router refuses as private targets.
+func webhookServiceTest(db *gorm.DB, queue *jobs.Queue) services.WebhookService {
+	webhookService := services.NewWebhookService(repositories.NewWebhookRepository(), repositories.NewUserRepository(), queue, services.WebhookConfig{Timeout: 5 * time.Second, AllowPrivateTargets: true}, db, validator.New())
+	jobs.Handle(queue, consts.JobTypeWebhookDelivery, func(ctx context.Context, job models.WebhookDeliveryJob) error {
+		return webhookService.Deliver(ctx, job.DeliveryID)
+	})
+	return webhookService
+}
+
+func createWebhook(db *gorm.DB, url string, eventTypes string) models.WebhookSubscription {
+	subscription := models.WebhookSubscription{
+		ID:         uuid.New().String(),
+		UserID:     uuid.New().String(),
+		URL:        url,
+		Secret:     "whsec_test_secret",
+		EventTypes: eventTypes,
+		Active:     true,
+	}
+
+	err := db.Create(&subscription).Error
+	if err != nil {
+		panic(err)
+	}
+	return subscription
+}
+
+func truncateWebhook(db *gorm.DB) {
+	db.Exec("TRUNCATE webhook_subscriptions")
+	db.Exec("TRUNCATE webhook_deliveries")
+}
+
+func orderPaidEvent() events.Event {
+	return events.Event{
+		ID:            uuid.New().String(),
+		Type:          consts.EventOrderPaid,
+		AggregateType: "order",
+		AggregateID:   uuid.New().String(),
+		Payload:       json.RawMessage(`{"status":"PAID"}`),
+		OccurredAt:    time.Now(),
+	}
+}
+
+func TestCreateWebhookSuccess(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateWebhook(db)
+
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+
+	requestBody := map[string]interface{}{
+		"url":         "https://partner.example.com/hooks",
+		"event_types": []string{consts.EventOrderPaid, consts.EventStockChanged},
+	}
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/webhooks", toRequestBody(requestBody))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	body, _ := io.ReadAll(recorder.Result().Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(body, &responseBody)
+
+	data := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, true, strings.HasPrefix(data["secret"].(string), "whsec_"))
+	assert.Equal(t, 2, len(data["event_types"].([]interface{})))
+}
+
+func TestCreateWebhookForbidden(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateWebhook(db)
+
+	user := createUser(mockUser(success), db)
+	token, _ := tokensTest.Issue(user.ID, "")
+
+	requestBody := map[string]interface{}{
+		"url":         "https://partner.example.com/hooks",
+		"event_types": []string{consts.EventOrderPaid},
+	}
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/webhooks", toRequestBody(requestBody))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 403, recorder.Result().StatusCode)
+}
+
+func TestCreateWebhookPrivateTarget(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateWebhook(db)
+
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+
+	for _, url := range []string{"http://partner.example.com/hooks", "https://127.0.0.1/hooks", "https://169.254.169.254/latest/meta-data", "https://10.1.2.3/hooks"} {
+		requestBody := map[string]interface{}{
+			"url":         url,
+			"event_types": []string{consts.EventOrderPaid},
+		}
+		request := httptest.NewRequest(http.MethodPost, baseURL+"/webhooks", toRequestBody(requestBody))
+		request.Header.Add("Content-Type", "application/json")
+		request.Header.Add("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, 400, recorder.Result().StatusCode)
+	}
+
+	var subscriptions int64
+	db.Model(&models.WebhookSubscription{}).Count(&subscriptions)
+	assert.Equal(t, int64(0), subscriptions)
+}
+
+func TestWebhookDeliveryRefusesPrivateTarget(t *testing.T) {
+	db := dbTest()
+	truncateWebhook(db)
+	truncateJob(db)
+
+	receiver := newWebhookReceiver(http.StatusOK)
+	defer receiver.server.Close()
+
+	// A host name resolving to loopback passes the URL check and is only
+	// caught when dialled.
+	url := strings.Replace(receiver.server.URL, "http://127.0.0.1", "https://localhost", 1)
+	subscription := createWebhook(db, url, consts.EventOrderPaid)
+
+	queue := queueTest(db)
+	webhookService := services.NewWebhookService(repositories.NewWebhookRepository(), repositories.NewUserRepository(), queue, services.WebhookConfig{Timeout: 5 * time.Second}, db, validator.New())
+	jobs.Handle(queue, consts.JobTypeWebhookDelivery, func(ctx context.Context, job models.WebhookDeliveryJob) error {
+		return webhookService.Deliver(ctx, job.DeliveryID)
+	})
+	webhookService.Dispatch(context.Background(), orderPaidEvent())
+
+	_, err := queue.RunNext(context.Background())
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, 0, len(receiver.requests))
+
+	var delivery models.WebhookDelivery
+	db.Where("subscription_id = ?", subscription.ID).Take(&delivery)
+	assert.Equal(t, false, delivery.Succeeded)
+	assert.NotEqual(t, "", delivery.Error)
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	db := dbTest()
+	truncateWebhook(db)
+	truncateJob(db)
+
+	receiver := newWebhookReceiver(http.StatusOK)
+	defer receiver.server.Close()
+
+	subscription := createWebhook(db, receiver.server.URL, consts.EventOrderPaid)
+	createWebhook(db, receiver.server.URL, consts.EventProductUpdated)
+
+	queue := queueTest(db)
+	webhookService := webhookServiceTest(db, queue)
+
+	event := orderPaidEvent()
+	assert.Equal(t, nil, webhookService.Dispatch(context.Background(), event))
+	// The bus delivers at least once; a repeated event is not sent again.
+	assert.Equal(t, nil, webhookService.Dispatch(context.Background(), event))
+
+	for {
+		ran, err := queue.RunNext(context.Background())
+		assert.Equal(t, nil, err)
+		if !ran {
+			break
+		}
+	}
+
+	assert.Equal(t, 1, len(receiver.requests))
+
+	request := receiver.requests[0]
+	assert.Equal(t, consts.EventOrderPaid, request.Header.Get(services.WebhookEventHeader))
+
+	var timestamp int64
+	var signature string
+	fmt.Sscanf(strings.Replace(request.Header.Get(services.WebhookSignatureHeader), ",v1=", " ", 1), "t=%d %s", &timestamp, &signature)
+	assert.Equal(t, services.SignWebhookPayload(subscription.Secret, timestamp, receiver.bodies[0]), signature)
+
+	var delivery models.WebhookDelivery
+	db.Where("subscription_id = ?", subscription.ID).Take(&delivery)
+	assert.Equal(t, true, delivery.Succeeded)
+	assert.Equal(t, 200, delivery.StatusCode)
+	assert.Equal(t, 1, delivery.Attempts)
+}
+
+func TestWebhookRedeliverAfterFailure(t *testing.T) {
+	db := dbTest()
+	router := routerTest(db)
+	truncateUser(db)
+	truncateWebhook(db)
+	truncateJob(db)
+
+	receiver := newWebhookReceiver(http.StatusInternalServerError, http.StatusOK)
+	defer receiver.server.Close()
+
+	subscription := createWebhook(db, receiver.server.URL, consts.EventOrderPaid)
+
+	queue := queueTest(db)
+	webhookService := webhookServiceTest(db, queue)
+	webhookService.Dispatch(context.Background(), orderPaidEvent())
+
+	_, err := queue.RunNext(context.Background())
+	assert.Equal(t, nil, err)
+
+	var delivery models.WebhookDelivery
+	db.Where("subscription_id = ?", subscription.ID).Take(&delivery)
+	assert.Equal(t, false, delivery.Succeeded)
+	assert.Equal(t, 500, delivery.StatusCode)
+
+	// Drop the pending retry so only the manual redelivery is left to run.
+	db.Exec("DELETE FROM jobs")
+
+	staff := createStaff(db)
+	token, _ := tokensTest.Issue(staff.ID, "")
+
+	request := httptest.NewRequest(http.MethodPost, baseURL+"/webhooks/deliveries/"+delivery.ID+"/redeliver", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+
+	ran, err := queue.RunNext(context.Background())
+	assert.Equal(t, true, ran)
+	assert.Equal(t, nil, err)
+
+	db.Where("id = ?", delivery.ID).Take(&delivery)
+	assert.Equal(t, true, delivery.Succeeded)
+	assert.Equal(t, 2, delivery.Attempts)
+
+	request = httptest.NewRequest(http.MethodGet, baseURL+"/webhooks/"+subscription.ID+"/deliveries", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+}